@@ -0,0 +1,141 @@
+package schema
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+)
+
+// EncodeBatches encodes src into as many batches of values as needed for
+// the encoded query of each batch to fit in maxLen bytes, e.g. to query an
+// API limiting the length of URLs with thousands of IDs. The values of the
+// field splitField, given by path as in EncodeOptions.Include, are
+// partitioned across the batches, following the other values which are
+// repeated in each batch. Lengths are measured as by SetMaxEncodedLength.
+// Slice elements in the SliceKeyIndexed style are numbered from 0 in each
+// batch.
+//
+// A single batch is returned if the split field has no values. If the
+// other values with a single value of the split field don't fit, or the
+// other values alone if it has none, a LengthExceededError is returned.
+func (e *Encoder) EncodeBatches(src any, maxLen int, splitField string) ([]UrlValues, error) {
+	if maxLen <= 0 {
+		return nil, errors.New("schema: the maximum length of batches must be positive")
+	}
+	t := reflect.TypeOf(src)
+	if t != nil && t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	var field *fieldPlan
+	if t != nil && t.Kind() == reflect.Struct {
+		_, plans := e.encoders(context.Background())
+		if field = e.fieldPlanOf(t, splitField, plans, map[reflect.Type]bool{}); field == nil {
+			return nil, fmt.Errorf("schema: %q is not a field of %v", splitField, t)
+		}
+	}
+	opts := e.opts
+	opts.Exclude = append(opts.Exclude[:len(opts.Exclude):len(opts.Exclude)], splitField)
+	common, err := e.EncodeWithOptions(src, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	// The split values are encoded without hooks, which added their values
+	// to the common ones.
+	opts = e.opts
+	opts.Include = []string{splitField}
+	s := &encodeState{ctx: context.Background(), values: UrlValues{}, opts: opts, noHooks: true}
+	s.regenc, s.plans = e.encoders(s.ctx)
+	if err := e.encode(reflect.ValueOf(src), "", "", s); err != nil {
+		return nil, err
+	}
+	split := s.values
+	for i := range split {
+		split[i].Key = opts.KeyPrefix + split[i].Key
+	}
+	indexed := false
+	if field != nil && field.typ.Kind() == reflect.Slice && field.delim == "" {
+		style := opts.SliceKeyStyle
+		if field.slice != "" {
+			style = field.sliceStyle
+		}
+		indexed = style == SliceKeyIndexed
+	}
+	base := encodedLength(common, e.escape)
+	if base > maxLen {
+		return nil, LengthExceededError{Length: base, Max: maxLen}
+	}
+	var batches []UrlValues
+	batch, n, index := common.Clone(), base, 0
+	for _, p := range split {
+		if indexed {
+			p.Key = reindex(p.Key, index)
+		}
+		pair := encodedLength(UrlValues{p}, e.escape)
+		if len(batch) > len(common) && n+1+pair > maxLen {
+			batches = append(batches, batch)
+			batch, n, index = common.Clone(), base, 0
+			if indexed {
+				p.Key = reindex(p.Key, index)
+				pair = encodedLength(UrlValues{p}, e.escape)
+			}
+		}
+		if len(batch) > 0 {
+			pair++ // separator
+		}
+		if n+pair > maxLen {
+			return nil, LengthExceededError{Length: n + pair, Max: maxLen}
+		}
+		batch, n, index = append(batch, p), n+pair, index+1
+	}
+	return append(batches, batch), nil
+}
+
+// reindex replaces the index of key, a slice element key in the
+// SliceKeyIndexed style, by i.
+func reindex(key string, i int) string {
+	if j := strings.LastIndexByte(key, '['); j >= 0 {
+		key = key[:j]
+	}
+	return SliceKeyIndexed.key(key, i)
+}
+
+// fieldPlanOf returns the plan of the field named by path, as in
+// EncodeOptions.Include, in the struct type t, or nil if there is none.
+// visiting holds the flattened structs being searched, to stop on
+// recursively embedded structs.
+func (e *Encoder) fieldPlanOf(t reflect.Type, path string, plans *planCache, visiting map[reflect.Type]bool) *fieldPlan {
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	if t.Kind() != reflect.Struct {
+		return nil
+	}
+	fields := plans.get(e, t, "").fields
+	for i := range fields {
+		f := &fields[i]
+		switch {
+		case f.alias == "-":
+		case f.alias == path:
+			return f
+		case f.flatten:
+			if ft := indirectType(f.typ); !visiting[ft] {
+				visiting[ft] = true
+				found := e.fieldPlanOf(ft, path, plans, visiting)
+				delete(visiting, ft)
+				if found != nil {
+					return found
+				}
+			}
+		default:
+			if rest, ok := strings.CutPrefix(path, f.alias+"."); ok {
+				if found := e.fieldPlanOf(f.typ, rest, plans, visiting); found != nil {
+					return found
+				}
+			}
+		}
+	}
+	return nil
+}
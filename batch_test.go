@@ -0,0 +1,160 @@
+package schema
+
+import (
+	"reflect"
+	"slices"
+	"strconv"
+	"testing"
+)
+
+func TestEncodeBatches(t *testing.T) {
+	type S struct {
+		Fields string `schema:"fields"`
+		IDs    []int  `schema:"id"`
+		Limit  int    `schema:"limit"`
+	}
+	src := S{Fields: "name", Limit: 10}
+	for i := 1; i <= 25; i++ {
+		src.IDs = append(src.IDs, i)
+	}
+
+	encoder := NewEncoder()
+	encoder.BeforeEncode(func(src any, values *UrlValues) error {
+		values.Add("v", "2")
+		return nil
+	})
+	const maxLen = 64
+	batches, err := encoder.EncodeBatches(src, maxLen, "id")
+	noError(t, err)
+	if len(batches) < 2 {
+		t.Fatalf("expected several batches, got %v", batches)
+	}
+	var ids []string
+	for _, batch := range batches {
+		if n := len(batch.Encode()); n > maxLen {
+			t.Errorf("batch %q is %d bytes long", batch.Encode(), n)
+		}
+		common := UrlValues{{"v", "2"}, {"fields", "name"}, {"limit", "10"}}
+		if !reflect.DeepEqual(batch[:3], common) {
+			t.Errorf("expected batch to start with %v, got %v", common, batch)
+		}
+		for _, p := range batch[3:] {
+			if p.Key != "id" {
+				t.Errorf("unexpected value %v in %v", p, batch)
+			}
+			ids = append(ids, p.Value)
+		}
+		// Batches are full: the next ID would not fit.
+		if batch := append(batch.Clone(), UrlValue{"id", "99"}); len(batch.Encode()) <= maxLen && len(ids) < len(src.IDs) {
+			t.Errorf("batch %v is not full", batch)
+		}
+	}
+	for i, id := range ids {
+		if id != strconv.Itoa(i+1) {
+			t.Fatalf("expected all IDs in order, got %v", ids)
+		}
+	}
+	if len(ids) != len(src.IDs) {
+		t.Errorf("expected %d IDs, got %v", len(src.IDs), ids)
+	}
+
+	batches, err = encoder.EncodeBatches(S{Fields: "name"}, maxLen, "id")
+	noError(t, err)
+	if want := []UrlValues{{{"v", "2"}, {"fields", "name"}, {"limit", "0"}}}; !reflect.DeepEqual(batches, want) {
+		t.Errorf("expected %v, got %v", want, batches)
+	}
+
+	if _, err := encoder.EncodeBatches(src, 26, "id"); err == nil {
+		t.Error("expected a LengthExceededError")
+	} else if _, ok := err.(LengthExceededError); !ok {
+		t.Errorf("expected a LengthExceededError, got %v", err)
+	}
+}
+
+type batchSigned struct {
+	Q   string `schema:"q"`
+	IDs []int  `schema:"id"`
+}
+
+func (b batchSigned) EncodeHook(values *UrlValues) error {
+	values.Add("sig", "abc")
+	return nil
+}
+
+func TestEncodeBatchesHook(t *testing.T) {
+	src := batchSigned{Q: "x", IDs: []int{1, 2, 3, 4}}
+	batches, err := NewEncoder().EncodeBatches(src, len("q=x&sig=abc&id=1&id=2"), "id")
+	noError(t, err)
+	want := []UrlValues{
+		{{"q", "x"}, {"sig", "abc"}, {"id", "1"}, {"id", "2"}},
+		{{"q", "x"}, {"sig", "abc"}, {"id", "3"}, {"id", "4"}},
+	}
+	if !reflect.DeepEqual(batches, want) {
+		t.Errorf("expected %v, got %v", want, batches)
+	}
+}
+
+func TestEncodeBatchesIndexed(t *testing.T) {
+	type S struct {
+		IDs  []int `schema:"id"`
+		Tags []int `schema:"tag,slice=indexed"`
+	}
+	encoder := NewEncoder()
+	encoder.SetSliceKeyStyle(SliceKeyIndexed)
+	batches, err := encoder.EncodeBatches(S{IDs: []int{1, 2, 3}}, len("id%5B0%5D=1&id%5B1%5D=2"), "id")
+	noError(t, err)
+	want := []UrlValues{{{"id[0]", "1"}, {"id[1]", "2"}}, {{"id[0]", "3"}}}
+	if !reflect.DeepEqual(batches, want) {
+		t.Errorf("expected %v, got %v", want, batches)
+	}
+	for _, batch := range batches {
+		var dst S
+		noError(t, NewDecoder().Decode(&dst, sliceKeys(batch.Values(), nil)))
+		if slices.Contains(dst.IDs, 0) {
+			t.Errorf("expected no zero IDs decoding %v, got %v", batch, dst.IDs)
+		}
+	}
+
+	batches, err = NewEncoder().EncodeBatches(S{Tags: []int{1, 2, 3}}, len("tag%5B0%5D=1&tag%5B1%5D=2"), "tag")
+	noError(t, err)
+	want = []UrlValues{{{"tag[0]", "1"}, {"tag[1]", "2"}}, {{"tag[0]", "3"}}}
+	if !reflect.DeepEqual(batches, want) {
+		t.Errorf("expected %v, got %v", want, batches)
+	}
+}
+
+func TestEncodeBatchesErrors(t *testing.T) {
+	type Page struct {
+		Size int `schema:"size"`
+	}
+	type Filter struct {
+		IDs []int `schema:"id"`
+	}
+	type S struct {
+		Page
+		Fields string `schema:"fields"`
+		Filter Filter `schema:"filter"`
+		IDs    []int  `schema:"id"`
+	}
+	src := S{Fields: "name,price,stock", IDs: []int{1, 2, 3, 4, 5}}
+	encoder := NewEncoder()
+
+	// The common values must fit, even without split values.
+	for _, s := range []S{src, {Fields: src.Fields}} {
+		_, err := encoder.EncodeBatches(s, 12, "id")
+		if e, ok := err.(LengthExceededError); !ok || e.Max != 12 {
+			t.Errorf("expected a LengthExceededError, got %v", err)
+		}
+	}
+
+	for _, field := range []string{"ids", "fields.id", "Page.size", ""} {
+		if _, err := encoder.EncodeBatches(src, 64, field); err == nil {
+			t.Errorf("expected an error for the split field %q", field)
+		}
+	}
+	for _, field := range []string{"id", "size", "filter", "filter.id"} {
+		if _, err := encoder.EncodeBatches(&src, 64, field); err != nil {
+			t.Errorf("unexpected error for the split field %q: %v", field, err)
+		}
+	}
+}
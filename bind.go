@@ -0,0 +1,92 @@
+package schema
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+)
+
+// paramsKey is the context key of the params bound by Bind.
+type paramsKey[T any] struct{}
+
+// Bind returns a handler decoding the query, form body and path values of
+// the requests into a T, as by Decoder.DecodeRequest with a Decoder
+// configured by opts, then calling handler with it. The decoded params are
+// also stored in the request context; see ParamsFromContext.
+//
+// If the request can't be decoded or validated, handler isn't called and a
+// 400 Bad Request response is written, with a JSON body mapping the
+// failing keys to their error, such as:
+//
+//	{"errors":{"page":"schema: error converting value for \"page\""}}
+func Bind[T any](handler func(w http.ResponseWriter, r *http.Request, params T), opts ...DecoderOption) http.Handler {
+	d := NewDecoder(opts...)
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var params T
+		if err := d.DecodeRequest(&params, r); err != nil {
+			writeBindError(w, err)
+			return
+		}
+		r = r.WithContext(context.WithValue(r.Context(), paramsKey[T]{}, params))
+		handler(w, r, params)
+	})
+}
+
+// ParamsFromContext returns the params of type T bound by Bind to the
+// request of ctx, and whether there are any.
+func ParamsFromContext[T any](ctx context.Context) (T, bool) {
+	params, ok := ctx.Value(paramsKey[T]{}).(T)
+	return params, ok
+}
+
+// RequestBinder binds requests to structs with a Decoder, as by
+// Decoder.DecodeRequest. It satisfies the binding.Binding interface of gin
+// without depending on it:
+//
+//	c.ShouldBindWith(&params, schema.NewRequestBinder())
+//
+// Echo's Binder takes an echo.Context, so it needs a small wrapper:
+//
+//	type echoBinder struct{ *schema.RequestBinder }
+//
+//	func (b echoBinder) Bind(i any, c echo.Context) error {
+//		return b.RequestBinder.Bind(c.Request(), i)
+//	}
+//
+// Path values are only decoded from routers setting http.Request.PathValue.
+type RequestBinder struct {
+	Decoder *Decoder
+}
+
+// NewRequestBinder returns a RequestBinder with a Decoder configured by
+// opts.
+func NewRequestBinder(opts ...DecoderOption) *RequestBinder {
+	return &RequestBinder{Decoder: NewDecoder(opts...)}
+}
+
+// Name returns the name of the binding, "schema".
+func (b *RequestBinder) Name() string {
+	return "schema"
+}
+
+// Bind decodes r into dst.
+func (b *RequestBinder) Bind(r *http.Request, dst any) error {
+	return b.Decoder.DecodeRequest(dst, r)
+}
+
+// writeBindError writes the 400 Bad Request response of Bind for err.
+func writeBindError(w http.ResponseWriter, err error) {
+	errs := map[string]string{}
+	if m, ok := err.(MultiError); ok {
+		for key, err := range m {
+			errs[key] = err.Error()
+		}
+	} else {
+		errs[""] = err.Error()
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusBadRequest)
+	json.NewEncoder(w).Encode(struct {
+		Errors map[string]string `json:"errors"`
+	}{errs})
+}
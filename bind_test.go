@@ -0,0 +1,63 @@
+package schema
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestBind(t *testing.T) {
+	type ListParams struct {
+		Q    string `schema:"q,required"`
+		Page int    `schema:"page"`
+	}
+	var got ListParams
+	h := Bind(func(w http.ResponseWriter, r *http.Request, params ListParams) {
+		got = params
+		if p, ok := ParamsFromContext[ListParams](r.Context()); !ok || p != params {
+			t.Errorf("expected the params in the context, got %v", p)
+		}
+		w.WriteHeader(http.StatusNoContent)
+	}, WithIgnoreUnknownKeys())
+
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/?q=x&page=2&other=1", nil))
+	if rec.Code != http.StatusNoContent || got != (ListParams{Q: "x", Page: 2}) {
+		t.Errorf("unexpected response %d for %+v", rec.Code, got)
+	}
+
+	rec = httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/?page=x", nil))
+	body := rec.Body.String()
+	if rec.Code != http.StatusBadRequest || rec.Header().Get("Content-Type") != "application/json" {
+		t.Errorf("unexpected response %d %v", rec.Code, rec.Header())
+	}
+	for _, want := range []string{`"q":"q is empty"`, `"page":"schema: error converting value for \"page\""`} {
+		if !strings.Contains(body, want) {
+			t.Errorf("expected %s in %s", want, body)
+		}
+	}
+}
+
+func TestRequestBinder(t *testing.T) {
+	// binding is the binding.Binding interface of gin.
+	type binding interface {
+		Name() string
+		Bind(*http.Request, any) error
+	}
+	var b binding = NewRequestBinder(WithIgnoreUnknownKeys())
+	if b.Name() != "schema" {
+		t.Errorf("unexpected name %q", b.Name())
+	}
+
+	var dst struct {
+		Q string `schema:"q"`
+	}
+	r := httptest.NewRequest(http.MethodPost, "/?other=1", strings.NewReader("q=x"))
+	r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	noError(t, b.Bind(r, &dst))
+	if dst.Q != "x" {
+		t.Errorf("unexpected result %+v", dst)
+	}
+}
@@ -6,10 +6,12 @@ package schema
 
 import (
 	"errors"
+	"fmt"
+	"maps"
 	"reflect"
+	"slices"
 	"strconv"
 	"strings"
-	"sync"
 )
 
 var errInvalidPath = errors.New("schema: invalid path")
@@ -17,23 +19,47 @@ var errInvalidPath = errors.New("schema: invalid path")
 // newCache returns a new cache.
 func newCache() *cache {
 	c := cache{
-		m:       make(map[reflect.Type]*structInfo),
-		regconv: make(map[reflect.Type]Converter),
-		tag:     "schema",
+		regconv: make(map[reflect.Type]ErrorConverter),
+		enums:   make(map[reflect.Type][]string),
+		tags:    []string{"schema"},
 	}
 	return &c
 }
 
 // cache caches meta-data about a struct.
 type cache struct {
-	l       sync.RWMutex
-	m       map[reflect.Type]*structInfo
-	regconv map[reflect.Type]Converter
-	tag     string
+	m       cowMap[reflect.Type, *structInfo]
+	regconv map[reflect.Type]ErrorConverter
+	// enums holds the values allowed for the types registered by
+	// Decoder.RegisterEnum.
+	enums map[reflect.Type][]string
+	tags  []string
+	// naming derives the alias of fields without one in their tag.
+	naming NamingStrategy
+	// preferExact makes keys matching an alias exactly take precedence
+	// over case-insensitive matches. See Decoder.PreferExactKeys.
+	preferExact bool
+	// taggedOnly ignores the fields without one of the tags, except
+	// embedded structs. It is used to decode side tags such as "header".
+	taggedOnly bool
+}
+
+// clone returns a copy of the cache which doesn't share mutable state
+// with c.
+func (c *cache) clone() *cache {
+	clone := &cache{
+		regconv:     maps.Clone(c.regconv),
+		enums:       maps.Clone(c.enums),
+		tags:        slices.Clone(c.tags),
+		naming:      c.naming,
+		preferExact: c.preferExact,
+	}
+	clone.m.reset(c.m.snapshot())
+	return clone
 }
 
 // registerConverter registers a converter function for a custom type.
-func (c *cache) registerConverter(value interface{}, converterFunc Converter) {
+func (c *cache) registerConverter(value interface{}, converterFunc ErrorConverter) {
 	c.regconv[reflect.TypeOf(value)] = converterFunc
 }
 
@@ -58,11 +84,24 @@ func (c *cache) parsePath(p string, t reflect.Type) ([]pathPart, error) {
 		if struc = c.get(t); struc == nil {
 			return nil, errInvalidPath
 		}
-		if field = struc.get(keys[i]); field == nil {
+		if field = struc.get(keys[i], c.preferExact); field == nil {
 			return nil, errInvalidPath
 		}
 		// Valid field. Append index.
 		path = append(path, field.name)
+		if field.isMap {
+			// The rest of the path is the map key.
+			mapKey := strings.Join(keys[i+1:], ".")
+			if mapKey == "" {
+				return nil, errInvalidPath
+			}
+			return append(parts, pathPart{
+				path:   path,
+				field:  field,
+				index:  -1,
+				mapKey: mapKey,
+			}), nil
+		}
 		if field.isSliceOfStructs && (!field.unmarshalerInfo.IsValid || (field.unmarshalerInfo.IsValid && field.unmarshalerInfo.IsSliceElement)) {
 			// Parse a special case: slices of structs.
 			// i+1 must be the slice index.
@@ -111,29 +150,82 @@ func (c *cache) parsePath(p string, t reflect.Type) ([]pathPart, error) {
 	return parts, nil
 }
 
+// dottedKey converts the bracket notation of key to the dotted notation, e.g.
+// "items[0][name]" to "items.0.name". Both notations may be mixed, as in
+// "filter[price].from". Malformed keys, or keys with empty brackets, are
+// returned unchanged.
+func dottedKey(key string) string {
+	i := strings.IndexByte(key, '[')
+	if i < 0 {
+		return key
+	}
+	var b strings.Builder
+	b.Grow(len(key))
+	b.WriteString(key[:i])
+	for rest := key[i:]; rest != ""; {
+		if rest[0] == '.' {
+			next := strings.IndexByte(rest, '[')
+			if next < 0 {
+				b.WriteString(rest)
+				break
+			}
+			if next == 1 {
+				return key
+			}
+			b.WriteString(rest[:next])
+			rest = rest[next:]
+			continue
+		}
+		end := strings.IndexByte(rest, ']')
+		if rest[0] != '[' || end < 2 {
+			return key
+		}
+		b.WriteByte('.')
+		b.WriteString(rest[1:end])
+		rest = rest[end+1:]
+	}
+	return b.String()
+}
+
+// dottedKeys returns src with the keys converted by dottedKey, or src
+// itself if no key uses the bracket notation.
+func dottedKeys(src map[string][]string) map[string][]string {
+	var dotted map[string][]string
+	for key := range src {
+		if strings.IndexByte(key, '[') >= 0 {
+			dotted = make(map[string][]string, len(src))
+			break
+		}
+	}
+	if dotted == nil {
+		return src
+	}
+	for key, values := range src {
+		k := dottedKey(key)
+		dotted[k] = append(dotted[k], values...)
+	}
+	return dotted
+}
+
 // get returns a cached structInfo, creating it if necessary.
 func (c *cache) get(t reflect.Type) *structInfo {
-	c.l.RLock()
-	info := c.m[t]
-	c.l.RUnlock()
-	if info == nil {
-		info = c.create(t, "")
-		c.l.Lock()
-		c.m[t] = info
-		c.l.Unlock()
+	info, ok := c.m.load(t)
+	if !ok {
+		info = c.create(t, "", "")
+		c.m.store(t, info)
 	}
 	return info
 }
 
 // create creates a structInfo with meta-data about a struct.
-func (c *cache) create(t reflect.Type, parentAlias string) *structInfo {
+func (c *cache) create(t reflect.Type, parentAlias, parentName string) *structInfo {
 	info := &structInfo{}
 	var anonymousInfos []*structInfo
 	for i := 0; i < t.NumField(); i++ {
-		if f := c.createField(t.Field(i), parentAlias); f != nil {
+		if f := c.createField(t.Field(i), parentAlias, parentName); f != nil {
 			info.fields = append(info.fields, f)
 			if ft := indirectType(f.typ); ft.Kind() == reflect.Struct && f.isAnonymous {
-				anonymousInfos = append(anonymousInfos, c.create(ft, f.canonicalAlias))
+				anonymousInfos = append(anonymousInfos, c.create(ft, f.canonicalAlias, f.canonicalName))
 			}
 		}
 	}
@@ -151,15 +243,27 @@ func (c *cache) create(t reflect.Type, parentAlias string) *structInfo {
 }
 
 // createField creates a fieldInfo for the given field.
-func (c *cache) createField(field reflect.StructField, parentAlias string) *fieldInfo {
-	alias, options := fieldAlias(field, c.tag)
+func (c *cache) createField(field reflect.StructField, parentAlias, parentName string) *fieldInfo {
+	if c.taggedOnly && !field.Anonymous && lookupTag(field, c.tags) == "" {
+		return nil
+	}
+	if !c.taggedOnly && hasSideTag(field, c.tags) {
+		return nil
+	}
+	alias, options := fieldAlias(field, c.tags)
+	if c.naming != nil {
+		if name, _ := parseTag(lookupTag(field, c.tags)); name == "" {
+			alias = c.naming(field.Name)
+		}
+	}
 	if alias == "-" {
 		// Ignore this field.
 		return nil
 	}
-	canonicalAlias := alias
+	canonicalAlias, canonicalName := alias, field.Name
 	if parentAlias != "" {
 		canonicalAlias = parentAlias + "." + alias
+		canonicalName = parentName + "." + field.Name
 	}
 	// Check if the type is supported and don't cache it if not.
 	// First let's get the basic type.
@@ -181,28 +285,94 @@ func (c *cache) createField(field reflect.StructField, parentAlias string) *fiel
 			ft = ft.Elem()
 		}
 	}
-	if isStruct = ft.Kind() == reflect.Struct; !isStruct {
+	// Maps are decoded from prefixed keys.
+	isMap := !isSlice && ft.Kind() == reflect.Map && c.converter(ft) == nil
+	if isMap {
+		if !isMapKeyType(ft.Key()) {
+			return nil
+		}
+		ft = ft.Elem()
+	}
+	if isStruct = ft.Kind() == reflect.Struct; !isStruct || isMap {
 		if c.converter(ft) == nil && builtinConverters[ft.Kind()] == nil {
 			// Type is not supported.
 			return nil
 		}
 	}
 
-	return &fieldInfo{
+	info := &fieldInfo{
 		typ:              field.Type,
 		name:             field.Name,
 		alias:            alias,
 		canonicalAlias:   canonicalAlias,
+		canonicalName:    canonicalName,
 		unmarshalerInfo:  m,
 		isSliceOfStructs: isSlice && isStruct,
+		isMap:            isMap,
 		isAnonymous:      field.Anonymous,
 		isRequired:       options.Contains("required"),
 		defaultValue:     options.getDefaultOptionValue(),
+		delim:            options.getDelimiter(),
+	}
+	if o, ok := options.getOptionValue("max"); ok {
+		n, err := strconv.Atoi(o)
+		if err != nil || n < 0 {
+			info.maxErr = fmt.Errorf("schema: invalid max %q for %v", o, field.Name)
+		}
+		info.maxLen = n
+	}
+	if info.defaultValue != "" {
+		if info.isRequired {
+			info.defaultErr = errors.New("required fields cannot have a default value")
+		} else {
+			info.defaultVal, info.defaultErr = parseDefault(field.Type, field.Name, info.defaultValue)
+		}
+	}
+	return info
+}
+
+var errUnsupportedDefault = errors.New("default option is supported only on: bool, float variants, string, unit variants types or their corresponding pointers or slices")
+
+// parseDefault parses the default value of the field name of type t. The
+// values of slices are separated by "|".
+func parseDefault(t reflect.Type, name, value string) (reflect.Value, error) {
+	convert := func(t reflect.Type, value string) (reflect.Value, error) {
+		conv := builtinConverters[t.Kind()]
+		if conv == nil || t.Kind() == reflect.Struct {
+			return reflect.Value{}, errUnsupportedDefault
+		}
+		v := conv(value)
+		if !v.IsValid() {
+			return reflect.Value{}, fmt.Errorf("failed setting default: %s is not compatible with field %s type", value, name)
+		}
+		return v.Convert(t), nil
 	}
+	switch t.Kind() {
+	case reflect.Slice:
+		values := strings.Split(value, "|")
+		s := reflect.MakeSlice(t, 0, len(values))
+		for _, value := range values {
+			v, err := convert(t.Elem(), value)
+			if err != nil {
+				return reflect.Value{}, err
+			}
+			s = reflect.Append(s, v)
+		}
+		return s, nil
+	case reflect.Ptr:
+		v, err := convert(t.Elem(), value)
+		if err != nil {
+			return reflect.Value{}, err
+		}
+		p := reflect.New(t.Elem())
+		p.Elem().Set(v)
+		return p, nil
+	}
+	return convert(t, value)
 }
 
 // converter returns the converter for a type.
-func (c *cache) converter(t reflect.Type) Converter {
+func (c *cache) converter(t reflect.Type) ErrorConverter {
 	return c.regconv[t]
 }
 
@@ -212,18 +382,48 @@ type structInfo struct {
 	fields []*fieldInfo
 }
 
-func (i *structInfo) get(alias string) *fieldInfo {
+// get returns the first field with the alias, matched case-insensitively.
+// If preferExact is set, a field with exactly this alias takes precedence.
+func (i *structInfo) get(alias string, preferExact bool) *fieldInfo {
+	var folded *fieldInfo
 	for _, field := range i.fields {
-		if strings.EqualFold(field.alias, alias) {
+		if field.alias == alias {
 			return field
 		}
+		if folded == nil && strings.EqualFold(field.alias, alias) {
+			if !preferExact {
+				return field
+			}
+			folded = field
+		}
 	}
-	return nil
+	return folded
+}
+
+// keyResolves reports whether the dotted key, or its first segments,
+// resolve to the fields of the dotted path of aliases from the struct type
+// t, matching fields as parsePath does.
+func (c *cache) keyResolves(t reflect.Type, key, path string) bool {
+	keys, aliases := strings.Split(key, "."), strings.Split(path, ".")
+	if len(keys) < len(aliases) {
+		return false
+	}
+	for i, alias := range aliases {
+		if t = indirectType(t); t.Kind() != reflect.Struct {
+			return false
+		}
+		field := c.get(t).get(keys[i], c.preferExact)
+		if field == nil || field.alias != alias {
+			return false
+		}
+		t = field.typ
+	}
+	return true
 }
 
 func containsAlias(infos []*structInfo, alias string) bool {
 	for _, info := range infos {
-		if info.get(alias) != nil {
+		if info.get(alias, false) != nil {
 			return true
 		}
 	}
@@ -241,15 +441,46 @@ type fieldInfo struct {
 	// For instance, if the alias is "N" and this field is an embedded field
 	// in a struct "X", canonicalAlias will be "X.N".
 	canonicalAlias string
+	// canonicalName is like canonicalAlias, using the field names.
+	canonicalName string
 	// unmarshalerInfo contains information regarding the
 	// encoding.TextUnmarshaler implementation of the field type.
 	unmarshalerInfo unmarshaler
 	// isSliceOfStructs indicates if the field type is a slice of structs.
 	isSliceOfStructs bool
+	// isMap indicates if the field type is a map, whose entries are
+	// decoded from the keys prefixed by the field alias.
+	isMap bool
 	// isAnonymous indicates whether the field is embedded in the struct.
 	isAnonymous  bool
 	isRequired   bool
 	defaultValue string
+	// defaultVal is the parsed defaultValue, or defaultErr the error
+	// parsing it.
+	defaultVal reflect.Value
+	defaultErr error
+	// maxLen is the maximum length of slices set by the max option, or
+	// maxErr the error parsing it.
+	maxLen int
+	maxErr error
+	// delim is the delimiter splitting the values of slices, if any.
+	delim string
+}
+
+// newDefault returns a copy of the default value of the field, which
+// doesn't share memory with the other copies.
+func (f *fieldInfo) newDefault() reflect.Value {
+	switch f.defaultVal.Kind() {
+	case reflect.Slice:
+		s := reflect.MakeSlice(f.typ, f.defaultVal.Len(), f.defaultVal.Len())
+		reflect.Copy(s, f.defaultVal)
+		return s
+	case reflect.Ptr:
+		p := reflect.New(f.typ.Elem())
+		p.Elem().Set(f.defaultVal.Elem())
+		return p
+	}
+	return f.defaultVal
 }
 
 func (f *fieldInfo) paths(prefix string) []string {
@@ -263,6 +494,24 @@ type pathPart struct {
 	field *fieldInfo
 	path  []string // path to the field: walks structs using field names.
 	index int      // struct index in slices of structs.
+	// mapKey is the key of the entry in map fields.
+	mapKey string
+}
+
+// fieldPath returns the Go path of the field of the parsed path parts, in
+// dotted notation, including the indices in slices of structs.
+func fieldPath(parts []pathPart) string {
+	var b strings.Builder
+	for i, part := range parts {
+		if i > 0 {
+			b.WriteByte('.')
+		}
+		b.WriteString(strings.Join(part.path, "."))
+		if part.index >= 0 {
+			b.WriteString("." + strconv.Itoa(part.index))
+		}
+	}
+	return b.String()
 }
 
 // ----------------------------------------------------------------------------
@@ -275,8 +524,10 @@ func indirectType(typ reflect.Type) reflect.Type {
 }
 
 // fieldAlias parses a field tag to get a field alias.
-func fieldAlias(field reflect.StructField, tagName string) (alias string, options tagOptions) {
-	if tag := field.Tag.Get(tagName); tag != "" {
+//
+// The first non-empty tag among tagNames is used.
+func fieldAlias(field reflect.StructField, tagNames []string) (alias string, options tagOptions) {
+	if tag := lookupTag(field, tagNames); tag != "" {
 		alias, options = parseTag(tag)
 	}
 	if alias == "" {
@@ -285,6 +536,29 @@ func fieldAlias(field reflect.StructField, tagName string) (alias string, option
 	return alias, options
 }
 
+// sideTags are the tags of fields which are encoded into other parts of a
+// request than its query or form body.
+var sideTags = []string{"header", "cookie", "path"}
+
+// hasSideTag reports whether field has a side tag but none of tagNames.
+// Such fields are ignored when encoding or decoding query parameters.
+func hasSideTag(field reflect.StructField, tagNames []string) bool {
+	if lookupTag(field, tagNames) != "" {
+		return false
+	}
+	return lookupTag(field, sideTags) != ""
+}
+
+// lookupTag returns the first non-empty tag of field among tagNames.
+func lookupTag(field reflect.StructField, tagNames []string) string {
+	for _, name := range tagNames {
+		if tag := field.Tag.Get(name); tag != "" {
+			return tag
+		}
+	}
+	return ""
+}
+
 // tagOptions is the string following a comma in a struct field's tag, or
 // the empty string. It does not include the leading comma.
 type tagOptions []string
@@ -306,12 +580,87 @@ func (o tagOptions) Contains(option string) bool {
 	return false
 }
 
+// getOptionValue returns the value of an option written as "name=value".
+func (o tagOptions) getOptionValue(name string) (string, bool) {
+	for _, s := range o {
+		if v, ok := strings.CutPrefix(s, name+"="); ok {
+			return v, true
+		}
+	}
+	return "", false
+}
+
+// getDefaultOptionValue returns the value of the "default" option, written
+// either as "default:value" or "default=value".
 func (o tagOptions) getDefaultOptionValue() string {
 	for _, s := range o {
-		if strings.HasPrefix(s, "default:") {
-			return strings.Split(s, ":")[1]
+		if v, ok := strings.CutPrefix(s, "default:"); ok {
+			return v
+		}
+		if v, ok := strings.CutPrefix(s, "default="); ok {
+			return v
 		}
 	}
 
 	return ""
 }
+
+// delimiters maps the delimiter tag options to their delimiter.
+var delimiters = map[string]string{
+	"comma": ",",
+	"space": " ",
+	"pipe":  "|",
+}
+
+// getDelimiter returns the delimiter of the slice elements set by the
+// "comma", "space", "pipe" or "delim=" options, or "" if there is none.
+func (o tagOptions) getDelimiter() string {
+	if d, ok := o.getOptionValue("delim"); ok {
+		return d
+	}
+	for _, s := range o {
+		if d, ok := delimiters[s]; ok {
+			return d
+		}
+	}
+	return ""
+}
+
+// joinDelim joins the values with delim, escaping the backslashes and the
+// delimiters in the values with a backslash.
+func joinDelim(values []string, delim string) string {
+	escape := strings.NewReplacer(`\`, `\\`, delim, `\`+delim)
+	var b strings.Builder
+	for i, v := range values {
+		if i > 0 {
+			b.WriteString(delim)
+		}
+		escape.WriteString(&b, v)
+	}
+	return b.String()
+}
+
+// splitDelim splits s around delim, unescaping the delimiters and the
+// backslashes escaped by joinDelim. Other backslashes are kept as is.
+func splitDelim(s, delim string) []string {
+	var values []string
+	var b strings.Builder
+	for i := 0; i < len(s); {
+		switch {
+		case strings.HasPrefix(s[i:], `\`+delim):
+			b.WriteString(delim)
+			i += 1 + len(delim)
+		case strings.HasPrefix(s[i:], `\\`):
+			b.WriteByte('\\')
+			i += 2
+		case strings.HasPrefix(s[i:], delim):
+			values = append(values, b.String())
+			b.Reset()
+			i += len(delim)
+		default:
+			b.WriteByte(s[i])
+			i++
+		}
+	}
+	return append(values, b.String())
+}
@@ -0,0 +1,101 @@
+package schema
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+)
+
+// Check walks the struct type of sample, which may be a struct, a pointer
+// to a struct or a reflect.Type, and reports every field that would fail
+// to encode: fields without an encoder, unless SetSkipUnsupported is set,
+// invalid tag options and fields encoded under the same key as another
+// field.
+//
+// It is meant to validate request structs at startup. The returned error
+// is a MultiError keyed by field path, or nil if all fields can be encoded.
+func (e *Encoder) Check(sample any) error {
+	t, ok := sample.(reflect.Type)
+	if !ok {
+		t = reflect.TypeOf(sample)
+	}
+	if t != nil && t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	if t == nil || t.Kind() != reflect.Struct {
+		return errors.New("schema: interface must be a struct")
+	}
+	s := &encodeState{ctx: context.Background(), opts: e.opts}
+	s.regenc, s.plans = e.encoders(s.ctx)
+	c := &checker{e: e, s: s, keys: map[string]string{}, errs: MultiError{}}
+	e.walkPlan(s, t, "", "", map[reflect.Type]bool{}, c.check)
+	if len(c.errs) > 0 {
+		return c.errs
+	}
+	return nil
+}
+
+// checker holds the state of Encoder.Check.
+type checker struct {
+	e *Encoder
+	s *encodeState
+	// keys maps the encoded keys to the path of the field encoding them.
+	keys map[string]string
+	errs MultiError
+}
+
+// check reports the errors of the field f of the struct type t, visited by
+// walkPlan.
+func (c *checker) check(t reflect.Type, f *fieldPlan, key, path string, nested bool) {
+	if f.alias == "-" {
+		return
+	}
+	if f.orderErr != nil {
+		c.errs[path] = f.orderErr
+	}
+	if f.slice != "" && !f.validSlice {
+		c.errs[path] = fmt.Errorf("schema: invalid slice key style %q for %v", f.slice, path)
+	}
+	if f.duplicate != "" && !f.validDuplicate {
+		c.errs[path] = fmt.Errorf("schema: invalid duplicate key mode %q for %v", f.duplicate, path)
+	}
+	if f.sep != "" && !f.validSep {
+		c.errs[path] = fmt.Errorf("schema: invalid nested key separator %q for %v", f.sep, path)
+	}
+	if nested {
+		return
+	}
+	if f.file {
+		c.addKey(key, path)
+		return
+	}
+	enc, elemEnc, _ := f.encoders(c.e, c.s)
+	switch f.typ.Kind() {
+	case reflect.Slice:
+		if enc == nil {
+			enc = elemEnc
+		}
+	case reflect.Map:
+		if enc == nil && f.keyEnc != nil {
+			enc = elemEnc
+		}
+	}
+	if enc == nil {
+		if !c.e.skipUnsupported {
+			c.errs[path] = UnsupportedTypeError{Field: path, Type: f.typ}
+		}
+		return
+	}
+	c.addKey(key, path)
+}
+
+// addKey records that the field at path is encoded as key, reporting an
+// error if another field is encoded as the same key.
+func (c *checker) addKey(key, path string) {
+	if other, ok := c.keys[key]; ok {
+		c.errs[path] = KeyConflictError{Key: key, Fields: [2]string{other, path}}
+		return
+	}
+	c.keys[key] = path
+}
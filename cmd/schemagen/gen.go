@@ -0,0 +1,503 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"go/ast"
+	"go/format"
+	"go/parser"
+	"go/token"
+	"math"
+	"os"
+	"path/filepath"
+	"reflect"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/stormyyd/schema"
+)
+
+const schemaPath = "github.com/stormyyd/schema"
+
+// sideTags are the tags of fields encoded by schema outside of the query,
+// which the generated methods skip like the reflection based ones.
+var sideTags = []string{"header", "cookie", "path"}
+
+// kind describes a supported basic type.
+type kind struct {
+	// parse is the strconv function parsing it, and bits its bit size.
+	parse string
+	bits  int
+	// format formats a value of the type, %s being the value.
+	format string
+	// scalar is set for the types whose first value must not be empty to
+	// satisfy the required option, as checked by the Decoder.
+	scalar bool
+}
+
+var kinds = map[string]kind{
+	"string":  {format: "%s", scalar: true},
+	"bool":    {parse: "ParseBool", format: "strconv.FormatBool(%s)", scalar: true},
+	"int":     {parse: "ParseInt", bits: 0, format: "strconv.FormatInt(int64(%s), 10)", scalar: true},
+	"int8":    {parse: "ParseInt", bits: 8, format: "strconv.FormatInt(int64(%s), 10)", scalar: true},
+	"int16":   {parse: "ParseInt", bits: 16, format: "strconv.FormatInt(int64(%s), 10)"},
+	"int32":   {parse: "ParseInt", bits: 32, format: "strconv.FormatInt(int64(%s), 10)", scalar: true},
+	"int64":   {parse: "ParseInt", bits: 64, format: "strconv.FormatInt(%s, 10)", scalar: true},
+	"uint":    {parse: "ParseUint", bits: 0, format: "strconv.FormatUint(uint64(%s), 10)"},
+	"uint8":   {parse: "ParseUint", bits: 8, format: "strconv.FormatUint(uint64(%s), 10)", scalar: true},
+	"uint16":  {parse: "ParseUint", bits: 16, format: "strconv.FormatUint(uint64(%s), 10)", scalar: true},
+	"uint32":  {parse: "ParseUint", bits: 32, format: "strconv.FormatUint(uint64(%s), 10)", scalar: true},
+	"uint64":  {parse: "ParseUint", bits: 64, format: "strconv.FormatUint(%s, 10)", scalar: true},
+	"float32": {parse: "ParseFloat", bits: 32, format: "strconv.FormatFloat(float64(%s), 'f', 6, 32)", scalar: true},
+	"float64": {parse: "ParseFloat", bits: 64, format: "strconv.FormatFloat(%s, 'f', 6, 64)", scalar: true},
+}
+
+// field is a struct field the methods are generated for.
+type field struct {
+	name  string
+	alias string
+	typ   string
+	ptr   bool
+	slice bool
+
+	omitEmpty bool
+	required  bool
+	// defaults holds the untyped Go constants of the default value, and
+	// def the default value as written in the tag.
+	def      string
+	defaults []string
+}
+
+// structType is a struct the methods are generated for.
+type structType struct {
+	name   string
+	fields []field
+}
+
+// generate returns the generated source for the struct types names of the
+// package in dir, ignoring the file output.
+func generate(dir string, names []string, output string) ([]byte, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	fset := token.NewFileSet()
+	pkg := ""
+	specs := map[string]*ast.TypeSpec{}
+	for _, entry := range entries {
+		name := entry.Name()
+		if entry.IsDir() || !strings.HasSuffix(name, ".go") || strings.HasSuffix(name, "_test.go") || name == output {
+			continue
+		}
+		file, err := parser.ParseFile(fset, filepath.Join(dir, name), nil, parser.SkipObjectResolution)
+		if err != nil {
+			return nil, err
+		}
+		pkg = file.Name.Name
+		ast.Inspect(file, func(n ast.Node) bool {
+			if spec, ok := n.(*ast.TypeSpec); ok {
+				specs[spec.Name.Name] = spec
+			}
+			return true
+		})
+	}
+
+	var types []structType
+	for _, name := range names {
+		spec, ok := specs[name]
+		if !ok {
+			return nil, fmt.Errorf("type %s not found in %s", name, dir)
+		}
+		st, ok := spec.Type.(*ast.StructType)
+		if !ok || spec.TypeParams != nil {
+			return nil, fmt.Errorf("type %s is not a struct", name)
+		}
+		t, err := parseStruct(name, st)
+		if err != nil {
+			return nil, err
+		}
+		types = append(types, t)
+	}
+
+	g := &generator{imports: map[string]bool{}}
+	for _, t := range types {
+		g.encoder(t)
+		g.decoder(t)
+	}
+	return g.source(pkg)
+}
+
+// parseStruct returns the supported fields of the struct st.
+func parseStruct(name string, st *ast.StructType) (structType, error) {
+	t := structType{name: name}
+	for _, f := range st.Fields.List {
+		tag := ""
+		if f.Tag != nil {
+			tag, _ = strconv.Unquote(f.Tag.Value)
+		}
+		if len(f.Names) == 0 {
+			return t, fmt.Errorf("%s: embedded fields are not supported", name)
+		}
+		for _, n := range f.Names {
+			fld, ok, err := parseField(n.Name, f.Type, reflect.StructTag(tag))
+			if err != nil {
+				return t, fmt.Errorf("%s.%s: %v", name, n.Name, err)
+			}
+			if ok {
+				t.fields = append(t.fields, fld)
+			}
+		}
+	}
+	return t, nil
+}
+
+// parseField returns the field name of type expr with the given tag, and
+// false if it is skipped.
+func parseField(name string, expr ast.Expr, tag reflect.StructTag) (field, bool, error) {
+	value := tag.Get("schema")
+	if value == "" && slices.ContainsFunc(sideTags, func(t string) bool { return tag.Get(t) != "" }) {
+		return field{}, false, nil
+	}
+	options := strings.Split(value, ",")
+	f := field{name: name, alias: options[0]}
+	if f.alias == "-" {
+		return f, false, nil
+	}
+	if !token.IsExported(name) {
+		return f, false, fmt.Errorf(`unexported fields are not supported; tag it schema:"-"`)
+	}
+	if f.alias == "" {
+		f.alias = name
+	}
+
+	switch t := expr.(type) {
+	case *ast.Ident:
+		f.typ = t.Name
+	case *ast.StarExpr:
+		if id, ok := t.X.(*ast.Ident); ok {
+			f.typ, f.ptr = id.Name, true
+		}
+	case *ast.ArrayType:
+		if id, ok := t.Elt.(*ast.Ident); ok && t.Len == nil {
+			f.typ, f.slice = id.Name, true
+		}
+	}
+	if _, ok := kinds[f.typ]; !ok {
+		return f, false, fmt.Errorf("type %s is not supported", types(expr))
+	}
+
+	for _, o := range options[1:] {
+		switch {
+		case o == "omitempty":
+			f.omitEmpty = true
+		case o == "required":
+			f.required = true
+		case strings.HasPrefix(o, "default=") || strings.HasPrefix(o, "default:"):
+			f.def = o[len("default="):]
+		case o == "":
+		default:
+			return f, false, fmt.Errorf("option %q is not supported", o)
+		}
+	}
+	if f.def != "" {
+		values := []string{f.def}
+		if f.slice {
+			values = strings.Split(f.def, "|")
+		}
+		for _, v := range values {
+			lit, err := literal(f.typ, v)
+			if err != nil {
+				return f, false, fmt.Errorf("invalid default %q: %v", v, err)
+			}
+			f.defaults = append(f.defaults, lit)
+		}
+	}
+	return f, true, nil
+}
+
+// types returns the source of the type expression expr.
+func types(expr ast.Expr) string {
+	var buf bytes.Buffer
+	format.Node(&buf, token.NewFileSet(), expr)
+	return buf.String()
+}
+
+// literal returns the untyped Go constant of the value s of type typ,
+// parsed as by the Decoder.
+func literal(typ, s string) (string, error) {
+	k := kinds[typ]
+	switch k.parse {
+	case "":
+		return strconv.Quote(s), nil
+	case "ParseBool":
+		v, err := schema.ParseBool(s)
+		return strconv.FormatBool(v), err
+	case "ParseInt":
+		v, err := strconv.ParseInt(s, 10, k.bits)
+		return strconv.FormatInt(v, 10), err
+	case "ParseUint":
+		v, err := strconv.ParseUint(s, 10, k.bits)
+		return strconv.FormatUint(v, 10), err
+	default:
+		v, err := strconv.ParseFloat(s, k.bits)
+		if err == nil && (math.IsInf(v, 0) || math.IsNaN(v)) {
+			err = fmt.Errorf("not a finite number")
+		}
+		return strconv.FormatFloat(v, 'g', -1, k.bits), err
+	}
+}
+
+// generator writes the generated methods.
+type generator struct {
+	buf     bytes.Buffer
+	imports map[string]bool
+}
+
+func (g *generator) printf(format string, args ...any) {
+	fmt.Fprintf(&g.buf, format, args...)
+}
+
+// source returns the formatted source of the generated file.
+func (g *generator) source(pkg string) ([]byte, error) {
+	var out bytes.Buffer
+	fmt.Fprintf(&out, "// Code generated by schemagen; DO NOT EDIT.\n\npackage %s\n\nimport (\n", pkg)
+	paths := make([]string, 0, len(g.imports))
+	for path := range g.imports {
+		paths = append(paths, path)
+	}
+	slices.Sort(paths)
+	for _, path := range paths {
+		fmt.Fprintf(&out, "\t%q\n", path)
+	}
+	fmt.Fprintf(&out, "\n\t%q\n)\n", schemaPath)
+	out.Write(g.buf.Bytes())
+	return format.Source(out.Bytes())
+}
+
+// format returns the expression formatting the value v of field f.
+func (g *generator) format(f field, v string) string {
+	k := kinds[f.typ]
+	if k.parse != "" {
+		g.imports["strconv"] = true
+	}
+	return fmt.Sprintf(k.format, v)
+}
+
+// encoder writes the EncodeSchema method of t.
+func (g *generator) encoder(t structType) {
+	g.printf("\n// EncodeSchema encodes x into values. It is used by schema.Encoder instead of\n// reflection.\n")
+	g.printf("func (x %s) EncodeSchema(values *schema.UrlValues) error {\n", t.name)
+	g.printf("errs := schema.MultiError{}\n")
+	for _, f := range t.fields {
+		v := "x." + f.name
+		alias := strconv.Quote(f.alias)
+		var zero, nonZero string
+		switch {
+		case f.slice:
+			zero, nonZero = fmt.Sprintf("len(%s) == 0", v), fmt.Sprintf("len(%s) > 0", v)
+		case f.ptr:
+			zero, nonZero = v+" == nil", v+" != nil"
+		case f.typ == "string":
+			zero, nonZero = v+` == ""`, v+` != ""`
+		case f.typ == "bool":
+			zero, nonZero = "!"+v, v
+		default:
+			zero, nonZero = v+" == 0", v+" != 0"
+		}
+
+		emit := func(deref bool) {
+			switch {
+			case f.slice:
+				g.printf("for _, v := range %s {\nvalues.Add(%s, %s)\n}\n", v, alias, g.format(f, "v"))
+			case f.ptr && !deref:
+				g.printf("if %s == nil {\nvalues.Add(%s, \"null\")\n} else {\nvalues.Add(%s, %s)\n}\n", v, alias, alias, g.format(f, "*"+v))
+			case f.ptr:
+				g.printf("values.Add(%s, %s)\n", alias, g.format(f, "*"+v))
+			default:
+				g.printf("values.Add(%s, %s)\n", alias, g.format(f, v))
+			}
+		}
+
+		switch {
+		case f.required:
+			g.printf("if %s {\nerrs[%q] = %s\n} else {\n", zero, f.name, g.missingFieldError(f))
+			emit(true)
+			g.printf("}\n")
+		case f.def != "":
+			g.printf("if %s {\n", zero)
+			values := []string{f.def}
+			if f.slice {
+				values = strings.Split(f.def, "|")
+			}
+			for _, d := range values {
+				g.printf("values.Add(%s, %s)\n", alias, strconv.Quote(d))
+			}
+			g.printf("} else {\n")
+			emit(true)
+			g.printf("}\n")
+		case f.omitEmpty && !f.slice:
+			g.printf("if %s {\n", nonZero)
+			emit(true)
+			g.printf("}\n")
+		default:
+			emit(false)
+		}
+	}
+	g.printf("if len(errs) > 0 {\nreturn errs\n}\nreturn nil\n}\n")
+}
+
+// parse writes the code parsing the string in into the type of f, calling
+// set with the parsed value, or running fail if it is not empty.
+func (g *generator) parse(f field, in string, set func(v string) string, fail string) {
+	k := kinds[f.typ]
+	switch k.parse {
+	case "":
+		g.printf("%s\n", set(in))
+		return
+	case "ParseBool":
+		g.printf("if v, err := schema.ParseBool(%s); err == nil {\n%s\n", in, set("v"))
+	case "ParseFloat":
+		g.printf("if v, err := strconv.ParseFloat(%s, %d); err == nil {\n%s\n", in, k.bits, set(convert(f.typ, "float64", "v")))
+	case "ParseInt":
+		g.printf("if v, err := strconv.ParseInt(%s, 10, %d); err == nil {\n%s\n", in, k.bits, set(convert(f.typ, "int64", "v")))
+	default:
+		g.printf("if v, err := strconv.ParseUint(%s, 10, %d); err == nil {\n%s\n", in, k.bits, set(convert(f.typ, "uint64", "v")))
+	}
+	if fail == "" {
+		g.printf("}\n")
+		return
+	}
+	g.printf("} else {\n%s\n}\n", fail)
+}
+
+// convert returns the expression converting v of type from to typ. The
+// default types of untyped constants, string and bool, are not converted.
+func convert(typ, from, v string) string {
+	if typ == from || (from == "" && (typ == "string" || typ == "bool")) {
+		return v
+	}
+	return typ + "(" + v + ")"
+}
+
+// conversionError returns the expression of a schema.ConversionError for
+// the type of f at index, failing to convert the string value.
+func (g *generator) conversionError(f field, index, value string) string {
+	g.imports["reflect"] = true
+	zero := f.typ + "(0)"
+	if f.typ == "bool" {
+		zero = "false"
+	}
+	return fmt.Sprintf("schema.ConversionError{Key: key, Field: %q, Type: reflect.TypeOf(%s), Index: %s, Value: %s}", f.name, zero, index, value)
+}
+
+// missingFieldError returns the expression of a schema.MissingFieldError
+// for the required field f.
+func (g *generator) missingFieldError(f field) string {
+	g.imports["reflect"] = true
+	return fmt.Sprintf("schema.MissingFieldError{Key: %q, Field: %q, Type: reflect.TypeOf(x.%s)}", f.alias, f.name, f.name)
+}
+
+// decoder writes the DecodeSchema method of t.
+func (g *generator) decoder(t structType) {
+	g.printf("\n// DecodeSchema decodes src into x. It is used by schema.Decoder instead of\n// reflection.\n")
+	g.printf("func (x *%s) DecodeSchema(src map[string][]string) error {\n", t.name)
+	g.printf("errs := schema.MultiError{}\n")
+	if len(t.fields) == 0 {
+		g.printf("for key := range src {\n")
+	} else {
+		g.imports["strings"] = true
+		g.printf("for key, values := range src {\n")
+	}
+	g.printf("switch {\n")
+	for _, f := range t.fields {
+		v := "x." + f.name
+		g.printf("case strings.EqualFold(key, %s):\n", strconv.Quote(f.alias))
+		if f.slice {
+			g.decodeSlice(f, v)
+			continue
+		}
+		g.printf("val := \"\"\nif len(values) > 0 {\nval = values[len(values)-1]\n}\n")
+		target := v
+		if f.ptr {
+			g.printf("if %s == nil {\n%s = new(%s)\n}\n", v, v, f.typ)
+			target = "*" + v
+		}
+		g.printf("if val != \"\" {\n")
+		g.parse(f, "val", func(p string) string { return target + " = " + p }, "errs[key] = "+g.conversionError(f, "-1", "val"))
+		g.printf("}\n")
+	}
+	g.printf("default:\nerrs[key] = schema.UnknownKeyError{Key: key}\n}\n}\n")
+
+	for _, f := range t.fields {
+		if f.def == "" || f.required {
+			continue
+		}
+		v := "x." + f.name
+		switch {
+		case f.slice:
+			g.printf("if %s == nil {\n%s = []%s{%s}\n}\n", v, v, f.typ, strings.Join(f.defaults, ", "))
+		case f.ptr:
+			g.printf("if %s == nil {\nv := %s\n%s = &v\n}\n", v, convert(f.typ, "", f.defaults[0]), v)
+		default:
+			g.printf("if %s {\n%s = %s\n}\n", g.isZero(f, v), v, f.defaults[0])
+		}
+	}
+
+	for i, f := range t.fields {
+		if !f.required {
+			continue
+		}
+		alias := strconv.Quote(f.alias)
+		// A field whose alias matches the one of a field declared before
+		// it ignoring case is never set, so it is always missing.
+		if slices.ContainsFunc(t.fields[:i], func(prev field) bool { return strings.EqualFold(prev.alias, f.alias) }) {
+			g.printf("if errs[%s] == nil {\nerrs[%s] = %s\n}\n", alias, alias, g.missingFieldError(f))
+			continue
+		}
+		empty := "len(values) > 0"
+		if kinds[f.typ].scalar && !f.ptr && !f.slice {
+			empty += ` && values[0] != ""`
+		}
+		g.printf("if errs[%s] == nil && !func() bool {\n", alias)
+		g.printf("for key, values := range src {\nif i := strings.IndexAny(key, \".[\"); i >= 0 {\nkey = key[:i]\n}\n")
+		g.printf("if strings.EqualFold(key, %s) && %s {\nreturn true\n}\n}\nreturn false\n}() {\n", alias, empty)
+		g.printf("errs[%s] = %s\n}\n", alias, g.missingFieldError(f))
+	}
+	g.printf("if len(errs) > 0 {\nreturn errs\n}\nreturn nil\n}\n")
+}
+
+// isZero returns the expression reporting whether v, of the non-pointer
+// and non-slice type of f, is the zero value as by reflect.Value.IsZero.
+func (g *generator) isZero(f field, v string) string {
+	switch f.typ {
+	case "string":
+		return v + ` == ""`
+	case "bool":
+		return "!" + v
+	case "float32":
+		g.imports["math"] = true
+		return fmt.Sprintf("math.Float32bits(%s) == 0", v)
+	case "float64":
+		g.imports["math"] = true
+		return fmt.Sprintf("math.Float64bits(%s) == 0", v)
+	}
+	return v + " == 0"
+}
+
+// decodeSlice writes the decoding of the slice field v, splitting values
+// on commas as the Decoder does.
+func (g *generator) decodeSlice(f field, v string) {
+	if f.typ == "string" {
+		g.printf("items := make([]string, 0, len(values))\nfor _, value := range values {\nif value != \"\" {\nitems = append(items, value)\n}\n}\n%s = items\n", v)
+		return
+	}
+	appendItem := func(p string) string { return "items = append(items, " + p + ")" }
+	g.printf("if err := func() error {\nitems := make([]%s, 0, len(values))\n", f.typ)
+	g.printf("for i, value := range values {\nif value == \"\" {\ncontinue\n}\n")
+	g.parse(f, "value", func(p string) string { return appendItem(p) + "\ncontinue" }, "")
+	g.printf("if !strings.Contains(value, \",\") {\nreturn %s\n}\n", g.conversionError(f, "i", "value"))
+	g.printf("for _, value := range strings.Split(value, \",\") {\nif value == \"\" {\ncontinue\n}\n")
+	g.parse(f, "value", appendItem, "return "+g.conversionError(f, "i", "value"))
+	g.printf("}\n}\n%s = items\nreturn nil\n}(); err != nil {\nerrs[key] = err\n}\n", v)
+}
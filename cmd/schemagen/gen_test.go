@@ -0,0 +1,74 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestGenerateUpToDate(t *testing.T) {
+	dir := filepath.Join("..", "..", "internal", "gentest")
+	got, err := generate(dir, []string{"Query", "Empty"}, "query_schema.go")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want, err := os.ReadFile(filepath.Join(dir, "query_schema.go"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(got, want) {
+		t.Error("internal/gentest/query_schema.go is out of date; run go generate ./...")
+	}
+}
+
+func TestGenerateErrors(t *testing.T) {
+	tests := []struct {
+		src  string
+		want string
+	}{
+		{"type T struct{ A map[string]string }", "type map[string]string is not supported"},
+		{"type T struct{ A []*int }", "type []*int is not supported"},
+		{"type T struct{ time.Time }", "embedded fields are not supported"},
+		{"type T struct{ a int }", "unexported fields are not supported"},
+		{"type T struct{ A int `schema:\"a,omitzero\"` }", `option "omitzero" is not supported`},
+		{"type T struct{ A int8 `schema:\"a,default=300\"` }", `invalid default "300"`},
+		{"type T struct{ A []bool `schema:\"a,default=true|x\"` }", `invalid default "x"`},
+		{"type T int", "type T is not a struct"},
+		{"type U struct{}", "type T not found"},
+	}
+	for _, tc := range tests {
+		dir := t.TempDir()
+		src := "package p\n\nimport \"time\"\n\nvar _ time.Time\n\n" + tc.src + "\n"
+		if err := os.WriteFile(filepath.Join(dir, "p.go"), []byte(src), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		_, err := generate(dir, []string{"T"}, "t_schema.go")
+		if err == nil || !strings.Contains(err.Error(), tc.want) {
+			t.Errorf("%s: got error %v, want %q", tc.src, err, tc.want)
+		}
+	}
+}
+
+func TestGenerateSkipsFields(t *testing.T) {
+	dir := t.TempDir()
+	src := "package p\n\ntype T struct {\n" +
+		"\tA int `schema:\"a\"`\n" +
+		"\tb int `schema:\"-\"`\n" +
+		"\tC map[string]int `schema:\"-\"`\n" +
+		"\tD string `header:\"X-D\"`\n" +
+		"}\n"
+	if err := os.WriteFile(filepath.Join(dir, "p.go"), []byte(src), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	out, err := generate(dir, []string{"T"}, "t_schema.go")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, name := range []string{"x.b", "x.C", "x.D"} {
+		if bytes.Contains(out, []byte(name)) {
+			t.Errorf("generated code uses skipped field %s", name)
+		}
+	}
+}
@@ -0,0 +1,58 @@
+// Command schemagen generates reflection-free EncodeSchema and DecodeSchema
+// methods for structs, which schema.Encoder and schema.Decoder use instead
+// of reflection when their settings allow it.
+//
+// It is meant to be run by go generate, next to the struct definitions:
+//
+//	//go:generate go run github.com/stormyyd/schema/cmd/schemagen -type Query,Filter
+//
+// The generated code is written to <type>_schema.go, named after the first
+// type, unless -output is given.
+//
+// Only fields of type string, bool, the sized and unsized integers and
+// floats, pointers to them, and slices of them are supported, with the
+// omitempty, required and default tag options. Fields ignored with "-", or
+// with only a "header", "cookie" or "path" tag, are skipped. schemagen fails
+// on other fields, so the generated code always behaves like the reflection
+// based encoding; keep using the latter for such structs.
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+func main() {
+	types := flag.String("type", "", "comma-separated list of struct type names; required")
+	output := flag.String("output", "", "output file name; default <dir>/<type>_schema.go")
+	flag.Usage = func() {
+		fmt.Fprintf(os.Stderr, "Usage: schemagen -type T[,T...] [-output file] [dir]\n")
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+	if *types == "" || flag.NArg() > 1 {
+		flag.Usage()
+		os.Exit(2)
+	}
+	dir := "."
+	if flag.NArg() == 1 {
+		dir = flag.Arg(0)
+	}
+	names := strings.Split(*types, ",")
+	if *output == "" {
+		*output = filepath.Join(dir, strings.ToLower(names[0])+"_schema.go")
+	}
+
+	src, err := generate(dir, names, filepath.Base(*output))
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "schemagen: %v\n", err)
+		os.Exit(1)
+	}
+	if err := os.WriteFile(*output, src, 0o644); err != nil {
+		fmt.Fprintf(os.Stderr, "schemagen: %v\n", err)
+		os.Exit(1)
+	}
+}
@@ -0,0 +1,148 @@
+package schema
+
+import (
+	"cmp"
+	"maps"
+	"reflect"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// Codec is an Encoder and a Decoder configured together, so that the
+// values it encodes decode back into the same struct. Its settings are
+// applied to both sides and should be set before its first use.
+type Codec struct {
+	enc *Encoder
+	dec *Decoder
+}
+
+// NewCodec returns a new Codec. Nested struct fields are encoded in the
+// NestedKeyDotted style, and nil pointer fields are omitted, as if tagged
+// "omitnil", so that they decode back as nil.
+func NewCodec() *Codec {
+	c := &Codec{enc: NewEncoder(), dec: NewDecoder()}
+	c.enc.SetNestedKeyStyle(NestedKeyDotted)
+	c.enc.omitNil = true
+	return c
+}
+
+// SetAliasTags sets an ordered list of tags used to locate custom field
+// aliases. See Encoder.SetAliasTags.
+func (c *Codec) SetAliasTags(tags ...string) {
+	c.enc.SetAliasTags(tags...)
+	c.dec.SetAliasTags(tags...)
+}
+
+// SetNamingStrategy sets the function used to derive key names from field
+// names for fields without an alias in their tag.
+func (c *Codec) SetNamingStrategy(naming NamingStrategy) {
+	c.enc.SetNamingStrategy(naming)
+	c.dec.SetNamingStrategy(naming)
+}
+
+// SetNestedKeyStyle sets how the keys of nested struct fields are built.
+// The keys of NestedKeyFlat don't name the nested struct and can't be
+// decoded, so it is replaced by NestedKeyDotted, the default style.
+func (c *Codec) SetNestedKeyStyle(style NestedKeyStyle) {
+	if style == NestedKeyFlat {
+		style = NestedKeyDotted
+	}
+	c.enc.SetNestedKeyStyle(style)
+}
+
+// SetSliceKeyStyle sets how the keys of slice elements are built. The
+// default style is SliceKeyRepeat.
+func (c *Codec) SetSliceKeyStyle(style SliceKeyStyle) {
+	c.enc.SetSliceKeyStyle(style)
+}
+
+// RegisterType registers the functions encoding and decoding the values
+// of the type of value.
+func (c *Codec) RegisterType(value any, encode func(reflect.Value) string, decode ErrorConverter) {
+	c.enc.RegisterEncoder(value, encode)
+	c.dec.RegisterErrorConverter(value, decode)
+}
+
+// RegisterEnum registers the values allowed for the type of value. They
+// are checked when decoding, and when encoding if SetCheckEnums is set.
+func (c *Codec) RegisterEnum(value any, allowed ...string) {
+	c.enc.RegisterEnum(value, allowed...)
+	c.dec.RegisterEnum(value, allowed...)
+}
+
+// SetCheckEnums controls whether encoding fails when a value of a type
+// registered with RegisterEnum isn't allowed. See Encoder.SetCheckEnums.
+func (c *Codec) SetCheckEnums(check bool) {
+	c.enc.SetCheckEnums(check)
+}
+
+// Encode encodes the struct src. See Encoder.EncodeValues.
+func (c *Codec) Encode(src any) (UrlValues, error) {
+	return c.enc.EncodeValues(src)
+}
+
+// Decode decodes src into the struct pointed to by dst. Besides the keys
+// accepted by Decoder.Decode, the keys of slice elements may be in any
+// SliceKeyStyle, e.g. "tag[]" or "tag[0]". Keys of map entries, such as
+// "scores[3]" for a map field "scores", are left as they are.
+func (c *Codec) Decode(dst any, src map[string][]string) error {
+	return c.dec.Decode(dst, sliceKeys(src, c.mapEntries(dst)))
+}
+
+// mapEntries returns a function reporting whether a key sets an entry of a
+// map field of the struct pointed to by dst, or nil if dst isn't a pointer
+// to a struct.
+func (c *Codec) mapEntries(dst any) func(key string) bool {
+	t := reflect.TypeOf(dst)
+	if t == nil || t.Kind() != reflect.Ptr || t.Elem().Kind() != reflect.Struct {
+		return nil
+	}
+	return func(key string) bool {
+		parts, err := c.dec.cache.parsePath(dottedKey(key), t.Elem())
+		return err == nil && parts[len(parts)-1].mapKey != ""
+	}
+}
+
+// sliceKeys returns src with the keys of slice elements in the
+// SliceKeyBrackets and SliceKeyIndexed styles, e.g. "tag[]" and "tag[0]",
+// replaced by the key of the slice, with the indexed elements in index
+// order. Keys for which isMapEntry returns true are left as they are. It
+// returns src itself if no key is replaced.
+func sliceKeys(src map[string][]string, isMapEntry func(key string) bool) map[string][]string {
+	type element struct {
+		key   string
+		index int
+	}
+	var elements []element
+	for key := range src {
+		i := strings.LastIndexByte(key, '[')
+		if i <= 0 || !strings.HasSuffix(key, "]") {
+			continue
+		}
+		if isMapEntry != nil && isMapEntry(key) {
+			continue
+		}
+		index := 0
+		if s := key[i+1 : len(key)-1]; s != "" {
+			var err error
+			if index, err = strconv.Atoi(s); err != nil || index < 0 {
+				continue
+			}
+		}
+		elements = append(elements, element{key, index})
+	}
+	if len(elements) == 0 {
+		return src
+	}
+	slices.SortFunc(elements, func(a, b element) int {
+		return cmp.Or(cmp.Compare(a.index, b.index), strings.Compare(a.key, b.key))
+	})
+	dst := maps.Clone(src)
+	for _, e := range elements {
+		key := e.key[:strings.LastIndexByte(e.key, '[')]
+		dst[key] = append(slices.Clip(dst[key]), src[e.key]...)
+		delete(dst, e.key)
+	}
+	return dst
+}
@@ -0,0 +1,109 @@
+package schema
+
+import (
+	"reflect"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+type codecPoint struct{ X, Y int }
+
+type codecParams struct {
+	UserID int
+	Tags   []string
+	Filter struct {
+		MinPrice float64
+	}
+	Origin codecPoint
+	Page   *int
+	Scores map[int]string
+	Addr   *codecAddress
+}
+
+type codecAddress struct {
+	City string
+	Zip  *int
+}
+
+func TestCodecRoundTrip(t *testing.T) {
+	page := 2
+	src := codecParams{UserID: 1, Tags: []string{"a", "b", "c"}, Origin: codecPoint{1, 2}, Page: &page}
+	src.Scores = map[int]string{3: "12", 0: "7"}
+	src.Filter.MinPrice = 9.5
+	src.Addr = &codecAddress{City: "Lyon"}
+	nils := codecParams{UserID: 1}
+
+	for _, nested := range []NestedKeyStyle{NestedKeyFlat, NestedKeyDotted, NestedKeyBrackets} {
+		for _, slice := range []SliceKeyStyle{SliceKeyRepeat, SliceKeyBrackets, SliceKeyIndexed} {
+			c := NewCodec()
+			c.SetNamingStrategy(SnakeCase)
+			c.SetNestedKeyStyle(nested)
+			c.SetSliceKeyStyle(slice)
+			c.RegisterType(codecPoint{}, func(v reflect.Value) string {
+				p := v.Interface().(codecPoint)
+				return strconv.Itoa(p.X) + "," + strconv.Itoa(p.Y)
+			}, func(s string) (reflect.Value, error) {
+				x, y, _ := strings.Cut(s, ",")
+				var p codecPoint
+				var err error
+				if p.X, err = strconv.Atoi(x); err == nil {
+					p.Y, err = strconv.Atoi(y)
+				}
+				return reflect.ValueOf(p), err
+			})
+
+			for _, src := range []codecParams{src, nils} {
+				values, err := c.Encode(src)
+				noError(t, err)
+				var dst codecParams
+				if err := c.Decode(&dst, values.Values()); err != nil {
+					t.Errorf("nested %v, slice %v: decoding %v: %v", nested, slice, values, err)
+					continue
+				}
+				if !reflect.DeepEqual(dst, src) {
+					t.Errorf("nested %v, slice %v: got %+v, want %+v", nested, slice, dst, src)
+				}
+			}
+		}
+	}
+}
+
+func TestSliceKeys(t *testing.T) {
+	src := map[string][]string{
+		"a[2]":      {"z"},
+		"a[0]":      {"x"},
+		"a[1]":      {"y"},
+		"b[]":       {"1", "2"},
+		"c":         {"c"},
+		"d[e]":      {"f"},
+		"g[0][h]":   {"i"},
+		"[0]":       {"j"},
+		"k[-1]":     {"l"},
+		"m":         {"n"},
+		"m[0]":      {"o"},
+		"p.q[1]":    {"r"},
+		"p.q[0]":    {"s"},
+		"t[0x1]":    {"u"},
+		"v[0]extra": {"w"},
+	}
+	want := map[string][]string{
+		"a":         {"x", "y", "z"},
+		"b":         {"1", "2"},
+		"c":         {"c"},
+		"d[e]":      {"f"},
+		"g[0][h]":   {"i"},
+		"[0]":       {"j"},
+		"k[-1]":     {"l"},
+		"m":         {"n", "o"},
+		"p.q":       {"s", "r"},
+		"t[0x1]":    {"u"},
+		"v[0]extra": {"w"},
+	}
+	if got := sliceKeys(src, nil); !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+	if len(src["m"]) != 1 {
+		t.Errorf("src was modified: %v", src)
+	}
+}
@@ -0,0 +1,104 @@
+// Package compat exposes the API of github.com/gorilla/schema backed by
+// github.com/stormyyd/schema, so code written for the former can switch by
+// changing its import path:
+//
+//	import schema "github.com/stormyyd/schema/compat"
+//
+// The Encoder and Decoder have the method set of gorilla/schema, plus
+// Unwrap returning the underlying Encoder or Decoder to use the features of
+// this package, such as Encoder.EncodeValues keeping the order of fields.
+package compat
+
+import (
+	"reflect"
+
+	"github.com/stormyyd/schema"
+)
+
+// The error and converter types of gorilla/schema.
+type (
+	Converter       = schema.Converter
+	ConversionError = schema.ConversionError
+	EmptyFieldError = schema.MissingFieldError
+	MultiError      = schema.MultiError
+	UnknownKeyError = schema.UnknownKeyError
+)
+
+// Decoder decodes values from a map[string][]string to a struct.
+type Decoder struct {
+	d *schema.Decoder
+}
+
+// NewDecoder returns a new Decoder.
+func NewDecoder() *Decoder {
+	return &Decoder{d: schema.NewDecoder()}
+}
+
+// SetAliasTag changes the tag used to locate custom field aliases.
+// The default tag is "schema".
+func (d *Decoder) SetAliasTag(tag string) {
+	d.d.SetAliasTag(tag)
+}
+
+// ZeroEmpty controls the behaviour when the decoder encounters empty values
+// in a map. See schema.Decoder.ZeroEmpty.
+func (d *Decoder) ZeroEmpty(z bool) {
+	d.d.ZeroEmpty(z)
+}
+
+// IgnoreUnknownKeys controls the behaviour when the decoder encounters
+// unknown keys in the map. See schema.Decoder.IgnoreUnknownKeys.
+func (d *Decoder) IgnoreUnknownKeys(i bool) {
+	d.d.IgnoreUnknownKeys(i)
+}
+
+// MaxSize limits the size of slices for URL nested arrays or object arrays.
+func (d *Decoder) MaxSize(size int) {
+	d.d.MaxSize(size)
+}
+
+// RegisterConverter registers a converter function for a custom type.
+func (d *Decoder) RegisterConverter(value interface{}, converterFunc Converter) {
+	d.d.RegisterConverter(value, converterFunc)
+}
+
+// Decode decodes a map[string][]string to a struct.
+func (d *Decoder) Decode(dst interface{}, src map[string][]string) error {
+	return d.d.Decode(dst, src)
+}
+
+// Unwrap returns the underlying Decoder.
+func (d *Decoder) Unwrap() *schema.Decoder {
+	return d.d
+}
+
+// Encoder encodes values from a struct into url.Values.
+type Encoder struct {
+	e *schema.Encoder
+}
+
+// NewEncoder returns a new Encoder with defaults.
+func NewEncoder() *Encoder {
+	return &Encoder{e: schema.NewEncoder()}
+}
+
+// Encode encodes a struct into map[string][]string.
+func (e *Encoder) Encode(v interface{}, dst map[string][]string) error {
+	return e.e.Encode(v, dst)
+}
+
+// RegisterEncoder registers a converter for encoding a custom type.
+func (e *Encoder) RegisterEncoder(value interface{}, encoder func(reflect.Value) string) {
+	e.e.RegisterEncoder(value, encoder)
+}
+
+// SetAliasTag changes the tag used to locate custom field aliases.
+// The default tag is "schema".
+func (e *Encoder) SetAliasTag(tag string) {
+	e.e.SetAliasTag(tag)
+}
+
+// Unwrap returns the underlying Encoder.
+func (e *Encoder) Unwrap() *schema.Encoder {
+	return e.e
+}
@@ -0,0 +1,56 @@
+package compat
+
+import (
+	"reflect"
+	"strconv"
+	"testing"
+)
+
+type person struct {
+	Name  string `form:"name"`
+	Age   int    `form:"age"`
+	Email string `form:"email,required"`
+}
+
+func TestCompat(t *testing.T) {
+	e := NewEncoder()
+	e.SetAliasTag("form")
+	e.RegisterEncoder(0, func(v reflect.Value) string { return "n" + strconv.FormatInt(v.Int(), 10) })
+	dst := map[string][]string{}
+	if err := e.Encode(person{Name: "a", Age: 1, Email: "e"}, dst); err != nil {
+		t.Fatal(err)
+	}
+	want := map[string][]string{"name": {"a"}, "age": {"n1"}, "email": {"e"}}
+	if !reflect.DeepEqual(dst, want) {
+		t.Errorf("got %v, want %v", dst, want)
+	}
+
+	d := NewDecoder()
+	d.SetAliasTag("form")
+	d.RegisterConverter(0, func(s string) reflect.Value { return reflect.ValueOf(len(s)) })
+	var p person
+	err := d.Decode(&p, map[string][]string{"name": {"a"}, "age": {"abc"}, "other": {"x"}})
+	errs, ok := err.(MultiError)
+	if !ok {
+		t.Fatalf("expected a MultiError, got %v", err)
+	}
+	if _, ok := errs["email"].(EmptyFieldError); !ok {
+		t.Errorf("expected an EmptyFieldError, got %v", errs["email"])
+	}
+	if _, ok := errs["other"].(UnknownKeyError); !ok {
+		t.Errorf("expected an UnknownKeyError, got %v", errs["other"])
+	}
+	if p.Name != "a" || p.Age != 3 {
+		t.Errorf("unexpected result %+v", p)
+	}
+
+	d.IgnoreUnknownKeys(true)
+	d.ZeroEmpty(true)
+	p = person{Age: 3}
+	if err := d.Decode(&p, map[string][]string{"email": {"e"}, "age": {""}, "other": {"x"}}); err != nil {
+		t.Fatal(err)
+	}
+	if p.Age != 0 || p.Email != "e" {
+		t.Errorf("unexpected result %+v", p)
+	}
+}
@@ -5,12 +5,30 @@
 package schema
 
 import (
+	"math"
 	"reflect"
 	"strconv"
+	"strings"
 )
 
+// Converter converts a string to a value of a custom type, or returns an
+// invalid reflect.Value if the string can't be converted.
 type Converter func(string) reflect.Value
 
+// ErrorConverter converts a string to a value of a custom type, or returns
+// an error describing why the string can't be converted.
+type ErrorConverter func(string) (reflect.Value, error)
+
+// withError returns conv as an ErrorConverter, or nil if conv is nil.
+func withError(conv Converter) ErrorConverter {
+	if conv == nil {
+		return nil
+	}
+	return func(s string) (reflect.Value, error) {
+		return conv(s), nil
+	}
+}
+
 var (
 	invalidValue = reflect.Value{}
 	boolType     = reflect.Bool
@@ -47,10 +65,38 @@ var builtinConverters = map[reflect.Kind]Converter{
 	uint64Type:  convertUint64,
 }
 
+// Converters for basic types used by WeaklyTypedInput.
+var weakConverters = map[reflect.Kind]Converter{
+	boolType:    convertWeakBool,
+	float32Type: weakNumber(convertFloat32),
+	float64Type: weakNumber(convertFloat64),
+	intType:     weakNumber(convertInt),
+	int8Type:    weakNumber(convertInt8),
+	int16Type:   weakNumber(convertInt16),
+	int32Type:   weakNumber(convertInt32),
+	int64Type:   weakNumber(convertInt64),
+	uintType:    weakNumber(convertUint),
+	uint8Type:   weakNumber(convertUint8),
+	uint16Type:  weakNumber(convertUint16),
+	uint32Type:  weakNumber(convertUint32),
+	uint64Type:  weakNumber(convertUint64),
+}
+
+// ParseBool returns the boolean value of s as decoded by the Decoder. It
+// accepts the values of strconv.ParseBool, "on" and "yes" for true, as sent
+// by HTML checkboxes, and "off" and "no" for false.
+func ParseBool(s string) (bool, error) {
+	switch s {
+	case "on", "yes":
+		return true, nil
+	case "off", "no":
+		return false, nil
+	}
+	return strconv.ParseBool(s)
+}
+
 func convertBool(value string) reflect.Value {
-	if value == "on" {
-		return reflect.ValueOf(true)
-	} else if v, err := strconv.ParseBool(value); err == nil {
+	if v, err := ParseBool(value); err == nil {
 		return reflect.ValueOf(v)
 	}
 	return invalidValue
@@ -144,79 +190,29 @@ func convertUint64(value string) reflect.Value {
 	return invalidValue
 }
 
-func convertPointer(k reflect.Kind, value string) reflect.Value {
-	switch k {
-	case boolType:
-		if v := convertBool(value); v.IsValid() {
-			converted := v.Bool()
-			return reflect.ValueOf(&converted)
-		}
-	case float32Type:
-		if v := convertFloat32(value); v.IsValid() {
-			converted := float32(v.Float())
-			return reflect.ValueOf(&converted)
-		}
-	case float64Type:
-		if v := convertFloat64(value); v.IsValid() {
-			converted := float64(v.Float())
-			return reflect.ValueOf(&converted)
-		}
-	case intType:
-		if v := convertInt(value); v.IsValid() {
-			converted := int(v.Int())
-			return reflect.ValueOf(&converted)
-		}
-	case int8Type:
-		if v := convertInt8(value); v.IsValid() {
-			converted := int8(v.Int())
-			return reflect.ValueOf(&converted)
-		}
-	case int16Type:
-		if v := convertInt16(value); v.IsValid() {
-			converted := int16(v.Int())
-			return reflect.ValueOf(&converted)
-		}
-	case int32Type:
-		if v := convertInt32(value); v.IsValid() {
-			converted := int32(v.Int())
-			return reflect.ValueOf(&converted)
-		}
-	case int64Type:
-		if v := convertInt64(value); v.IsValid() {
-			converted := int64(v.Int())
-			return reflect.ValueOf(&converted)
-		}
-	case stringType:
-		if v := convertString(value); v.IsValid() {
-			converted := v.String()
-			return reflect.ValueOf(&converted)
-		}
-	case uintType:
-		if v := convertUint(value); v.IsValid() {
-			converted := uint(v.Uint())
-			return reflect.ValueOf(&converted)
-		}
-	case uint8Type:
-		if v := convertUint8(value); v.IsValid() {
-			converted := uint8(v.Uint())
-			return reflect.ValueOf(&converted)
-		}
-	case uint16Type:
-		if v := convertUint16(value); v.IsValid() {
-			converted := uint16(v.Uint())
-			return reflect.ValueOf(&converted)
-		}
-	case uint32Type:
-		if v := convertUint32(value); v.IsValid() {
-			converted := uint32(v.Uint())
-			return reflect.ValueOf(&converted)
+func convertWeakBool(value string) reflect.Value {
+	switch strings.ToLower(strings.TrimSpace(value)) {
+	case "1", "t", "true", "on", "y", "yes":
+		return reflect.ValueOf(true)
+	case "0", "f", "false", "off", "n", "no":
+		return reflect.ValueOf(false)
+	}
+	return invalidValue
+}
+
+// weakNumber returns a converter trimming the spaces around the value
+// before converting it with conv, and accepting integral floats for
+// integers.
+func weakNumber(conv Converter) Converter {
+	return func(value string) reflect.Value {
+		value = strings.TrimSpace(value)
+		if v := conv(value); v.IsValid() {
+			return v
 		}
-	case uint64Type:
-		if v := convertUint64(value); v.IsValid() {
-			converted := uint64(v.Uint())
-			return reflect.ValueOf(&converted)
+		f, err := strconv.ParseFloat(value, 64)
+		if err != nil || f != math.Trunc(f) || math.IsInf(f, 0) {
+			return invalidValue
 		}
+		return conv(strconv.FormatFloat(f, 'f', -1, 64))
 	}
-
-	return invalidValue
 }
@@ -0,0 +1,47 @@
+package schema
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+)
+
+// EncodeCookies encodes the fields of src tagged with "cookie" into
+// cookies, one per value, in field order.
+//
+// Only the name and value of the cookies are set; the caller can set other
+// attributes, such as Path or HttpOnly, before sending them. Values are
+// escaped with url.PathEscape, so that characters not allowed in cookies,
+// such as ';' or '"', are kept. Fields without a "cookie" tag are skipped,
+// except embedded structs, which are walked. The settings of the Encoder
+// apply as for EncodeHeader.
+func (e *Encoder) EncodeCookies(src any) ([]*http.Cookie, error) {
+	s := &encodeState{ctx: context.Background(), values: UrlValues{}, opts: e.sideOptions(), tag: "cookie"}
+	if err := e.encodeInto(src, s); err != nil {
+		return nil, err
+	}
+	cookies := make([]*http.Cookie, 0, len(s.values))
+	for _, v := range s.values {
+		cookies = append(cookies, &http.Cookie{Name: v.Key, Value: url.PathEscape(v.Value)})
+	}
+	return cookies, nil
+}
+
+// DecodeCookies decodes the fields of dst tagged with "cookie" from
+// cookies, typically from Request.Cookies.
+//
+// Fields without a "cookie" tag are ignored, except embedded structs, and
+// so are unknown cookies. Cookies sharing a name decode into a slice.
+// Values are unescaped as encoded by EncodeCookies; values which aren't
+// valid escapes, e.g. set by other code, are decoded as they are.
+func (d *Decoder) DecodeCookies(dst any, cookies []*http.Cookie) error {
+	src := make(map[string][]string, len(cookies))
+	for _, c := range cookies {
+		value, err := url.PathUnescape(c.Value)
+		if err != nil {
+			value = c.Value
+		}
+		src[c.Name] = append(src[c.Name], value)
+	}
+	return d.side("cookie").decodeValues(dst, src)
+}
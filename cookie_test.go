@@ -0,0 +1,89 @@
+package schema
+
+import (
+	"net/http"
+	"reflect"
+	"testing"
+)
+
+type cookiePrefs struct {
+	Session string   `cookie:"session"`
+	Theme   string   `cookie:"theme,omitempty"`
+	Visits  int      `cookie:"visits"`
+	Flags   []string `cookie:"flag"`
+	Lang    string   `schema:"lang"`
+}
+
+func TestEncodeCookies(t *testing.T) {
+	src := cookiePrefs{Session: "abc", Visits: 3, Flags: []string{"a", "b"}, Lang: "en"}
+	cookies, err := NewEncoder().EncodeCookies(src)
+	noError(t, err)
+
+	got := map[string][]string{}
+	var names []string
+	for _, c := range cookies {
+		got[c.Name] = append(got[c.Name], c.Value)
+		names = append(names, c.Name)
+	}
+	want := []string{"session", "visits", "flag", "flag"}
+	if !reflect.DeepEqual(names, want) {
+		t.Errorf("got cookies %v, want %v", names, want)
+	}
+	valExists(t, "session", "abc", got)
+	valExists(t, "visits", "3", got)
+	valsExist(t, "flag", []string{"a", "b"}, got)
+}
+
+func TestEncodeCookiesRoundTrip(t *testing.T) {
+	e := NewEncoder()
+	e.SetOmitEmptyByDefault(true)
+	src := cookiePrefs{Session: `a;b "c" d\e`, Flags: []string{"x,y", "é"}}
+	cookies, err := e.EncodeCookies(src)
+	noError(t, err)
+	if len(cookies) != 3 {
+		t.Fatalf("expected 3 cookies without the empty ones, got %v", cookies)
+	}
+
+	r, err := http.NewRequest(http.MethodGet, "/", nil)
+	noError(t, err)
+	for _, c := range cookies {
+		r.AddCookie(c)
+	}
+	var dst cookiePrefs
+	noError(t, NewDecoder().DecodeCookies(&dst, r.Cookies()))
+	if !reflect.DeepEqual(dst, src) {
+		t.Errorf("got %+v, want %+v", dst, src)
+	}
+
+	// Values which aren't escapes are decoded as they are.
+	noError(t, NewDecoder().DecodeCookies(&dst, []*http.Cookie{{Name: "session", Value: "a+b/c%zz"}}))
+	if dst.Session != "a+b/c%zz" {
+		t.Errorf("got %q, want %q", dst.Session, "a+b/c%zz")
+	}
+}
+
+func TestDecodeCookies(t *testing.T) {
+	r, err := http.NewRequest(http.MethodGet, "/", nil)
+	noError(t, err)
+	r.AddCookie(&http.Cookie{Name: "session", Value: "abc"})
+	r.AddCookie(&http.Cookie{Name: "visits", Value: "3"})
+	r.AddCookie(&http.Cookie{Name: "flag", Value: "a"})
+	r.AddCookie(&http.Cookie{Name: "flag", Value: "b"})
+	r.AddCookie(&http.Cookie{Name: "lang", Value: "en"})
+
+	var dst cookiePrefs
+	noError(t, NewDecoder().DecodeCookies(&dst, r.Cookies()))
+
+	want := cookiePrefs{Session: "abc", Visits: 3, Flags: []string{"a", "b"}}
+	if !reflect.DeepEqual(dst, want) {
+		t.Errorf("got %+v, want %+v", dst, want)
+	}
+}
+
+func TestDecodeCookiesConversionError(t *testing.T) {
+	var dst cookiePrefs
+	err := NewDecoder().DecodeCookies(&dst, []*http.Cookie{{Name: "visits", Value: "many"}})
+	if _, ok := err.(MultiError)["visits"].(ConversionError); !ok {
+		t.Errorf("expected a ConversionError, got %v", err)
+	}
+}
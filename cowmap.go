@@ -0,0 +1,60 @@
+package schema
+
+import (
+	"maps"
+	"sync"
+	"sync/atomic"
+)
+
+// cowMap is a copy-on-write map safe for concurrent use, used to cache
+// metadata by type. Lookups read the current map without locking; stores
+// replace it by a copy. Stores are rare, as they stop once every type in
+// use is cached, so lookups never contend under heavy concurrency.
+//
+// The zero value is an empty map.
+type cowMap[K comparable, V any] struct {
+	// mu serializes the stores.
+	mu sync.Mutex
+	m  atomic.Pointer[map[K]V]
+}
+
+// load returns the value stored for key, if any.
+func (c *cowMap[K, V]) load(key K) (V, bool) {
+	var v V
+	m := c.m.Load()
+	if m == nil {
+		return v, false
+	}
+	v, ok := (*m)[key]
+	return v, ok
+}
+
+// store sets the value for key.
+func (c *cowMap[K, V]) store(key K, value V) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	m := map[K]V{}
+	if old := c.m.Load(); old != nil {
+		m = maps.Clone(*old)
+	}
+	m[key] = value
+	c.m.Store(&m)
+}
+
+// snapshot returns a copy of the current entries, which can be passed to
+// reset to initialize another cowMap.
+func (c *cowMap[K, V]) snapshot() map[K]V {
+	m := c.m.Load()
+	if m == nil {
+		return nil
+	}
+	return maps.Clone(*m)
+}
+
+// reset replaces the entries of c by m, which must not be modified
+// afterwards.
+func (c *cowMap[K, V]) reset(m map[K]V) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.m.Store(&m)
+}
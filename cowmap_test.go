@@ -0,0 +1,60 @@
+package schema
+
+import (
+	"sync"
+	"testing"
+)
+
+func TestCowMap(t *testing.T) {
+	var m cowMap[string, int]
+	if _, ok := m.load("a"); ok {
+		t.Error("expected an empty map")
+	}
+	m.store("a", 1)
+	snap := m.snapshot()
+	m.store("b", 2)
+	if v, ok := m.load("a"); !ok || v != 1 {
+		t.Errorf("expected a=1, got %d, %v", v, ok)
+	}
+	if len(snap) != 1 {
+		t.Errorf("expected the snapshot to be unchanged, got %v", snap)
+	}
+
+	var clone cowMap[string, int]
+	clone.reset(m.snapshot())
+	clone.store("c", 3)
+	if _, ok := m.load("c"); ok {
+		t.Error("expected the clone not to share entries")
+	}
+}
+
+func TestDecodeConcurrent(t *testing.T) {
+	type nested struct {
+		B int `schema:"b"`
+	}
+	type S struct {
+		A string `schema:"a"`
+		N nested `schema:"n"`
+	}
+	d := NewDecoder()
+	e := NewEncoder()
+	var wg sync.WaitGroup
+	for range 8 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for range 100 {
+				var dst S
+				if err := d.Decode(&dst, map[string][]string{"a": {"x"}, "n.b": {"1"}}); err != nil {
+					t.Error(err)
+					return
+				}
+				if err := e.Encode(dst, map[string][]string{}); err != nil {
+					t.Error(err)
+					return
+				}
+			}
+		}()
+	}
+	wg.Wait()
+}
@@ -8,31 +8,64 @@ import (
 	"encoding"
 	"errors"
 	"fmt"
+	"maps"
 	"reflect"
+	"slices"
+	"strconv"
 	"strings"
+	"sync"
 )
 
 const (
-	defaultMaxSize = 16000
+	defaultMaxSize  = 16000
+	defaultMaxDepth = 32
 )
 
-// NewDecoder returns a new Decoder.
-func NewDecoder() *Decoder {
-	return &Decoder{cache: newCache(), maxSize: defaultMaxSize}
+// NewDecoder returns a new Decoder configured with opts.
+func NewDecoder(opts ...DecoderOption) *Decoder {
+	d := &Decoder{cache: newCache(), maxSize: defaultMaxSize, maxDepth: defaultMaxDepth}
+	for _, opt := range opts {
+		opt(d)
+	}
+	return d
 }
 
 // Decoder decodes values from a map[string][]string to a struct.
 type Decoder struct {
 	cache             *cache
-	zeroEmpty         bool
+	empty             EmptyMode
 	ignoreUnknownKeys bool
+	weaklyTyped       bool
 	maxSize           int
+	maxSliceLength    int
+	maxDepth          int
+	maxFileSize       int64
+	validator         func(any) error
+	// boolValues holds the values added by BoolValues.
+	boolValues map[string]bool
+	// sideCaches holds the caches used to decode side tags, such as
+	// "header", by tag.
+	sideCaches sync.Map
 }
 
 // SetAliasTag changes the tag used to locate custom field aliases.
 // The default tag is "schema".
 func (d *Decoder) SetAliasTag(tag string) {
-	d.cache.tag = tag
+	d.cache.tags = []string{tag}
+}
+
+// SetAliasTags sets an ordered list of tags used to locate custom field
+// aliases. The first tag present on a field is used, so structs annotated
+// for other libraries can be reused, e.g. SetAliasTags("schema", "json").
+func (d *Decoder) SetAliasTags(tags ...string) {
+	d.cache.tags = tags
+}
+
+// SetNamingStrategy sets the function used to derive key names from field
+// names for fields without an alias in their tag, as Encoder does.
+// By default the field name is used as is.
+func (d *Decoder) SetNamingStrategy(naming NamingStrategy) {
+	d.cache.naming = naming
 }
 
 // ZeroEmpty controls the behaviour when the decoder encounters empty values
@@ -43,8 +76,40 @@ func (d *Decoder) SetAliasTag(tag string) {
 //
 // The default value is false, that is empty values do not change
 // the value of the struct field.
+//
+// See EmptyValues for more control.
 func (d *Decoder) ZeroEmpty(z bool) {
-	d.zeroEmpty = z
+	if z {
+		d.empty = EmptyZero
+	} else {
+		d.empty = EmptyPointer
+	}
+}
+
+// EmptyMode controls how the Decoder handles empty values, as in "name=".
+type EmptyMode int
+
+const (
+	// EmptyPointer leaves the fields untouched, except nil pointers which
+	// are set to a pointer to the zero value, so an empty value can be told
+	// apart from a missing key, and slices which are set to an empty slice.
+	// This is the default, also set by ZeroEmpty(false).
+	EmptyPointer EmptyMode = iota
+	// EmptyZero sets the fields to their zero value, or pointers to a
+	// pointer to the zero value. Empty elements of slices are decoded as
+	// zero values. It is also set by ZeroEmpty(true).
+	EmptyZero
+	// EmptyIgnore leaves the fields untouched, including nil pointers, as
+	// if the key was missing.
+	EmptyIgnore
+)
+
+// EmptyValues sets how empty values are decoded. Empty elements of slices
+// are skipped, unless the mode is EmptyZero.
+//
+// The default value is EmptyPointer.
+func (d *Decoder) EmptyValues(mode EmptyMode) {
+	d.empty = mode
 }
 
 // IgnoreUnknownKeys controls the behaviour when the decoder encounters unknown
@@ -59,6 +124,46 @@ func (d *Decoder) IgnoreUnknownKeys(i bool) {
 	d.ignoreUnknownKeys = i
 }
 
+// PreferExactKeys controls how keys are matched to field aliases, which is
+// case-insensitive, when several aliases differ only in case.
+// If p is true, a key matching an alias exactly sets its field, so that
+// "userid" sets the field tagged "userid" rather than the field tagged
+// "UserID" declared before it. Either way, required fields are satisfied by
+// the keys which set them.
+//
+// The default value is false: keys set the first field whose alias matches
+// ignoring case.
+func (d *Decoder) PreferExactKeys(p bool) {
+	d.cache.preferExact = p
+}
+
+// WeaklyTypedInput controls how loosely basic values are decoded, as they
+// are often typed by hand or sent by HTML forms.
+// If w is true, booleans also accept "y", "n", any case and surrounding
+// spaces, numbers accept surrounding spaces, and integers accept integral
+// floats such as "1.0" or "1e3", still within the range of the field type.
+// A single value is decoded as a one-element slice in both modes.
+//
+// The default value is false.
+func (d *Decoder) WeaklyTypedInput(w bool) {
+	d.weaklyTyped = w
+}
+
+// BoolValues adds values accepted for booleans, such as "enabled" and
+// "disabled", to the ones accepted by ParseBool. They are matched exactly,
+// or case-insensitively with WeaklyTypedInput.
+func (d *Decoder) BoolValues(truthy, falsy []string) {
+	if d.boolValues == nil {
+		d.boolValues = map[string]bool{}
+	}
+	for _, s := range truthy {
+		d.boolValues[s] = true
+	}
+	for _, s := range falsy {
+		d.boolValues[s] = false
+	}
+}
+
 // MaxSize limits the size of slices for URL nested arrays or object arrays.
 // Choose MaxSize carefully; large values may create many zero-value slice elements.
 // Example: "items.100000=apple" would create a slice with 100,000 empty strings.
@@ -66,47 +171,297 @@ func (d *Decoder) MaxSize(size int) {
 	d.maxSize = size
 }
 
+// MaxSliceLength limits the number of elements decoded into a slice, from
+// repeated keys, comma-separated values or indices of slices of structs.
+// Exceeding it returns a SliceLengthError, before the slice is allocated.
+// The "max" tag option sets the limit of a field, as in
+// `schema:"ids,max=100"`, and takes precedence.
+//
+// The default value is 0, that is no limit besides MaxSize.
+func (d *Decoder) MaxSliceLength(n int) {
+	d.maxSliceLength = n
+}
+
+// MaxDepth limits the number of segments of the keys, as in "a.b.c" or
+// "a[b][c]", so crafted keys can't drive deep walks of the struct. Keys
+// exceeding it return a DepthError, even if unknown keys are ignored.
+// A value of 0 disables the limit.
+//
+// The default value is 32.
+func (d *Decoder) MaxDepth(depth int) {
+	d.maxDepth = depth
+}
+
+// MaxFileSize limits the size of the files decoded by DecodeRequest into
+// fields of type *multipart.FileHeader or []*multipart.FileHeader. Larger
+// files return a FileSizeError.
+//
+// The default value is 0, that is no limit.
+func (d *Decoder) MaxFileSize(size int64) {
+	d.maxFileSize = size
+}
+
+// Validatable is implemented by types that validate themselves once
+// decoded, such as by checking that a range is not empty.
+type Validatable interface {
+	Validate() error
+}
+
+// SetValidator sets a function validating the values decoded without
+// error by Decode, DecodeString and DecodeRequest, typically wrapping a
+// validation library. It is called with the decoded dst, after its
+// Validate method if it implements Validatable. DecodeHeader and
+// DecodeCookies don't validate, as they usually decode part of dst.
+//
+// Validation errors are returned in a MultiError: a MultiError returned by
+// the validator is merged into it, and other errors are keyed by the type
+// of dst.
+func (d *Decoder) SetValidator(validator func(any) error) {
+	d.validator = validator
+}
+
 // RegisterConverter registers a converter function for a custom type.
 func (d *Decoder) RegisterConverter(value interface{}, converterFunc Converter) {
+	d.cache.registerConverter(value, withError(converterFunc))
+}
+
+// RegisterErrorConverter registers a converter function returning errors
+// for a custom type. The error is reported in the ConversionError of the
+// key, along with the offending value.
+func (d *Decoder) RegisterErrorConverter(value interface{}, converterFunc ErrorConverter) {
 	d.cache.registerConverter(value, converterFunc)
 }
 
+// RegisterEnum restricts the values decoded into fields of the type of
+// value, or into pointers and slices of it, to allowed. Other values fail
+// with a ConversionError wrapping an EnumError. Values are checked as
+// received, before any conversion, so it applies to types converted by
+// their kind, by a registered converter or by their UnmarshalText method.
+// Empty values are not checked, and are handled as set by EmptyValues.
+func (d *Decoder) RegisterEnum(value any, allowed ...string) {
+	d.cache.enums[reflect.TypeOf(value)] = slices.Clone(allowed)
+}
+
 // Decode decodes a map[string][]string to a struct.
 //
 // The first parameter must be a pointer to a struct.
 //
 // The second parameter is a map, typically url.Values from an HTTP request.
 // Keys are "paths" in dotted notation to the struct fields and nested structs.
+// Bracket notation is accepted too, so "filter[status]" is the same as
+// "filter.status". Fields of type map collect the keys prefixed by their
+// alias, e.g. "meta.color" sets the "color" entry of the map field "meta".
+// Map keys may be strings, integers or implement encoding.TextUnmarshaler,
+// e.g. "scores[3]" sets the entry 3 of a map[int]string. The values of
+// slices tagged with the "comma", "space", "pipe" or "delim=" options are
+// split into elements, as encoded by the Encoder. Keys are matched
+// case-insensitively to the field aliases, see PreferExactKeys for aliases
+// differing only in case.
+//
+// The first parameter may also be a map[string]any or a map[string][]string,
+// or a pointer to one, for free-form values validated later. The keys of a
+// map[string]any are expanded into nested maps, so "filter[status]=open"
+// sets the "status" entry of the "filter" map to "open". Keys with several
+// values are set to a []string.
+//
+// Decode doesn't stop at the first invalid value: the returned MultiError
+// has an entry per failing key, so all of them can be reported at once. If
+// dst is decoded without error, it is then validated: see SetValidator.
 //
 // See the package documentation for a full explanation of the mechanics.
 func (d *Decoder) Decode(dst interface{}, src map[string][]string) error {
+	if err := d.decodeValues(dst, src); err != nil {
+		return err
+	}
+	return d.validate(dst)
+}
+
+// decodeValues decodes src into dst as Decode does, without validating it.
+func (d *Decoder) decodeValues(dst interface{}, src map[string][]string) error {
 	v := reflect.ValueOf(dst)
+	if m, ok := mapDst(v); ok {
+		return d.decodeMap(m, src)
+	}
 	if v.Kind() != reflect.Ptr || v.Elem().Kind() != reflect.Struct {
 		return errors.New("schema: interface must be a pointer to struct")
 	}
+	if g, ok := d.generated(dst, src); ok {
+		return g.DecodeSchema(src)
+	}
 	v = v.Elem()
 	t := v.Type()
 	errors := MultiError{}
-	for path, values := range src {
-		if parts, err := d.cache.parsePath(path, t); err == nil {
-			if err = d.decode(v, path, parts, values); err != nil {
-				errors[path] = err
+	for key, values := range src {
+		dotted := dottedKey(key)
+		if depth := strings.Count(dotted, ".") + 1; d.maxDepth > 0 && depth > d.maxDepth {
+			errors[key] = DepthError{Key: key, Depth: depth, Max: d.maxDepth}
+		} else if parts, err := d.cache.parsePath(dotted, t); err == nil {
+			if err = d.decode(v, key, parts, values); err != nil {
+				errors[key] = withField(err, parts)
 			}
 		} else if !d.ignoreUnknownKeys {
-			errors[path] = UnknownKeyError{Key: path}
+			errors[key] = UnknownKeyError{Key: key}
 		}
 	}
 	errors.merge(d.setDefaults(t, v))
-	errors.merge(d.checkRequired(t, src))
+	errors.merge(d.checkRequired(t, dottedKeys(src)))
 	if len(errors) > 0 {
 		return errors
 	}
 	return nil
 }
 
+// DecodeString parses the raw query, with or without a leading "?", and
+// decodes it into dst as Decode does. Pairs may be separated by '&' or ';',
+// and the values of a key keep their order. It returns the first error
+// parsing the query, if any, without decoding it.
+func (d *Decoder) DecodeString(dst any, rawQuery string) error {
+	values, err := ParseQueryWithSeparators(strings.TrimPrefix(rawQuery, "?"), "&;")
+	if err != nil {
+		return err
+	}
+	return d.Decode(dst, values.Values())
+}
+
+// validate validates dst with its Validate method and the validator of d.
+func (d *Decoder) validate(dst any) error {
+	errs := MultiError{}
+	add := func(err error) {
+		if m, ok := err.(MultiError); ok {
+			errs.merge(m)
+			return
+		}
+		t := reflect.TypeOf(dst)
+		if t.Kind() == reflect.Ptr {
+			t = t.Elem()
+		}
+		if prev := errs[t.String()]; prev != nil {
+			err = errors.Join(prev, err)
+		}
+		errs[t.String()] = err
+	}
+	if v, ok := dst.(Validatable); ok {
+		if err := v.Validate(); err != nil {
+			add(err)
+		}
+	}
+	if d.validator != nil {
+		if err := d.validator(dst); err != nil {
+			add(err)
+		}
+	}
+	if len(errs) > 0 {
+		return errs
+	}
+	return nil
+}
+
+var (
+	anyMapType    = reflect.TypeOf(map[string]any(nil))
+	valuesMapType = reflect.TypeOf(map[string][]string(nil))
+)
+
+// mapDst returns the map to decode into if v is a map[string]any or a
+// map[string][]string, or a pointer to one, allocating it if needed.
+func mapDst(v reflect.Value) (reflect.Value, bool) {
+	if v.Kind() == reflect.Ptr && !v.IsNil() && v.Elem().Kind() == reflect.Map {
+		if v = v.Elem(); v.IsNil() && v.CanSet() {
+			v.Set(reflect.MakeMap(v.Type()))
+		}
+	}
+	if v.Kind() != reflect.Map || v.IsNil() {
+		return v, false
+	}
+	if !v.Type().ConvertibleTo(anyMapType) && !v.Type().ConvertibleTo(valuesMapType) {
+		return v, false
+	}
+	return v, true
+}
+
+// decodeMap decodes src into the map m. The values of a map[string][]string
+// are copied as is. The keys of a map[string]any are expanded into nested
+// map[string]any, so "filter.status" or "filter[status]" sets the "status"
+// entry of the "filter" map. Keys with a single value are set to the value,
+// and the others to a []string.
+func (d *Decoder) decodeMap(m reflect.Value, src map[string][]string) error {
+	if m.Type().ConvertibleTo(valuesMapType) {
+		dst := m.Convert(valuesMapType).Interface().(map[string][]string)
+		for key, values := range src {
+			dst[key] = slices.Clone(values)
+		}
+		return nil
+	}
+	dst := m.Convert(anyMapType).Interface().(map[string]any)
+	errors := MultiError{}
+	// Sort the keys so a conflict is always reported for the same key.
+	for _, key := range slices.Sorted(maps.Keys(src)) {
+		segments := strings.Split(dottedKey(key), ".")
+		if d.maxDepth > 0 && len(segments) > d.maxDepth {
+			errors[key] = DepthError{Key: key, Depth: len(segments), Max: d.maxDepth}
+			continue
+		}
+		var value any = slices.Clone(src[key])
+		if values := src[key]; len(values) == 1 {
+			value = values[0]
+		}
+		if !setNested(dst, segments, value) {
+			errors[key] = fmt.Errorf("schema: key %q conflicts with another key", key)
+		}
+	}
+	if len(errors) > 0 {
+		return errors
+	}
+	return nil
+}
+
+// setNested sets the value at the path of segments in the nested maps of
+// m, creating them as needed. It returns false if a segment is already set
+// to a value which isn't a map, or the last one to a map.
+func setNested(m map[string]any, segments []string, value any) bool {
+	for _, s := range segments[:len(segments)-1] {
+		switch next := m[s].(type) {
+		case nil:
+			nested := map[string]any{}
+			m[s] = nested
+			m = nested
+		case map[string]any:
+			m = next
+		default:
+			return false
+		}
+	}
+	last := segments[len(segments)-1]
+	if _, ok := m[last].(map[string]any); ok {
+		return false
+	}
+	m[last] = value
+	return true
+}
+
+// withField sets the Go path of the field of the parsed path in the errors
+// returned by decode.
+func withField(err error, parts []pathPart) error {
+	switch e := err.(type) {
+	case ConversionError:
+		e.Field = fieldPath(parts)
+		return e
+	case UnsupportedTypeError:
+		e.Field = fieldPath(parts)
+		return e
+	case SliceLengthError:
+		e.Field = fieldPath(parts)
+		return e
+	case FileSizeError:
+		e.Field = fieldPath(parts)
+		return e
+	}
+	return err
+}
+
 // setDefaults sets the default values when the `default` tag is specified,
 // default is supported on basic/primitive types and their pointers,
-// nested structs can also have default tags
+// nested structs can also have default tags. The default values are parsed
+// when the struct is cached, see parseDefault.
 func (d *Decoder) setDefaults(t reflect.Type, v reflect.Value) MultiError {
 	struc := d.cache.get(t)
 	if struc == nil {
@@ -134,48 +489,10 @@ func (d *Decoder) setDefaults(t reflect.Type, v reflect.Value) MultiError {
 			errs.merge(d.setDefaults(vCurrent.Elem().Type(), vCurrent.Elem()))
 		}
 
-		if f.defaultValue != "" && f.isRequired {
-			errs.merge(MultiError{"default-" + f.name: errors.New("required fields cannot have a default value")})
-		} else if f.defaultValue != "" && vCurrent.IsZero() && !f.isRequired {
-			if f.typ.Kind() == reflect.Struct {
-				errs.merge(MultiError{"default-" + f.name: errors.New("default option is supported only on: bool, float variants, string, unit variants types or their corresponding pointers or slices")})
-			} else if f.typ.Kind() == reflect.Slice {
-				vals := strings.Split(f.defaultValue, "|")
-
-				// check if slice has one of the supported types for defaults
-				if _, ok := builtinConverters[f.typ.Elem().Kind()]; !ok {
-					errs.merge(MultiError{"default-" + f.name: errors.New("default option is supported only on: bool, float variants, string, unit variants types or their corresponding pointers or slices")})
-					continue
-				}
-
-				defaultSlice := reflect.MakeSlice(f.typ, 0, cap(vals))
-				for _, val := range vals {
-					// this check is to handle if the wrong value is provided
-					convertedVal := builtinConverters[f.typ.Elem().Kind()](val)
-					if !convertedVal.IsValid() {
-						errs.merge(MultiError{"default-" + f.name: fmt.Errorf("failed setting default: %s is not compatible with field %s type", val, f.name)})
-						break
-					}
-					defaultSlice = reflect.Append(defaultSlice, convertedVal)
-				}
-				vCurrent.Set(defaultSlice)
-			} else if f.typ.Kind() == reflect.Ptr {
-				t1 := f.typ.Elem()
-
-				if t1.Kind() == reflect.Struct || t1.Kind() == reflect.Slice {
-					errs.merge(MultiError{"default-" + f.name: errors.New("default option is supported only on: bool, float variants, string, unit variants types or their corresponding pointers or slices")})
-				}
-
-				// this check is to handle if the wrong value is provided
-				if convertedVal := convertPointer(t1.Kind(), f.defaultValue); convertedVal.IsValid() {
-					vCurrent.Set(convertedVal)
-				}
-			} else {
-				// this check is to handle if the wrong value is provided
-				if convertedVal := builtinConverters[f.typ.Kind()](f.defaultValue); convertedVal.IsValid() {
-					vCurrent.Set(builtinConverters[f.typ.Kind()](f.defaultValue))
-				}
-			}
+		if f.defaultErr != nil {
+			errs.merge(MultiError{"default-" + f.name: f.defaultErr})
+		} else if f.defaultValue != "" && vCurrent.IsZero() {
+			vCurrent.Set(f.newDefault())
 		}
 	}
 
@@ -192,10 +509,11 @@ func isPointerToStruct(v reflect.Value) bool {
 //
 // src is the source map for decoding, we use it here to see if those required fields are included in src
 func (d *Decoder) checkRequired(t reflect.Type, src map[string][]string) MultiError {
-	m, errs := d.findRequiredFields(t, "", "")
+	m, errs := d.findRequiredFields(t, "", "", "")
 	for key, fields := range m {
-		if isEmptyFields(fields, src) {
-			errs[key] = EmptyFieldError{Key: key}
+		if d.isEmptyFields(t, fields, src) {
+			f := fields[0]
+			errs[key] = MissingFieldError{Key: key, Field: f.field, Type: f.typ}
 		}
 	}
 	return errs
@@ -206,8 +524,9 @@ func (d *Decoder) checkRequired(t reflect.Type, src map[string][]string) MultiEr
 // canonicalPrefix and searchPrefix are used to resolve full paths in dotted notation
 // for nested struct fields. canonicalPrefix is a complete path which never omits
 // any embedded struct fields. searchPrefix is a user-friendly path which may omit
-// some embedded struct fields to point promoted fields.
-func (d *Decoder) findRequiredFields(t reflect.Type, canonicalPrefix, searchPrefix string) (map[string][]fieldWithPrefix, MultiError) {
+// some embedded struct fields to point promoted fields. fieldPrefix is the
+// Go path of the struct.
+func (d *Decoder) findRequiredFields(t reflect.Type, canonicalPrefix, searchPrefix, fieldPrefix string) (map[string][]fieldWithPrefix, MultiError) {
 	struc := d.cache.get(t)
 	if struc == nil {
 		// unexpect, cache.get never return nil
@@ -219,8 +538,9 @@ func (d *Decoder) findRequiredFields(t reflect.Type, canonicalPrefix, searchPref
 	for _, f := range struc.fields {
 		if f.typ.Kind() == reflect.Struct {
 			fcprefix := canonicalPrefix + f.canonicalAlias + "."
+			ffprefix := fieldPrefix + f.canonicalName + "."
 			for _, fspath := range f.paths(searchPrefix) {
-				fm, ferrs := d.findRequiredFields(f.typ, fcprefix, fspath+".")
+				fm, ferrs := d.findRequiredFields(f.typ, fcprefix, fspath+".", ffprefix)
 				for key, fields := range fm {
 					m[key] = append(m[key], fields...)
 				}
@@ -232,6 +552,7 @@ func (d *Decoder) findRequiredFields(t reflect.Type, canonicalPrefix, searchPref
 			m[key] = append(m[key], fieldWithPrefix{
 				fieldInfo: f,
 				prefix:    searchPrefix,
+				field:     fieldPrefix + f.canonicalName,
 			})
 		}
 	}
@@ -241,18 +562,17 @@ func (d *Decoder) findRequiredFields(t reflect.Type, canonicalPrefix, searchPref
 type fieldWithPrefix struct {
 	*fieldInfo
 	prefix string
+	// field is the Go path of the field.
+	field string
 }
 
-// isEmptyFields returns true if all of specified fields are empty.
-func isEmptyFields(fields []fieldWithPrefix, src map[string][]string) bool {
+// isEmptyFields returns true if all of specified fields of the struct type
+// t are empty.
+func (d *Decoder) isEmptyFields(t reflect.Type, fields []fieldWithPrefix, src map[string][]string) bool {
 	for _, f := range fields {
 		for _, path := range f.paths(f.prefix) {
-			v, ok := src[path]
-			if ok && !isEmpty(f.typ, v) {
-				return false
-			}
-			for key := range src {
-				if !isEmpty(f.typ, src[key]) && strings.HasPrefix(key, path) {
+			for key, v := range src {
+				if !isEmpty(f.typ, v) && d.cache.keyResolves(t, key, path) {
 					return false
 				}
 			}
@@ -301,12 +621,15 @@ func (d *Decoder) decode(v reflect.Value, path string, parts []pathPart, values
 		return nil
 	}
 
-	// Dereference if needed.
+	// Dereference if needed. alloc is the pointer allocated for the value,
+	// reset if the value is ignored.
 	t := v.Type()
+	var alloc reflect.Value
 	if t.Kind() == reflect.Ptr {
 		t = t.Elem()
 		if v.IsNil() {
 			v.Set(reflect.New(t))
+			alloc = v
 		}
 		v = v.Elem()
 	}
@@ -315,6 +638,11 @@ func (d *Decoder) decode(v reflect.Value, path string, parts []pathPart, values
 	if len(parts) > 1 {
 		idx := parts[0].index
 		// a defensive check to avoid creating a large slice based on user input index
+		if limit, err := d.sliceLimit(parts[0].field); err != nil {
+			return err
+		} else if limit > 0 && idx >= limit {
+			return SliceLengthError{Key: path, Len: idx + 1, Max: limit}
+		}
 		if idx > d.maxSize {
 			return fmt.Errorf("%v index %d is larger than the configured maxSize %d", v.Kind(), idx, d.maxSize)
 		}
@@ -329,10 +657,31 @@ func (d *Decoder) decode(v reflect.Value, path string, parts []pathPart, values
 		return d.decode(v.Index(idx), path, parts[1:], values)
 	}
 
+	if key := parts[0].mapKey; key != "" {
+		return d.decodeMapEntry(v, path, key, values)
+	}
+	if err := d.checkEnum(path, t, parts[0].field, values); err != nil {
+		return err
+	}
+
 	// Get the converter early in case there is one for a slice type.
 	conv := d.cache.converter(t)
 	m := isTextUnmarshaler(v)
 	if conv == nil && t.Kind() == reflect.Slice && m.IsSliceElement {
+		if delim := parts[0].field.delim; delim != "" {
+			var split []string
+			for _, value := range values {
+				split = append(split, splitDelim(value, delim)...)
+			}
+			values = split
+		}
+		limit, err := d.sliceLimit(parts[0].field)
+		if err != nil {
+			return err
+		}
+		if limit > 0 && len(values) > limit {
+			return SliceLengthError{Key: path, Len: len(values), Max: limit}
+		}
 		var items []reflect.Value
 		elemT := t.Elem()
 		isPtrElem := elemT.Kind() == reflect.Ptr
@@ -343,17 +692,18 @@ func (d *Decoder) decode(v reflect.Value, path string, parts []pathPart, values
 		// Try to get a converter for the element type.
 		conv := d.cache.converter(elemT)
 		if conv == nil {
-			conv = builtinConverters[elemT.Kind()]
-			if conv == nil {
+			builtin := d.builtinConverter(elemT.Kind())
+			if builtin == nil {
 				// As we are not dealing with slice of structs here, we don't need to check if the type
 				// implements TextUnmarshaler interface
-				return fmt.Errorf("schema: converter not found for %v", elemT)
+				return UnsupportedTypeError{Type: elemT}
 			}
+			conv = withError(builtin)
 		}
 
 		for key, value := range values {
 			if value == "" {
-				if d.zeroEmpty {
+				if d.empty == EmptyZero {
 					items = append(items, reflect.Zero(elemT))
 				}
 			} else if m.IsValid {
@@ -366,6 +716,7 @@ func (d *Decoder) decode(v reflect.Value, path string, parts []pathPart, values
 						Key:   path,
 						Type:  t,
 						Index: key,
+						Value: value,
 						Err:   err,
 					}
 				}
@@ -376,7 +727,7 @@ func (d *Decoder) decode(v reflect.Value, path string, parts []pathPart, values
 				} else {
 					items = append(items, u)
 				}
-			} else if item := conv(value); item.IsValid() {
+			} else if item, err := conv(value); item.IsValid() && err == nil {
 				if isPtrElem {
 					ptr := reflect.New(elemT)
 					ptr.Elem().Set(item)
@@ -389,12 +740,15 @@ func (d *Decoder) decode(v reflect.Value, path string, parts []pathPart, values
 			} else {
 				if strings.Contains(value, ",") {
 					values := strings.Split(value, ",")
+					if n := len(items) + len(values); limit > 0 && n > limit {
+						return SliceLengthError{Key: path, Len: n, Max: limit}
+					}
 					for _, value := range values {
 						if value == "" {
-							if d.zeroEmpty {
+							if d.empty == EmptyZero {
 								items = append(items, reflect.Zero(elemT))
 							}
-						} else if item := conv(value); item.IsValid() {
+						} else if item, err := conv(value); item.IsValid() && err == nil {
 							if isPtrElem {
 								ptr := reflect.New(elemT)
 								ptr.Elem().Set(item)
@@ -409,6 +763,8 @@ func (d *Decoder) decode(v reflect.Value, path string, parts []pathPart, values
 								Key:   path,
 								Type:  elemT,
 								Index: key,
+								Value: value,
+								Err:   err,
 							}
 						}
 					}
@@ -417,10 +773,19 @@ func (d *Decoder) decode(v reflect.Value, path string, parts []pathPart, values
 						Key:   path,
 						Type:  elemT,
 						Index: key,
+						Value: value,
+						Err:   err,
 					}
 				}
 			}
 		}
+		if limit > 0 && len(items) > limit {
+			return SliceLengthError{Key: path, Len: len(items), Max: limit}
+		}
+		if len(items) == 0 && d.empty == EmptyIgnore {
+			resetPointer(alloc)
+			return nil
+		}
 		value := reflect.Append(reflect.MakeSlice(t, 0, 0), items...)
 		v.Set(value)
 	} else {
@@ -431,13 +796,15 @@ func (d *Decoder) decode(v reflect.Value, path string, parts []pathPart, values
 		}
 
 		if conv != nil {
-			if value := conv(val); value.IsValid() {
+			if value, err := conv(val); value.IsValid() && err == nil {
 				v.Set(value.Convert(t))
 			} else {
 				return ConversionError{
 					Key:   path,
 					Type:  t,
 					Index: -1,
+					Value: val,
+					Err:   err,
 				}
 			}
 		} else if m.IsValid {
@@ -448,6 +815,7 @@ func (d *Decoder) decode(v reflect.Value, path string, parts []pathPart, values
 						Key:   path,
 						Type:  t,
 						Index: -1,
+						Value: val,
 						Err:   err,
 					}
 				}
@@ -460,15 +828,19 @@ func (d *Decoder) decode(v reflect.Value, path string, parts []pathPart, values
 						Key:   path,
 						Type:  t,
 						Index: -1,
+						Value: val,
 						Err:   err,
 					}
 				}
 			}
 		} else if val == "" {
-			if d.zeroEmpty {
+			switch d.empty {
+			case EmptyZero:
 				v.Set(reflect.Zero(t))
+			case EmptyIgnore:
+				resetPointer(alloc)
 			}
-		} else if conv := builtinConverters[t.Kind()]; conv != nil {
+		} else if conv := d.builtinConverter(t.Kind()); conv != nil {
 			if value := conv(val); value.IsValid() {
 				v.Set(value.Convert(t))
 			} else {
@@ -476,15 +848,68 @@ func (d *Decoder) decode(v reflect.Value, path string, parts []pathPart, values
 					Key:   path,
 					Type:  t,
 					Index: -1,
+					Value: val,
 				}
 			}
 		} else {
-			return fmt.Errorf("schema: converter not found for %v", t)
+			return UnsupportedTypeError{Type: t}
+		}
+	}
+	return nil
+}
+
+// checkEnum returns a ConversionError wrapping an EnumError if the value of
+// the field f of type t, or one of its elements if t is a slice, isn't
+// allowed by the enum registered for its type. As when decoding, only the
+// last value of other types is used.
+func (d *Decoder) checkEnum(path string, t reflect.Type, f *fieldInfo, values []string) error {
+	if len(d.cache.enums) == 0 || len(values) == 0 {
+		return nil
+	}
+	if allowed, ok := d.cache.enums[t]; ok {
+		return enumValueError(path, t, -1, values[len(values)-1], allowed)
+	}
+	if t.Kind() != reflect.Slice {
+		return nil
+	}
+	elemT := t.Elem()
+	if elemT.Kind() == reflect.Ptr {
+		elemT = elemT.Elem()
+	}
+	allowed, ok := d.cache.enums[elemT]
+	if !ok {
+		return nil
+	}
+	if f != nil && f.delim != "" {
+		var split []string
+		for _, value := range values {
+			split = append(split, splitDelim(value, f.delim)...)
+		}
+		values = split
+	}
+	for i, value := range values {
+		if err := enumValueError(path, elemT, i, value, allowed); err != nil {
+			return err
 		}
 	}
 	return nil
 }
 
+// enumValueError returns a ConversionError wrapping an EnumError if value
+// is not empty and not allowed, or nil.
+func enumValueError(path string, t reflect.Type, index int, value string, allowed []string) error {
+	if value == "" || slices.Contains(allowed, value) {
+		return nil
+	}
+	return ConversionError{
+		Key:   path,
+		Type:  t,
+		Index: index,
+		Value: value,
+		Err:   EnumError{Type: t, Value: value, Allowed: allowed},
+	}
+}
+
 func isTextUnmarshaler(v reflect.Value) unmarshaler {
 	// Create a new unmarshaller instance
 	m := unmarshaler{}
@@ -544,13 +969,154 @@ type unmarshaler struct {
 	IsSliceElementPtr bool
 }
 
+// sliceLimit returns the maximum length of the slice field f, or 0 if it is
+// unlimited.
+func (d *Decoder) sliceLimit(f *fieldInfo) (int, error) {
+	if f.maxErr != nil {
+		return 0, f.maxErr
+	}
+	if f.maxLen > 0 {
+		return f.maxLen, nil
+	}
+	return d.maxSliceLength, nil
+}
+
+// builtinConverter returns the converter of the basic kind k, or nil if
+// there is none.
+func (d *Decoder) builtinConverter(k reflect.Kind) Converter {
+	conv := builtinConverters[k]
+	if d.weaklyTyped && weakConverters[k] != nil {
+		conv = weakConverters[k]
+	}
+	if k == reflect.Bool && len(d.boolValues) > 0 {
+		return d.convertBool(conv)
+	}
+	return conv
+}
+
+// convertBool returns a converter for booleans accepting the values added
+// by BoolValues, or converting the others with conv.
+func (d *Decoder) convertBool(conv Converter) Converter {
+	return func(value string) reflect.Value {
+		if v, ok := d.boolValues[value]; ok {
+			return reflect.ValueOf(v)
+		}
+		if d.weaklyTyped {
+			for s, v := range d.boolValues {
+				if strings.EqualFold(s, strings.TrimSpace(value)) {
+					return reflect.ValueOf(v)
+				}
+			}
+		}
+		return conv(value)
+	}
+}
+
+// decodeMapEntry sets the entry key of the map v to the last of values.
+// Empty values only set the entry to the zero value with EmptyZero.
+func (d *Decoder) decodeMapEntry(v reflect.Value, path, key string, values []string) error {
+	t := v.Type().Elem()
+	val := ""
+	if len(values) > 0 {
+		val = values[len(values)-1]
+	}
+	if allowed, ok := d.cache.enums[t]; ok {
+		if err := enumValueError(path, t, -1, val, allowed); err != nil {
+			return err
+		}
+	}
+	var value reflect.Value
+	if val == "" {
+		if d.empty != EmptyZero {
+			return nil
+		}
+		value = reflect.Zero(t)
+	} else {
+		conv := d.cache.converter(t)
+		if conv == nil {
+			conv = withError(d.builtinConverter(t.Kind()))
+		}
+		var err error
+		if value, err = conv(val); !value.IsValid() || err != nil {
+			return ConversionError{
+				Key:   path,
+				Type:  t,
+				Index: -1,
+				Value: val,
+				Err:   err,
+			}
+		}
+	}
+	k, err := parseMapKey(v.Type().Key(), key)
+	if err != nil {
+		return ConversionError{
+			Key:   path,
+			Type:  v.Type().Key(),
+			Index: -1,
+			Value: key,
+			Err:   err,
+		}
+	}
+	if v.IsNil() {
+		v.Set(reflect.MakeMap(v.Type()))
+	}
+	v.SetMapIndex(k, value.Convert(t))
+	return nil
+}
+
+var textUnmarshalerType = reflect.TypeOf((*encoding.TextUnmarshaler)(nil)).Elem()
+
+// isMapKeyType reports whether the keys of maps of type t can be parsed by
+// parseMapKey.
+func isMapKeyType(t reflect.Type) bool {
+	if reflect.PointerTo(t).Implements(textUnmarshalerType) {
+		return true
+	}
+	switch t.Kind() {
+	case reflect.String,
+		reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return true
+	}
+	return false
+}
+
+// parseMapKey parses the map key s of type t, using its UnmarshalText
+// method if it implements encoding.TextUnmarshaler.
+func parseMapKey(t reflect.Type, s string) (reflect.Value, error) {
+	if reflect.PointerTo(t).Implements(textUnmarshalerType) {
+		k := reflect.New(t)
+		if err := k.Interface().(encoding.TextUnmarshaler).UnmarshalText([]byte(s)); err != nil {
+			return reflect.Value{}, err
+		}
+		return k.Elem(), nil
+	}
+	if t.Kind() == reflect.String {
+		return reflect.ValueOf(s).Convert(t), nil
+	}
+	k := builtinConverters[t.Kind()](s)
+	if !k.IsValid() {
+		return reflect.Value{}, fmt.Errorf("invalid map key %q", s)
+	}
+	return k.Convert(t), nil
+}
+
+// resetPointer resets the pointer allocated by decode for an ignored value.
+func resetPointer(alloc reflect.Value) {
+	if alloc.IsValid() {
+		alloc.Set(reflect.Zero(alloc.Type()))
+	}
+}
+
 // Errors ---------------------------------------------------------------------
 
 // ConversionError stores information about a failed conversion.
 type ConversionError struct {
 	Key   string       // key from the source map.
+	Field string       // Go path of the field in dotted notation.
 	Type  reflect.Type // expected type of elem
 	Index int          // index for multi-value fields; -1 for single-value fields.
+	Value string       // value that failed to convert.
 	Err   error        // low-level error (when it exists)
 }
 
@@ -571,6 +1137,11 @@ func (e ConversionError) Error() string {
 	return output
 }
 
+// Unwrap returns the low-level error, such as an EnumError.
+func (e ConversionError) Unwrap() error {
+	return e.Err
+}
+
 // UnknownKeyError stores information about an unknown key in the source map.
 type UnknownKeyError struct {
 	Key string // key from the source map.
@@ -580,25 +1151,137 @@ func (e UnknownKeyError) Error() string {
 	return fmt.Sprintf("schema: invalid path %q", e.Key)
 }
 
-// EmptyFieldError stores information about an empty required field.
-type EmptyFieldError struct {
-	Key string // required key in the source map.
+// MissingFieldError stores information about an empty required field.
+type MissingFieldError struct {
+	Key   string       // required key in the source map.
+	Field string       // Go path of the field in dotted notation.
+	Type  reflect.Type // type of the field.
 }
 
-func (e EmptyFieldError) Error() string {
+func (e MissingFieldError) Error() string {
 	return fmt.Sprintf("%v is empty", e.Key)
 }
 
+// EmptyFieldError is the former name of MissingFieldError.
+//
+// Deprecated: use MissingFieldError.
+type EmptyFieldError = MissingFieldError
+
+// UnsupportedTypeError stores information about a field whose type has no
+// encoder or converter.
+type UnsupportedTypeError struct {
+	Field string       // Go path of the field in dotted notation.
+	Type  reflect.Type // type without encoder or converter.
+	Value string       // formatted value of the field, when encoding.
+}
+
+func (e UnsupportedTypeError) Error() string {
+	if e.Field == "" {
+		return fmt.Sprintf("schema: unsupported type %v", e.Type)
+	}
+	return fmt.Sprintf("schema: unsupported type %v for field %s", e.Type, e.Field)
+}
+
+// SliceLengthError stores information about a slice exceeding the maximum
+// length set by Decoder.MaxSliceLength or the max tag option.
+type SliceLengthError struct {
+	Key   string // key in the source map.
+	Field string // Go path of the field in dotted notation.
+	Len   int    // length required by the source values.
+	Max   int    // maximum length of the slice.
+}
+
+func (e SliceLengthError) Error() string {
+	return fmt.Sprintf("schema: %d values for %q exceed the maximum length %d", e.Len, e.Key, e.Max)
+}
+
+// FileSizeError stores information about a file larger than the maximum
+// set by Decoder.MaxFileSize.
+type FileSizeError struct {
+	Key   string // key of the file in the form.
+	Field string // Go path of the field in dotted notation.
+	Name  string // file name sent with the file.
+	Size  int64  // size of the file, or bytes read until exceeding Max.
+	Max   int64  // maximum size of the files.
+}
+
+func (e FileSizeError) Error() string {
+	return fmt.Sprintf("schema: file %q of %q is larger than the maximum %d bytes", e.Name, e.Key, e.Max)
+}
+
+// DepthError stores information about a key nested deeper than the
+// maximum set by Decoder.MaxDepth.
+type DepthError struct {
+	Key   string // key in the source map.
+	Depth int    // number of segments of the key.
+	Max   int    // maximum number of segments.
+}
+
+func (e DepthError) Error() string {
+	return fmt.Sprintf("schema: key %q has %d segments, more than the maximum %d", e.Key, e.Depth, e.Max)
+}
+
+// KeyConflictError stores information about fields encoded as the same
+// key.
+type KeyConflictError struct {
+	Key    string    // conflicting key.
+	Fields [2]string // Go paths of the fields, in encoding order.
+}
+
+func (e KeyConflictError) Error() string {
+	return fmt.Sprintf("schema: fields %v and %v are both encoded as %q", e.Fields[0], e.Fields[1], e.Key)
+}
+
+// DuplicateKeyError stores information about a key encoded into a
+// destination which already has values for it, when the DuplicateKeyMode
+// of the key is DuplicateError.
+type DuplicateKeyError struct {
+	Key string // duplicate key.
+}
+
+func (e DuplicateKeyError) Error() string {
+	return fmt.Sprintf("schema: key %q already has values", e.Key)
+}
+
+// LengthExceededError stores information about a query longer than the
+// maximum set by Encoder.SetMaxEncodedLength.
+type LengthExceededError struct {
+	Length int // length of the encoded query, in bytes.
+	Max    int // maximum length.
+}
+
+func (e LengthExceededError) Error() string {
+	return fmt.Sprintf("schema: encoded query is %d bytes long, more than the maximum %d", e.Length, e.Max)
+}
+
+// EnumError stores information about a value which is not one of the
+// values registered for its type with Decoder.RegisterEnum, or with
+// Encoder.RegisterEnum when encoding.
+type EnumError struct {
+	Type    reflect.Type // enum type.
+	Value   string       // value not allowed.
+	Allowed []string     // allowed values.
+}
+
+func (e EnumError) Error() string {
+	quoted := make([]string, len(e.Allowed))
+	for i, v := range e.Allowed {
+		quoted[i] = strconv.Quote(v)
+	}
+	return fmt.Sprintf("%q is not a valid %v, expected one of %s", e.Value, e.Type, strings.Join(quoted, ", "))
+}
+
 // MultiError stores multiple decoding errors.
 //
 // Borrowed from the App Engine SDK.
 type MultiError map[string]error
 
+// Error returns the message of the error with the first key in sorted
+// order, followed by the number of other errors.
 func (e MultiError) Error() string {
 	s := ""
-	for _, err := range e {
-		s = err.Error()
-		break
+	if len(e) > 0 {
+		s = e[slices.Min(slices.Collect(maps.Keys(e)))].Error()
 	}
 	switch len(e) {
 	case 0:
@@ -611,6 +1294,30 @@ func (e MultiError) Error() string {
 	return fmt.Sprintf("%s (and %d other errors)", s, len(e)-1)
 }
 
+// Unwrap returns the errors sorted by key, so errors.Is and errors.As
+// find the errors of the fields.
+func (e MultiError) Unwrap() []error {
+	errs := make([]error, 0, len(e))
+	for _, key := range slices.Sorted(maps.Keys(e)) {
+		errs = append(errs, e[key])
+	}
+	return errs
+}
+
+// Missing returns the sorted keys of the empty required fields, reported
+// by a MissingFieldError, e.g. to list all of them in a response.
+func (e MultiError) Missing() []string {
+	var keys []string
+	for _, err := range e {
+		var missing MissingFieldError
+		if errors.As(err, &missing) {
+			keys = append(keys, missing.Key)
+		}
+	}
+	slices.Sort(keys)
+	return keys
+}
+
 func (e MultiError) merge(errors MultiError) {
 	for key, err := range errors {
 		if e[key] == nil {
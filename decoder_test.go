@@ -8,6 +8,8 @@ import (
 	"encoding/hex"
 	"errors"
 	"fmt"
+	"net/netip"
+	"net/url"
 	"reflect"
 	"strings"
 	"testing"
@@ -1274,6 +1276,40 @@ func TestRegisterConverter(t *testing.T) {
 	}
 }
 
+func TestRegisterErrorConverter(t *testing.T) {
+	type Level int
+	errLevel := errors.New("unknown level")
+	decoder := NewDecoder()
+	decoder.RegisterErrorConverter(Level(0), func(s string) (reflect.Value, error) {
+		switch s {
+		case "low":
+			return reflect.ValueOf(Level(1)), nil
+		case "high":
+			return reflect.ValueOf(Level(2)), nil
+		}
+		return reflect.Value{}, errLevel
+	})
+
+	var dst struct {
+		Level  Level   `schema:"level"`
+		Levels []Level `schema:"levels"`
+	}
+	err := decoder.Decode(&dst, map[string][]string{
+		"level":  {"high"},
+		"levels": {"low", "medium"},
+	})
+	if dst.Level != 2 {
+		t.Errorf("expected level 2, got %v", dst.Level)
+	}
+	var cerr ConversionError
+	if !errors.As(err, &cerr) {
+		t.Fatalf("expected a ConversionError, got %v", err)
+	}
+	if cerr.Key != "levels" || cerr.Index != 1 || cerr.Value != "medium" || cerr.Err != errLevel {
+		t.Errorf("unexpected error %#v", cerr)
+	}
+}
+
 // Issue #40
 func TestRegisterConverterSlice(t *testing.T) {
 	decoder := NewDecoder()
@@ -1859,16 +1895,16 @@ func TestComprehensiveDecodingErrors(t *testing.T) {
 		if cerr, ok := e["V"].(ConversionError); !ok {
 			t.Errorf("%s: expected %#v, got %#v", "I2.I1.V", ConversionError{Key: "V"}, cerr)
 		}
-		if key, expected := "I2.I1.P", (EmptyFieldError{Key: "I2.I1.P"}); e[key] != expected {
+		if key, expected := "I2.I1.P", (MissingFieldError{Key: "I2.I1.P", Field: "I2.I1.P", Type: reflect.TypeOf((*int)(nil))}); e[key] != expected {
 			t.Errorf("%s: expected %#v, got %#v", key, expected, e[key])
 		}
-		if key, expected := "I2.J.V", (EmptyFieldError{Key: "I2.J.V"}); e[key] != expected {
+		if key, expected := "I2.J.V", (MissingFieldError{Key: "I2.J.V", Field: "I2.J.V", Type: reflect.TypeOf(0)}); e[key] != expected {
 			t.Errorf("%s: expected %#v, got %#v", key, expected, e[key])
 		}
-		if key, expected := "x.s.v", (EmptyFieldError{Key: "x.s.v"}); e[key] != expected {
+		if key, expected := "x.s.v", (MissingFieldError{Key: "x.s.v", Field: "X.S1.V", Type: reflect.TypeOf("")}); e[key] != expected {
 			t.Errorf("%s: expected %#v, got %#v", key, expected, e[key])
 		}
-		if key, expected := "x.t.p", (EmptyFieldError{Key: "x.t.p"}); e[key] != expected {
+		if key, expected := "x.t.p", (MissingFieldError{Key: "x.t.p", Field: "X.T.P", Type: reflect.TypeOf((*string)(nil))}); e[key] != expected {
 			t.Errorf("%s: expected %#v, got %#v", key, expected, e[key])
 		}
 		if key, expected := "Y.s.v", (UnknownKeyError{Key: "Y.s.v"}); e[key] != expected {
@@ -2333,7 +2369,7 @@ func TestInvalidDefaultElementInSliceRaiseError(t *testing.T) {
 	}
 }
 
-func TestInvalidDefaultsValuesHaveNoEffect(t *testing.T) {
+func TestInvalidDefaultsValuesRaiseError(t *testing.T) {
 	type D struct {
 		B bool     `schema:"b,default:invalid"`
 		C *float32 `schema:"c,default:notAFloat"`
@@ -2377,8 +2413,16 @@ func TestInvalidDefaultsValuesHaveNoEffect(t *testing.T) {
 
 	err := decoder.Decode(&d, data)
 
-	if err != nil {
-		t.Errorf("decoding should succeed but got error: %q", err)
+	dType := reflect.TypeOf(d)
+	e, ok := err.(MultiError)
+	if !ok || len(e) != dType.NumField() {
+		t.Errorf("Expected %d errors, got %#v", dType.NumField(), err)
+	}
+	for i := 0; i < dType.NumField(); i++ {
+		name := dType.Field(i).Name
+		if e["default-"+name] == nil {
+			t.Errorf("expected an error for the default of %s", name)
+		}
 	}
 
 	if !reflect.DeepEqual(expected, d) {
@@ -2527,3 +2571,743 @@ func TestDecoder_SetMaxSize(t *testing.T) {
 		}
 	})
 }
+
+func TestDefaultValueWithEqualsSign(t *testing.T) {
+	type S struct {
+		Limit int    `schema:"limit,default=25"`
+		Time  string `schema:"time,default:10:30"`
+	}
+	s := S{}
+	if err := NewDecoder().Decode(&s, map[string][]string{}); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if s.Limit != 25 {
+		t.Errorf("Expected limit 25, got %d", s.Limit)
+	}
+	if s.Time != "10:30" {
+		t.Errorf("Expected time 10:30, got %s", s.Time)
+	}
+}
+
+func TestDecoderSetAliasTags(t *testing.T) {
+	type S struct {
+		F01 string `schema:"f01" json:"json01"`
+		F02 string `json:"json02"`
+		F03 string
+	}
+	decoder := NewDecoder()
+	decoder.SetAliasTags("schema", "json")
+
+	s := S{}
+	err := decoder.Decode(&s, map[string][]string{
+		"f01":    {"1"},
+		"json02": {"2"},
+		"F03":    {"3"},
+	})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if s.F01 != "1" || s.F02 != "2" || s.F03 != "3" {
+		t.Errorf("Unexpected result: %+v", s)
+	}
+}
+
+func TestDecodeTypedErrors(t *testing.T) {
+	type item struct {
+		Count int `schema:"count"`
+		Ch    chan int
+	}
+	type S struct {
+		Items []item `schema:"items"`
+		Name  string `schema:"name,required"`
+	}
+	var s S
+	err := NewDecoder().Decode(&s, map[string][]string{"items.1.count": {"x"}})
+	errs := err.(MultiError)
+
+	want := ConversionError{Key: "items.1.count", Field: "Items.1.Count", Type: reflect.TypeOf(0), Index: -1, Value: "x"}
+	if errs["items.1.count"] != want {
+		t.Errorf("expected %#v, got %#v", want, errs["items.1.count"])
+	}
+	missing := MissingFieldError{Key: "name", Field: "Name", Type: reflect.TypeOf("")}
+	if errs["name"] != missing {
+		t.Errorf("expected %#v, got %#v", missing, errs["name"])
+	}
+}
+
+func TestMultiErrorUnwrap(t *testing.T) {
+	type S struct {
+		A int `schema:"a"`
+		B int `schema:"b"`
+		C int `schema:"c,required"`
+	}
+	var s S
+	err := NewDecoder().Decode(&s, map[string][]string{"b": {"x"}, "a": {"y"}})
+
+	var cerr ConversionError
+	if !errors.As(err, &cerr) || cerr.Key != "a" {
+		t.Errorf("expected the ConversionError of a, got %#v", cerr)
+	}
+	var merr MissingFieldError
+	if !errors.As(err, &merr) || merr.Key != "c" {
+		t.Errorf("expected the MissingFieldError of c, got %#v", merr)
+	}
+	want := `schema: error converting value for "a" (and 2 other errors)`
+	for range 10 {
+		if got := err.Error(); got != want {
+			t.Fatalf("expected %q, got %q", want, got)
+		}
+	}
+
+	nested := MultiError{"n": MultiError{"k": UnknownKeyError{Key: "k"}}}
+	if !errors.Is(nested, UnknownKeyError{Key: "k"}) {
+		t.Error("expected errors.Is to find a nested error")
+	}
+}
+
+func TestDecodeEmptyValues(t *testing.T) {
+	type S struct {
+		Name  string   `schema:"name"`
+		Count *int     `schema:"count"`
+		Tags  []string `schema:"tag"`
+		IDs   *[]int   `schema:"id"`
+	}
+	src := map[string][]string{"name": {""}, "count": {""}, "tag": {""}, "id": {""}}
+	zero := 0
+	tests := []struct {
+		mode EmptyMode
+		want S
+	}{
+		{EmptyPointer, S{Name: "n", Count: &zero, Tags: []string{}, IDs: &[]int{}}},
+		{EmptyZero, S{Count: &zero, Tags: []string{""}, IDs: &[]int{0}}},
+		{EmptyIgnore, S{Name: "n", Tags: []string{"t"}}},
+	}
+	for _, tt := range tests {
+		d := NewDecoder()
+		d.EmptyValues(tt.mode)
+		dst := S{Name: "n", Tags: []string{"t"}}
+		noError(t, d.Decode(&dst, src))
+		if !reflect.DeepEqual(dst, tt.want) {
+			t.Errorf("mode %d: expected %+v, got %+v", tt.mode, tt.want, dst)
+		}
+	}
+}
+
+func TestMultiErrorMissing(t *testing.T) {
+	type S struct {
+		UserID int    `schema:"user_id,required"`
+		Name   string `schema:"name,required"`
+		Page   int    `schema:"page"`
+		Token  string `schema:"token,required"`
+	}
+	var s S
+	err := NewDecoder().Decode(&s, map[string][]string{"token": {"t"}, "page": {"x"}})
+	errs, ok := err.(MultiError)
+	if !ok || len(errs) != 3 {
+		t.Fatalf("expected 3 errors, got %v", err)
+	}
+	if got, want := errs.Missing(), []string{"name", "user_id"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("expected %v, got %v", want, got)
+	}
+}
+
+func TestDefaultValuesAreCopied(t *testing.T) {
+	type S struct {
+		IDs   []int `schema:"id,default:1|2"`
+		Limit *int  `schema:"limit,default:25"`
+	}
+	d := NewDecoder()
+	var a, b S
+	noError(t, d.Decode(&a, map[string][]string{}))
+	a.IDs[0], *a.Limit = 3, 50
+	noError(t, d.Decode(&b, map[string][]string{}))
+	if !reflect.DeepEqual(b.IDs, []int{1, 2}) || *b.Limit != 25 {
+		t.Errorf("expected the defaults, got %v and %d", b.IDs, *b.Limit)
+	}
+}
+
+func TestDecodeBracketKeys(t *testing.T) {
+	type Item struct {
+		Name string `schema:"name"`
+	}
+	type Filter struct {
+		Status string `schema:"status,required"`
+	}
+	type S struct {
+		Filter Filter `schema:"filter"`
+		Items  []Item `schema:"items"`
+	}
+	var s S
+	src := map[string][]string{"filter[status]": {"open"}, "items[1][name]": {"b"}, "items[0].name": {"a"}}
+	noError(t, NewDecoder().Decode(&s, src))
+	want := S{Filter: Filter{Status: "open"}, Items: []Item{{Name: "a"}, {Name: "b"}}}
+	if !reflect.DeepEqual(s, want) {
+		t.Errorf("expected %+v, got %+v", want, s)
+	}
+
+	err := NewDecoder().Decode(&s, map[string][]string{"filter[state]": {"x"}, "items[": {"x"}, "items[0].[name]": {"x"}})
+	errs := err.(MultiError)
+	for _, key := range []string{"filter[state]", "items[", "items[0].[name]"} {
+		if _, ok := errs[key].(UnknownKeyError); !ok {
+			t.Errorf("expected an UnknownKeyError for %s, got %v", key, errs[key])
+		}
+	}
+}
+
+func TestDecodeMapFields(t *testing.T) {
+	type S struct {
+		Meta   map[string]string `schema:"meta"`
+		Counts map[string]int    `schema:"count"`
+		Name   string            `schema:"name"`
+	}
+	src := map[string][]string{
+		"meta.color":    {"red"},
+		"meta[size]":    {"XL"},
+		"meta.a.b":      {"c"},
+		"count.apples":  {"1", "3"},
+		"count[pears]":  {"2"},
+		"count.bananas": {""},
+		"name":          {"n"},
+	}
+	var s S
+	noError(t, NewDecoder().Decode(&s, src))
+	want := S{
+		Meta:   map[string]string{"color": "red", "size": "XL", "a.b": "c"},
+		Counts: map[string]int{"apples": 3, "pears": 2},
+		Name:   "n",
+	}
+	if !reflect.DeepEqual(s, want) {
+		t.Errorf("expected %+v, got %+v", want, s)
+	}
+
+	err := NewDecoder().Decode(&s, map[string][]string{"count.x": {"y"}, "meta": {"z"}})
+	errs := err.(MultiError)
+	if e, ok := errs["count.x"].(ConversionError); !ok || e.Value != "y" || e.Field != "Counts" {
+		t.Errorf("expected a ConversionError for count.x, got %#v", errs["count.x"])
+	}
+	if _, ok := errs["meta"].(UnknownKeyError); !ok {
+		t.Errorf("expected an UnknownKeyError for meta, got %#v", errs["meta"])
+	}
+}
+
+func TestDecodeMapKeyTypes(t *testing.T) {
+	type S struct {
+		Scores map[int]int           `schema:"scores"`
+		Flags  map[uint8]bool        `schema:"flag"`
+		Hosts  map[netip.Addr]string `schema:"host"`
+	}
+	src := map[string][]string{
+		"scores[3]": {"12"},
+		"scores.-1": {"4"},
+		"flag[7]":   {"true"},
+		"host[::1]": {"local"},
+	}
+	var s S
+	noError(t, NewDecoder().Decode(&s, src))
+	want := S{
+		Scores: map[int]int{3: 12, -1: 4},
+		Flags:  map[uint8]bool{7: true},
+		Hosts:  map[netip.Addr]string{netip.IPv6Loopback(): "local"},
+	}
+	if !reflect.DeepEqual(s, want) {
+		t.Errorf("expected %+v, got %+v", want, s)
+	}
+
+	err := NewDecoder().Decode(&s, map[string][]string{"scores.x": {"1"}, "host[nope]": {"a"}})
+	errs := err.(MultiError)
+	for _, key := range []string{"scores.x", "host[nope]"} {
+		if _, ok := errs[key].(ConversionError); !ok {
+			t.Errorf("expected a ConversionError for %s, got %#v", key, errs[key])
+		}
+	}
+}
+
+// enumLevel is an enum decoded by UnmarshalText.
+type enumLevel string
+
+func (l *enumLevel) UnmarshalText(text []byte) error {
+	*l = enumLevel(strings.ToUpper(string(text)))
+	return nil
+}
+
+func TestDecoderRegisterEnum(t *testing.T) {
+	type color string
+	type level int
+	type S struct {
+		Color  color   `schema:"color"`
+		Levels []level `schema:"level"`
+		Ptr    *color  `schema:"ptr"`
+	}
+	d := NewDecoder()
+	d.RegisterEnum(color(""), "red", "green")
+	d.RegisterEnum(level(0), "1", "2")
+
+	var s S
+	noError(t, d.Decode(&s, map[string][]string{"color": {"red"}, "level": {"2", "1"}, "ptr": {"green"}}))
+	if s.Color != "red" || !reflect.DeepEqual(s.Levels, []level{2, 1}) || s.Ptr == nil || *s.Ptr != "green" {
+		t.Errorf("unexpected result %+v", s)
+	}
+
+	err := d.Decode(&s, map[string][]string{"color": {"blue"}, "level": {"1", "3"}})
+	var enumErr EnumError
+	if !errors.As(err, &enumErr) {
+		t.Fatalf("expected an EnumError, got %v", err)
+	}
+	errs := err.(MultiError)
+	for key, value := range map[string]string{"color": "blue", "level": "3"} {
+		var e EnumError
+		if !errors.As(errs[key], &e) || e.Value != value {
+			t.Errorf("expected an EnumError for %s=%s, got %v", key, value, errs[key])
+		}
+	}
+	if !errors.As(errs["color"], &enumErr) || !reflect.DeepEqual(enumErr.Allowed, []string{"red", "green"}) {
+		t.Errorf("unexpected allowed values in %v", errs["color"])
+	}
+	if got, want := enumErr.Error(), `"blue" is not a valid schema.color, expected one of "red", "green"`; got != want {
+		t.Errorf("expected %q, got %q", want, got)
+	}
+
+	// Registered converters convert the allowed values.
+	d.RegisterConverter(color(""), func(s string) reflect.Value {
+		return reflect.ValueOf(color(strings.ToUpper(s)))
+	})
+	noError(t, d.Decode(&s, map[string][]string{"color": {"green"}}))
+	if s.Color != "GREEN" {
+		t.Errorf("expected GREEN, got %v", s.Color)
+	}
+	if err := d.Decode(&s, map[string][]string{"color": {"GREEN"}}); err == nil {
+		t.Error("expected an error for a value converted to an allowed one")
+	}
+
+	// Values of TextUnmarshaler types are checked before UnmarshalText, in
+	// scalar fields, slices and delimited slices.
+	type T struct {
+		Level  enumLevel    `schema:"l"`
+		Levels []enumLevel  `schema:"ls"`
+		Ptrs   []*enumLevel `schema:"ps,comma"`
+	}
+	d.RegisterEnum(enumLevel(""), "low", "high")
+	var dst T
+	noError(t, d.Decode(&dst, map[string][]string{"l": {"low"}, "ls": {"high", "low"}, "ps": {"low,high"}}))
+	if dst.Level != "LOW" || !reflect.DeepEqual(dst.Levels, []enumLevel{"HIGH", "LOW"}) || len(dst.Ptrs) != 2 || *dst.Ptrs[1] != "HIGH" {
+		t.Errorf("unexpected result %+v", dst)
+	}
+	errs = d.Decode(&dst, map[string][]string{"l": {"mid"}, "ls": {"low", "bogus"}, "ps": {"high,x"}}).(MultiError)
+	for key, index := range map[string]int{"l": -1, "ls": 1, "ps": 1} {
+		var e ConversionError
+		if !errors.As(errs[key], &e) || e.Index != index || !errors.As(e, &enumErr) {
+			t.Errorf("expected an EnumError at index %d for %s, got %v", index, key, errs[key])
+		}
+	}
+}
+
+func TestWeaklyTypedInput(t *testing.T) {
+	type S struct {
+		On    bool    `schema:"on"`
+		Off   bool    `schema:"off"`
+		Count int     `schema:"count"`
+		Small int8    `schema:"small"`
+		Ratio float64 `schema:"ratio"`
+		Tags  []uint  `schema:"tags"`
+	}
+	src := map[string][]string{
+		"on":    {"Yes"},
+		"off":   {"no"},
+		"count": {" 1e3 "},
+		"small": {"-2.0"},
+		"ratio": {" 0.5"},
+		"tags":  {"7"},
+	}
+
+	var strict S
+	if err := NewDecoder().Decode(&strict, src); err == nil {
+		t.Error("expected errors without WeaklyTypedInput")
+	}
+
+	d := NewDecoder()
+	d.WeaklyTypedInput(true)
+	var dst S
+	noError(t, d.Decode(&dst, src))
+	want := S{On: true, Count: 1000, Small: -2, Ratio: 0.5, Tags: []uint{7}}
+	if !reflect.DeepEqual(dst, want) {
+		t.Errorf("got %+v, want %+v", dst, want)
+	}
+
+	for _, value := range []string{"128", "1.5", "maybe"} {
+		key := "small"
+		if value == "maybe" {
+			key = "on"
+		}
+		err := d.Decode(&dst, map[string][]string{key: {value}})
+		if _, ok := err.(MultiError)[key].(ConversionError); !ok {
+			t.Errorf("%s=%s: expected a ConversionError, got %v", key, value, err)
+		}
+	}
+}
+
+func TestDecodeKeysCaseInsensitive(t *testing.T) {
+	type S struct {
+		UserID string `schema:"UserID,required"`
+		Lower  string `schema:"userid"`
+		Name   string `schema:"name"`
+	}
+
+	// Keys set the first field matching them, which satisfies its required
+	// option whatever the case of the key.
+	var dst S
+	noError(t, NewDecoder().Decode(&dst, map[string][]string{"userid": {"2"}, "NAME": {"n"}}))
+	if dst.UserID != "2" || dst.Lower != "" || dst.Name != "n" {
+		t.Errorf("unexpected result %+v", dst)
+	}
+	type R struct {
+		UserID string `schema:"userid,required"`
+	}
+	var r R
+	noError(t, NewDecoder().Decode(&r, map[string][]string{"UserID": {"1"}}))
+	if r.UserID != "1" {
+		t.Errorf("unexpected result %+v", r)
+	}
+
+	d := NewDecoder()
+	d.PreferExactKeys(true)
+	dst = S{}
+	noError(t, d.Decode(&dst, map[string][]string{"userId": {"1"}, "userid": {"2"}, "NAME": {"n"}}))
+	if dst.UserID != "1" || dst.Lower != "2" || dst.Name != "n" {
+		t.Errorf("unexpected result %+v", dst)
+	}
+
+	// Required fields are only satisfied by the keys setting them.
+	dst = S{}
+	err := d.Decode(&dst, map[string][]string{"userid": {"2"}})
+	if _, ok := err.(MultiError)["UserID"].(MissingFieldError); !ok {
+		t.Errorf("expected a MissingFieldError for UserID, got %v", err)
+	}
+	noError(t, d.Decode(&dst, map[string][]string{"USERID": {"1"}}))
+}
+
+func TestDecoderMaxSliceLength(t *testing.T) {
+	type Item struct {
+		N int
+	}
+	type S struct {
+		IDs   []int    `schema:"ids"`
+		Tags  []string `schema:"tags,max=3"`
+		Items []Item   `schema:"items"`
+	}
+	d := NewDecoder()
+	d.MaxSliceLength(2)
+
+	var dst S
+	noError(t, d.Decode(&dst, map[string][]string{
+		"ids":  {"1", "2"},
+		"tags": {"a,b", "c"},
+	}))
+
+	tests := []struct {
+		src  map[string][]string
+		key  string
+		want SliceLengthError
+	}{
+		{map[string][]string{"ids": {"1", "2", "3"}}, "ids", SliceLengthError{Key: "ids", Field: "IDs", Len: 3, Max: 2}},
+		{map[string][]string{"ids": {"1", "2,3"}}, "ids", SliceLengthError{Key: "ids", Field: "IDs", Len: 3, Max: 2}},
+		{map[string][]string{"tags": {"a", "b", "c", "d"}}, "tags", SliceLengthError{Key: "tags", Field: "Tags", Len: 4, Max: 3}},
+		{map[string][]string{"items[100000000][N]": {"1"}}, "items[100000000][N]", SliceLengthError{Key: "items[100000000][N]", Field: "Items.100000000.N", Len: 100000001, Max: 2}},
+	}
+	for _, tc := range tests {
+		var dst S
+		err := d.Decode(&dst, tc.src)
+		errs, _ := err.(MultiError)
+		if got := errs[tc.key]; !reflect.DeepEqual(got, tc.want) {
+			t.Errorf("%v: got %#v, want %#v", tc.src, got, tc.want)
+		}
+	}
+
+	var bad struct {
+		IDs []int `schema:"ids,max=x"`
+	}
+	err := NewDecoder().Decode(&bad, map[string][]string{"ids": {"1"}})
+	if err == nil || err.Error() != `schema: invalid max "x" for IDs` {
+		t.Errorf("unexpected error %v", err)
+	}
+}
+
+func TestDecoderMaxDepth(t *testing.T) {
+	type Node struct {
+		Name string `schema:"name"`
+		Next *Node  `schema:"next"`
+	}
+	d := NewDecoder()
+	d.MaxDepth(3)
+	d.IgnoreUnknownKeys(true)
+
+	var dst Node
+	err := d.Decode(&dst, map[string][]string{
+		"next.next.name":           {"c"},
+		"next[next][next][name]":   {"d"},
+		"a.b.c.d.e.f.g.h.i.j.k.l.": {"x"},
+	})
+	want := MultiError{
+		"next[next][next][name]":   DepthError{Key: "next[next][next][name]", Depth: 4, Max: 3},
+		"a.b.c.d.e.f.g.h.i.j.k.l.": DepthError{Key: "a.b.c.d.e.f.g.h.i.j.k.l.", Depth: 13, Max: 3},
+	}
+	if !reflect.DeepEqual(err, want) {
+		t.Errorf("got %#v, want %#v", err, want)
+	}
+	if dst.Next == nil || dst.Next.Next == nil || dst.Next.Next.Name != "c" || dst.Next.Next.Next != nil {
+		t.Errorf("unexpected result %+v", dst)
+	}
+
+	d.MaxDepth(0)
+	noError(t, d.Decode(&dst, map[string][]string{"next.next.next.next.name": {"e"}}))
+}
+
+func TestDecodeBoolValues(t *testing.T) {
+	type S struct {
+		A, B, C, D bool
+		Flags      []bool
+	}
+	src := map[string][]string{
+		"A":     {"on"},
+		"B":     {"yes"},
+		"C":     {"off"},
+		"D":     {"1"},
+		"Flags": {"no", "true,0"},
+	}
+	var dst S
+	noError(t, NewDecoder().Decode(&dst, src))
+	want := S{A: true, B: true, D: true, Flags: []bool{false, true, false}}
+	if !reflect.DeepEqual(dst, want) {
+		t.Errorf("got %+v, want %+v", dst, want)
+	}
+
+	d := NewDecoder()
+	d.BoolValues([]string{"enabled"}, []string{"disabled"})
+	dst = S{C: true}
+	noError(t, d.Decode(&dst, map[string][]string{"A": {"enabled"}, "B": {"yes"}, "C": {"disabled"}}))
+	if !dst.A || !dst.B || dst.C {
+		t.Errorf("unexpected result %+v", dst)
+	}
+	err := d.Decode(&dst, map[string][]string{"A": {"Enabled"}})
+	if _, ok := err.(MultiError)["A"].(ConversionError); !ok {
+		t.Errorf("expected a ConversionError, got %v", err)
+	}
+	d.WeaklyTypedInput(true)
+	dst = S{}
+	noError(t, d.Decode(&dst, map[string][]string{"A": {"Enabled"}}))
+	if !dst.A {
+		t.Errorf("unexpected result %+v", dst)
+	}
+}
+
+func TestDecodeMap(t *testing.T) {
+	src := map[string][]string{
+		"q":                 {"shoes"},
+		"filter[status]":    {"open"},
+		"filter.tags":       {"a", "b"},
+		"filter[price][lt]": {"10"},
+	}
+	var dst map[string]any
+	noError(t, NewDecoder().Decode(&dst, src))
+	want := map[string]any{
+		"q": "shoes",
+		"filter": map[string]any{
+			"status": "open",
+			"tags":   []string{"a", "b"},
+			"price":  map[string]any{"lt": "10"},
+		},
+	}
+	if !reflect.DeepEqual(dst, want) {
+		t.Errorf("got %v, want %v", dst, want)
+	}
+
+	values := url.Values{}
+	noError(t, NewDecoder().Decode(values, src))
+	if !reflect.DeepEqual(values, url.Values(src)) {
+		t.Errorf("got %v, want %v", values, src)
+	}
+
+	err := NewDecoder().Decode(map[string]any{}, map[string][]string{"a": {"1"}, "a.b": {"2"}})
+	if _, ok := err.(MultiError)["a.b"]; !ok {
+		t.Errorf("expected an error for a.b, got %v", err)
+	}
+	if err := NewDecoder().Decode(map[string]int{}, src); err == nil {
+		t.Error("expected an error for a map[string]int")
+	}
+}
+
+func TestDecodeString(t *testing.T) {
+	var dst struct {
+		Q    string   `schema:"q"`
+		Page int      `schema:"page"`
+		Tags []string `schema:"tag"`
+	}
+	d := NewDecoder()
+	noError(t, d.DecodeString(&dst, "?q=red+shoes&tag=b;tag=a&&page=2"))
+	if dst.Q != "red shoes" || dst.Page != 2 || !reflect.DeepEqual(dst.Tags, []string{"b", "a"}) {
+		t.Errorf("unexpected result %+v", dst)
+	}
+
+	if err := d.DecodeString(&dst, "q=%zz"); err == nil {
+		t.Error("expected an error for an invalid escape")
+	}
+	err := d.DecodeString(&dst, "page=x")
+	if _, ok := err.(MultiError)["page"].(ConversionError); !ok {
+		t.Errorf("expected a ConversionError, got %v", err)
+	}
+}
+
+type validatedRange struct {
+	From int `schema:"from"`
+	To   int `schema:"to"`
+}
+
+func (r validatedRange) Validate() error {
+	if r.From > r.To {
+		return errors.New("from is after to")
+	}
+	return nil
+}
+
+func TestDecodeValidate(t *testing.T) {
+	d := NewDecoder()
+	var dst validatedRange
+	noError(t, d.Decode(&dst, map[string][]string{"from": {"1"}, "to": {"2"}}))
+
+	err := d.Decode(&dst, map[string][]string{"from": {"3"}})
+	if got := err.(MultiError)["schema.validatedRange"]; got == nil || got.Error() != "from is after to" {
+		t.Errorf("unexpected error %v", err)
+	}
+
+	d.SetValidator(func(v any) error {
+		if v.(*validatedRange).To > 10 {
+			return MultiError{"to": errors.New("to is too large")}
+		}
+		return nil
+	})
+	err = d.Decode(&dst, map[string][]string{"from": {"1"}, "to": {"11"}})
+	if got := err.(MultiError)["to"]; got == nil || got.Error() != "to is too large" {
+		t.Errorf("unexpected error %v", err)
+	}
+
+	// Validation only runs once decoding succeeded.
+	err = d.Decode(&dst, map[string][]string{"from": {"x"}, "to": {"11"}})
+	if _, ok := err.(MultiError)["to"]; ok {
+		t.Errorf("unexpected validation error %v", err)
+	}
+}
+
+func TestDecodeCollectsAllErrors(t *testing.T) {
+	var dst struct {
+		Page  int     `schema:"page"`
+		Ratio float64 `schema:"ratio"`
+		IDs   []int   `schema:"id"`
+		Name  string  `schema:"name"`
+		Q     string  `schema:"q,required"`
+	}
+	err := NewDecoder().Decode(&dst, map[string][]string{
+		"page":  {"x"},
+		"ratio": {"y"},
+		"id":    {"1", "z"},
+		"name":  {"n"},
+		"other": {"o"},
+	})
+	errs, ok := err.(MultiError)
+	if !ok {
+		t.Fatalf("expected a MultiError, got %v", err)
+	}
+	for key, want := range map[string]any{
+		"page":  ConversionError{},
+		"ratio": ConversionError{},
+		"id":    ConversionError{},
+		"other": UnknownKeyError{},
+		"q":     MissingFieldError{},
+	} {
+		if reflect.TypeOf(errs[key]) != reflect.TypeOf(want) {
+			t.Errorf("%s: expected a %T, got %v", key, want, errs[key])
+		}
+	}
+	if len(errs) != 5 || dst.Name != "n" {
+		t.Errorf("unexpected errors %v for %+v", errs, dst)
+	}
+}
+
+func TestDecodeAllocatesNestedPointers(t *testing.T) {
+	type City struct {
+		Name string `schema:"name"`
+	}
+	type Address struct {
+		City *City `schema:"city"`
+	}
+	type Item struct {
+		Address *Address `schema:"address"`
+	}
+	var dst struct {
+		Address *Address `schema:"address"`
+		Items   []*Item  `schema:"items"`
+	}
+	noError(t, NewDecoder().Decode(&dst, map[string][]string{
+		"address.city.name":             {"NYC"},
+		"items[1][address][city][name]": {"LA"},
+	}))
+	if dst.Address == nil || dst.Address.City == nil || dst.Address.City.Name != "NYC" {
+		t.Errorf("unexpected address %+v", dst.Address)
+	}
+	if len(dst.Items) != 2 || dst.Items[0] != nil || dst.Items[1].Address.City.Name != "LA" {
+		t.Errorf("unexpected items %+v", dst.Items)
+	}
+}
+
+func TestDecodeDelimitedSlices(t *testing.T) {
+	type S struct {
+		Tags  []string `schema:"tags,comma"`
+		Words []string `schema:"words,space"`
+		IDs   []int    `schema:"ids,pipe"`
+		Path  []string `schema:"path,delim=/"`
+	}
+	var dst S
+	noError(t, NewDecoder().Decode(&dst, map[string][]string{
+		"tags":  {`a,b\,c`, `d\\`},
+		"words": {"hello world"},
+		"ids":   {"1|2", "3"},
+		"path":  {`usr/local\/bin`},
+	}))
+	want := S{
+		Tags:  []string{"a", "b,c", `d\`},
+		Words: []string{"hello", "world"},
+		IDs:   []int{1, 2, 3},
+		Path:  []string{"usr", "local/bin"},
+	}
+	if !reflect.DeepEqual(dst, want) {
+		t.Errorf("got %q, want %q", dst, want)
+	}
+
+	vals := map[string][]string{}
+	noError(t, NewEncoder().Encode(want, vals))
+	valExists(t, "tags", `a,b\,c,d\\`, vals)
+	valExists(t, "ids", "1|2|3", vals)
+	var back S
+	noError(t, NewDecoder().Decode(&back, vals))
+	if !reflect.DeepEqual(back, want) {
+		t.Errorf("round trip: got %q, want %q", back, want)
+	}
+}
+
+func TestDecoderSetNamingStrategy(t *testing.T) {
+	type S struct {
+		UserID   int
+		PageSize int
+		Sort     string `schema:"order_by"`
+	}
+	decoder := NewDecoder()
+	decoder.SetNamingStrategy(SnakeCase)
+
+	var s S
+	err := decoder.Decode(&s, map[string][]string{"user_id": {"1"}, "page_size": {"10"}, "order_by": {"asc"}})
+	noError(t, err)
+	if want := (S{UserID: 1, PageSize: 10, Sort: "asc"}); s != want {
+		t.Errorf("got %+v, want %+v", s, want)
+	}
+	if err := decoder.Decode(&s, map[string][]string{"UserID": {"1"}}); err == nil {
+		t.Error("expected an error decoding the field name")
+	}
+}
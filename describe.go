@@ -0,0 +1,106 @@
+package schema
+
+import (
+	"context"
+	"errors"
+	"reflect"
+)
+
+// Param describes a parameter encoded from, and decoded into, a struct
+// field.
+type Param struct {
+	// Name is the key of the parameter, including the prefix of nested
+	// structs, or the name of the header, cookie or path parameter.
+	Name string
+	// In is where the parameter is sent: "query", "header", "cookie" or
+	// "path". Query parameters are also sent in form bodies.
+	In string
+	// Field is the Go path of the field in dotted notation, showing the
+	// nesting of the parameter.
+	Field    string
+	Type     reflect.Type
+	Required bool
+	Default  string
+	// Options are the tag options of the field, such as "omitempty" or
+	// "max=10".
+	Options []string
+	// Enum lists the values allowed for the parameter, or for its elements
+	// if it is a slice, registered with Encoder.RegisterEnum.
+	Enum []string
+}
+
+// Describe describes the parameters of sample with a default Encoder. See
+// Encoder.Describe.
+func Describe(sample any) ([]Param, error) {
+	return defaultEncoder().Describe(sample)
+}
+
+// Describe returns the parameters of the struct type of sample, which may
+// be a struct, a pointer to a struct or a reflect.Type, in field order. It
+// is meant to generate API documentation or clients from request structs.
+//
+// Nested structs are described by their fields, named as the Encoder
+// encodes them. Recursive types are only described once.
+func (e *Encoder) Describe(sample any) ([]Param, error) {
+	t, ok := sample.(reflect.Type)
+	if !ok {
+		t = reflect.TypeOf(sample)
+	}
+	if t != nil && t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	if t == nil || t.Kind() != reflect.Struct {
+		return nil, errors.New("schema: interface must be a struct")
+	}
+	s := &encodeState{ctx: context.Background(), opts: e.opts}
+	s.regenc, s.plans = e.encoders(s.ctx)
+	d := &describer{e: e, s: s}
+	e.walkPlan(s, t, "", "", map[reflect.Type]bool{}, d.describe)
+	return d.params, nil
+}
+
+// describer holds the state of Encoder.Describe.
+type describer struct {
+	e      *Encoder
+	s      *encodeState
+	params []Param
+}
+
+// describe adds the parameter of the field f of the struct type t, visited
+// by walkPlan. Fields with only a side tag, such as "header", are described
+// by their plan for that tag.
+func (d *describer) describe(t reflect.Type, f *fieldPlan, key, path string, nested bool) {
+	if nested {
+		return
+	}
+	in := "query"
+	if f.alias == "-" {
+		in = sideTag(t.Field(f.index))
+		if in == "" || !hasSideTag(t.Field(f.index), d.e.cache.tags) {
+			return
+		}
+		f = &d.s.plans.get(d.e, t, in).fields[f.index]
+		key = f.alias
+	}
+	d.params = append(d.params, Param{
+		Name:     key,
+		In:       in,
+		Field:    path,
+		Type:     f.typ,
+		Required: f.required,
+		Default:  f.def,
+		Options:  f.opts,
+		Enum:     f.enum,
+	})
+}
+
+// sideTag returns the first side tag of field, such as "header", or an
+// empty string if it has none.
+func sideTag(field reflect.StructField) string {
+	for _, tag := range sideTags {
+		if field.Tag.Get(tag) != "" {
+			return tag
+		}
+	}
+	return ""
+}
@@ -0,0 +1,79 @@
+package schema
+
+import (
+	"reflect"
+	"testing"
+)
+
+type describeFilter struct {
+	Status string `schema:"status,default=open"`
+	Since  *int   `schema:"since"`
+}
+
+type describeRequest struct {
+	headerMeta
+	ID     int            `path:"id"`
+	Q      string         `schema:"q,required"`
+	Tags   []string       `schema:"tag,omitempty,max=10"`
+	Filter describeFilter `schema:"filter"`
+	Next   *describeRequest
+	hidden int
+}
+
+func TestDescribe(t *testing.T) {
+	e := NewEncoder()
+	e.SetNestedKeyStyle(NestedKeyBrackets)
+	params, err := e.Describe(&describeRequest{})
+	noError(t, err)
+	want := []Param{
+		{Name: "x-request-id", In: "header", Field: "headerMeta.RequestID", Type: reflect.TypeOf(""), Options: []string{}},
+		{Name: "id", In: "path", Field: "ID", Type: reflect.TypeOf(0), Options: []string{}},
+		{Name: "q", In: "query", Field: "Q", Type: reflect.TypeOf(""), Required: true, Options: []string{"required"}},
+		{Name: "tag", In: "query", Field: "Tags", Type: reflect.TypeOf([]string{}), Options: []string{"omitempty", "max=10"}},
+		{Name: "filter[status]", In: "query", Field: "Filter.Status", Type: reflect.TypeOf(""), Default: "open", Options: []string{"default=open"}},
+		{Name: "filter[since]", In: "query", Field: "Filter.Since", Type: reflect.TypeOf((*int)(nil)), Options: []string{}},
+		// Unexported fields are described as they are encoded.
+		{Name: "hidden", In: "query", Field: "hidden", Type: reflect.TypeOf(0)},
+	}
+	if !reflect.DeepEqual(params, want) {
+		t.Errorf("got %+v\nwant %+v", params, want)
+	}
+
+	if _, err := Describe(0); err == nil {
+		t.Error("expected an error for a non-struct")
+	}
+}
+
+func TestDescribeMatchesEncode(t *testing.T) {
+	type Range struct {
+		From int `schema:"from"`
+		To   int `schema:"to"`
+	}
+	type Paging struct {
+		Page int `schema:"page"`
+	}
+	type S struct {
+		Paging `schema:",flatten"`
+		Price  Range  `schema:"price,sep=[]"`
+		Date   *Range `schema:"date"`
+		Tags   []int  `schema:"tag"`
+		note   string
+	}
+	src := S{Paging: Paging{Page: 1}, Price: Range{1, 2}, Date: &Range{3, 4}, Tags: []int{5}, note: "n"}
+	for _, style := range []NestedKeyStyle{NestedKeyFlat, NestedKeyDotted, NestedKeyBrackets} {
+		e := NewEncoder()
+		e.SetNestedKeyStyle(style)
+		values, err := e.EncodeValues(src)
+		noError(t, err)
+		params, err := e.Describe(src)
+		noError(t, err)
+		var names []string
+		for _, p := range params {
+			names = append(names, p.Name)
+		}
+		if keys := values.Keys(); !reflect.DeepEqual(names, keys) {
+			t.Errorf("style %v: described %v, encoded %v", style, names, keys)
+		}
+		noError(t, e.Check(src))
+	}
+}
@@ -0,0 +1,44 @@
+package schema
+
+import (
+	"errors"
+	"reflect"
+	"slices"
+)
+
+// EncodeDiff encodes only the fields of current whose encoded values differ
+// from the ones of baseline, e.g. to build PATCH requests. Both must be
+// structs of the same type, or pointers to them.
+//
+// Changed keys are encoded with all of their values from current, in order.
+// Keys which are encoded from baseline but not from current, such as an
+// omitempty field which was cleared, are encoded with an empty value.
+func (e *Encoder) EncodeDiff(current, baseline any) (UrlValues, error) {
+	cv, bv := reflect.Indirect(reflect.ValueOf(current)), reflect.Indirect(reflect.ValueOf(baseline))
+	if !cv.IsValid() || !bv.IsValid() || cv.Type() != bv.Type() {
+		return nil, errors.New("schema: current and baseline must have the same type")
+	}
+	cur, err := e.EncodeValues(current)
+	if err != nil {
+		return nil, err
+	}
+	base, err := e.EncodeValues(baseline)
+	if err != nil {
+		return nil, err
+	}
+	curValues, baseValues := cur.Values(), base.Values()
+
+	diff := UrlValues{}
+	for _, p := range cur {
+		if !slices.Equal(curValues[p.Key], baseValues[p.Key]) {
+			diff = append(diff, p)
+		}
+	}
+	for _, p := range base {
+		if _, ok := curValues[p.Key]; !ok {
+			curValues[p.Key] = nil
+			diff = append(diff, UrlValue{Key: p.Key, Value: ""})
+		}
+	}
+	return diff, nil
+}
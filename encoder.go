@@ -1,58 +1,843 @@
 package schema
 
 import (
+	"cmp"
+	"context"
+	"encoding"
 	"errors"
 	"fmt"
+	"maps"
+	"net/url"
 	"reflect"
+	"slices"
+	"sort"
 	"strconv"
+	"strings"
+	"sync"
+	"unsafe"
 )
 
 type encoderFunc func(reflect.Value) string
 
+// NestedKeyStyle controls how the keys of nested struct fields are built.
+type NestedKeyStyle int
+
+const (
+	// NestedKeyFlat uses the field alias alone, e.g. "city".
+	NestedKeyFlat NestedKeyStyle = iota
+	// NestedKeyDotted prefixes the alias with the parent field alias in
+	// dotted notation, e.g. "address.city", as expected by the Decoder.
+	NestedKeyDotted
+	// NestedKeyBrackets puts the alias in brackets after the parent field
+	// alias, e.g. "address[city]", as also accepted by the Decoder.
+	NestedKeyBrackets
+)
+
+// key returns the key of the field name of a nested struct whose key is
+// prefix, or name if prefix is empty.
+func (s NestedKeyStyle) key(prefix, name string) string {
+	switch {
+	case prefix == "":
+		return name
+	case s == NestedKeyBrackets:
+		return prefix + "[" + name + "]"
+	}
+	return prefix + "." + name
+}
+
+// SliceKeyStyle controls how the keys of slice elements are built.
+type SliceKeyStyle int
+
+const (
+	// SliceKeyRepeat repeats the key for every element, e.g. "tag=a&tag=b".
+	SliceKeyRepeat SliceKeyStyle = iota
+	// SliceKeyBrackets appends empty brackets to the key, e.g.
+	// "tag[]=a&tag[]=b".
+	SliceKeyBrackets
+	// SliceKeyIndexed appends the element index in brackets to the key, e.g.
+	// "tag[0]=a&tag[1]=b".
+	SliceKeyIndexed
+)
+
+// sliceKeyStyles maps the values of the "slice" tag option to styles.
+var sliceKeyStyles = map[string]SliceKeyStyle{
+	"repeat":   SliceKeyRepeat,
+	"brackets": SliceKeyBrackets,
+	"indexed":  SliceKeyIndexed,
+}
+
+// key returns the key of the element i of a slice.
+func (s SliceKeyStyle) key(key string, i int) string {
+	switch s {
+	case SliceKeyBrackets:
+		return key + "[]"
+	case SliceKeyIndexed:
+		return key + "[" + strconv.Itoa(i) + "]"
+	}
+	return key
+}
+
+// DuplicateKeyMode controls how the Encoder handles the keys which already
+// have values in the destination of Encode or EncodeValuesInto, e.g. when
+// several structs are encoded into the same values.
+type DuplicateKeyMode int
+
+const (
+	// DuplicateAppend appends the encoded values after the existing ones.
+	DuplicateAppend DuplicateKeyMode = iota
+	// DuplicateReplace removes the existing values of the key, so that
+	// only the encoded values remain.
+	DuplicateReplace
+	// DuplicateError reports a DuplicateKeyError, leaving the destination
+	// unchanged.
+	DuplicateError
+)
+
+// duplicateKeyModes maps the values of the "duplicate" tag option to modes.
+var duplicateKeyModes = map[string]DuplicateKeyMode{
+	"append":  DuplicateAppend,
+	"replace": DuplicateReplace,
+	"error":   DuplicateError,
+}
+
+// EncodeHookFunc is called by the Encoder with the source value and the
+// values encoded so far.
+type EncodeHookFunc func(src any, values *UrlValues) error
+
+// EncodeHook is implemented by types that want to inject computed
+// parameters or post-process the values encoded from them.
+//
+// EncodeHook is called after all fields of the struct have been encoded.
+type EncodeHook interface {
+	EncodeHook(values *UrlValues) error
+}
+
 // Encoder encodes values from a struct into url.Values.
+//
+// An Encoder is safe for concurrent use by multiple goroutines, including
+// registering encoders while encoding: a call uses the encoders registered
+// when it started. The other settings, such as SetAliasTag or BeforeEncode,
+// must be set before the Encoder is used; use Clone to derive a differently
+// configured Encoder from one in use.
 type Encoder struct {
-	cache  *cache
-	regenc map[reflect.Type]encoderFunc
+	cache *cache
+	// mu guards plans, regenc, regctx, regzero, omitZero and enums. The
+	// maps are replaced, never modified, so encodings can use them without
+	// holding mu.
+	mu              sync.RWMutex
+	plans           *planCache
+	regenc          map[reflect.Type]encoderFunc
+	regctx          map[reflect.Type]ContextEncoderFunc
+	regzero         map[reflect.Type]zeroFunc
+	omitZero        map[reflect.Type]bool
+	enums           map[reflect.Type][]string
+	beforeEncode    []EncodeHookFunc
+	afterEncode     []EncodeHookFunc
+	naming          NamingStrategy
+	dedupe          bool
+	skipUnsupported bool
+	escape          Escaper
+	nilString       string
+	omitNil         bool
+	maxLength       int
+	checkEnums      bool
+	opts            EncodeOptions
 }
 
-// NewEncoder returns a new Encoder with defaults.
-func NewEncoder() *Encoder {
-	return &Encoder{cache: newCache(), regenc: make(map[reflect.Type]encoderFunc)}
+// EncodeOptions holds the settings of an Encoder that can be changed for a
+// single call with EncodeWithOptions.
+type EncodeOptions struct {
+	// OmitEmpty omits zero fields without the "include" tag option, as if
+	// they were tagged "omitempty". See Encoder.SetOmitEmptyByDefault.
+	OmitEmpty bool
+	// KeyPrefix is added to every key encoded from the struct.
+	// See Encoder.SetKeyPrefix.
+	KeyPrefix string
+	// NestedKeyStyle controls how the keys of nested struct fields are
+	// built. See Encoder.SetNestedKeyStyle.
+	NestedKeyStyle NestedKeyStyle
+	// SliceKeyStyle controls how the keys of slice elements are built.
+	// See Encoder.SetSliceKeyStyle.
+	SliceKeyStyle SliceKeyStyle
+	// Include, if not empty, lists the paths of the only fields to encode.
+	// A path is the dotted key of the field in the NestedKeyDotted style,
+	// e.g. "filter.status"; including a struct field includes all of its
+	// fields.
+	Include []string
+	// Exclude lists the paths of fields not to encode. Excluding a struct
+	// field excludes all of its fields.
+	Exclude []string
+	// StrictKeys reports a KeyConflictError when different fields are
+	// encoded as the same key. See Encoder.SetStrictKeys.
+	StrictKeys bool
+	// DuplicateKeys controls how keys which already have values in the
+	// destination are handled. See Encoder.SetDuplicateKeys.
+	DuplicateKeys DuplicateKeyMode
 }
 
-// Encode encodes a struct into map[string][]string.
+// included reports whether the field at path is selected by Include.
+// Structs which contain an included field are selected too.
+func (o *EncodeOptions) included(path string, isStruct bool) bool {
+	if len(o.Include) == 0 {
+		return true
+	}
+	for _, p := range o.Include {
+		if path == p || strings.HasPrefix(path, p+".") {
+			return true
+		}
+		if isStruct && strings.HasPrefix(p, path+".") {
+			return true
+		}
+	}
+	return false
+}
+
+// excluded reports whether the field at path is selected by Exclude.
+func (o *EncodeOptions) excluded(path string) bool {
+	for _, p := range o.Exclude {
+		if path == p || strings.HasPrefix(path, p+".") {
+			return true
+		}
+	}
+	return false
+}
+
+// NewEncoder returns a new Encoder with defaults, configured with opts.
+func NewEncoder(opts ...EncoderOption) *Encoder {
+	e := &Encoder{
+		cache:     newCache(),
+		regenc:    make(map[reflect.Type]encoderFunc),
+		regctx:    make(map[reflect.Type]ContextEncoderFunc),
+		nilString: "null",
+	}
+	e.plans = e.newPlans()
+	for _, opt := range opts {
+		opt(e)
+	}
+	return e
+}
+
+// Clone returns a copy of the Encoder, with the same registered encoders,
+// hooks and settings, which can be configured independently.
+func (e *Encoder) Clone() *Encoder {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+	c := &Encoder{
+		cache:           e.cache.clone(),
+		regenc:          e.regenc,
+		regctx:          e.regctx,
+		regzero:         e.regzero,
+		omitZero:        e.omitZero,
+		enums:           e.enums,
+		beforeEncode:    slices.Clone(e.beforeEncode),
+		afterEncode:     slices.Clone(e.afterEncode),
+		naming:          e.naming,
+		dedupe:          e.dedupe,
+		skipUnsupported: e.skipUnsupported,
+		escape:          e.escape,
+		nilString:       e.nilString,
+		omitNil:         e.omitNil,
+		maxLength:       e.maxLength,
+		checkEnums:      e.checkEnums,
+		opts:            e.opts,
+	}
+	c.opts.Include = slices.Clone(e.opts.Include)
+	c.opts.Exclude = slices.Clone(e.opts.Exclude)
+	c.plans = c.newPlans()
+	return c
+}
+
+// Encode encodes a struct into map[string][]string. Keys which already
+// have values in dst are handled as set by SetDuplicateKeys. The entries
+// of map fields are encoded with their key after the field key, e.g.
+// "scores[3]", in the NestedKeyStyle of the field or in brackets for
+// NestedKeyFlat.
 //
 // Intended for use with url.Values.
 func (e *Encoder) Encode(src any, dst map[string][]string) error {
-	values, err := e.EncodeValues(src)
+	return e.EncodeContext(context.Background(), src, dst)
+}
+
+// EncodeContext is like Encode, passing ctx to the encoders registered
+// with RegisterEncoderContext.
+func (e *Encoder) EncodeContext(ctx context.Context, src any, dst map[string][]string) error {
+	s := &encodeState{ctx: ctx, values: UrlValues{}, opts: e.opts}
+	if err := e.encodeInto(src, s); err != nil {
+		return err
+	}
+	replace, err := s.replacedKeys(func(key string) bool { return len(dst[key]) > 0 })
 	if err != nil {
 		return err
 	}
-	for _, p := range values {
+	for key := range replace {
+		delete(dst, key)
+	}
+	for _, p := range s.values {
 		dst[p.Key] = append(dst[p.Key], p.Value)
 	}
 	return nil
 }
 
+// EncodeValues encodes a struct into UrlValues, keeping the order of the
+// struct fields.
 func (e *Encoder) EncodeValues(src any) (UrlValues, error) {
-	v := reflect.ValueOf(src)
-	values := UrlValues{}
+	return e.EncodeWithOptions(src, e.opts)
+}
+
+// EncodeValuesInto is like EncodeValues but appends the values to dst,
+// reusing its memory. It is meant to be used with AcquireValues. Keys which
+// already have values in dst are handled as set by SetDuplicateKeys. dst is
+// left unchanged if an error is returned.
+func (e *Encoder) EncodeValuesInto(src any, dst *UrlValues) error {
+	s := &encodeState{ctx: context.Background(), values: (*dst)[len(*dst):], opts: e.opts}
+	if err := e.encodeInto(src, s); err != nil {
+		return err
+	}
+	replace, err := s.replacedKeys(dst.Has)
+	if err != nil {
+		return err
+	}
+	if len(replace) > 0 {
+		// The encoded values are past the end of dst, so they must be
+		// copied before the existing values are removed.
+		values := slices.Clone(s.values)
+		*dst = slices.DeleteFunc(*dst, func(p UrlValue) bool { return replace[p.Key] })
+		s.values = values
+	}
+	*dst = append(*dst, s.values...)
+	return nil
+}
+
+// replacedKeys returns the encoded keys with existing values, as reported
+// by exists, to replace according to their DuplicateKeyMode. It returns a
+// MultiError of DuplicateKeyErrors instead if any of them is an error.
+func (s *encodeState) replacedKeys(exists func(key string) bool) (map[string]bool, error) {
+	if s.opts.DuplicateKeys == DuplicateAppend && len(s.duplicates) == 0 {
+		return nil, nil
+	}
+	var replace map[string]bool
+	errors := MultiError{}
+	for _, p := range s.values {
+		mode, ok := s.duplicates[p.Key]
+		if !ok {
+			mode = s.opts.DuplicateKeys
+		}
+		if mode == DuplicateAppend || replace[p.Key] || !exists(p.Key) {
+			continue
+		}
+		if mode == DuplicateError {
+			errors[p.Key] = DuplicateKeyError{Key: p.Key}
+			continue
+		}
+		if replace == nil {
+			replace = map[string]bool{}
+		}
+		replace[p.Key] = true
+	}
+	if len(errors) > 0 {
+		return nil, errors
+	}
+	return replace, nil
+}
+
+// EncodeValuesContext is like EncodeValues, passing ctx to the encoders
+// registered with RegisterEncoderContext.
+func (e *Encoder) EncodeValuesContext(ctx context.Context, src any) (UrlValues, error) {
+	s := &encodeState{ctx: ctx, values: UrlValues{}, opts: e.opts}
+	if err := e.encodeInto(src, s); err != nil {
+		return nil, err
+	}
+	return s.values, nil
+}
+
+// Options returns the current settings of the Encoder, to be modified and
+// passed to EncodeWithOptions.
+func (e *Encoder) Options() EncodeOptions {
+	return e.opts
+}
+
+// EncodeWithOptions is like EncodeValues but uses opts instead of the
+// settings of the Encoder, so a shared Encoder can vary its behavior per
+// call without being modified. Use Options to start from the current
+// settings.
+func (e *Encoder) EncodeWithOptions(src any, opts EncodeOptions) (UrlValues, error) {
+	s := &encodeState{ctx: context.Background(), values: UrlValues{}, opts: opts}
+	if err := e.encodeInto(src, s); err != nil {
+		return nil, err
+	}
+	return s.values, nil
+}
+
+// EncodeOnly is like EncodeValues but only encodes the given fields.
+// Fields are given by path, i.e. their dotted key in the NestedKeyDotted
+// style, such as "filter.status". See EncodeOptions.Include.
+func (e *Encoder) EncodeOnly(src any, fields ...string) (UrlValues, error) {
+	opts := e.opts
+	opts.Include = fields
+	return e.EncodeWithOptions(src, opts)
+}
+
+// EncodeExcept is like EncodeValues but doesn't encode the given fields.
+// Fields are given by path as in EncodeOnly. See EncodeOptions.Exclude.
+func (e *Encoder) EncodeExcept(src any, fields ...string) (UrlValues, error) {
+	opts := e.opts
+	opts.Exclude = append(slices.Clip(opts.Exclude), fields...)
+	return e.EncodeWithOptions(src, opts)
+}
+
+// encodeState holds the state of a single encoding.
+type encodeState struct {
+	ctx    context.Context
+	values UrlValues
+	opts   EncodeOptions
+	// regenc holds the registered encoders bound to ctx, and plans the
+	// plans compiled for them.
+	regenc map[reflect.Type]encoderFunc
+	plans  *planCache
+	// tag is set when encoding the fields with a side tag, such as
+	// "header", instead of query parameters.
+	tag string
+	// multipart indicates that file fields are collected in files. File
+	// fields are skipped otherwise.
+	multipart bool
+	files     []filePart
+	// report, if set, records the keys and skipped fields.
+	report *EncodeReport
+	// parents holds the names of the fields of the nested structs being
+	// encoded, to build the Go paths of the fields.
+	parents    []string
+	parentsBuf [4]string
+	// keys maps the encoded keys to the Go path of the first field
+	// encoding them, and conflicts holds the KeyConflictErrors, when
+	// opts.StrictKeys is set.
+	keys      map[string]string
+	conflicts MultiError
+	// duplicate is the mode of the "duplicate" option of the field being
+	// encoded, if any, and duplicates maps the keys encoded from fields
+	// with the option to its mode.
+	duplicate  *DuplicateKeyMode
+	duplicates map[string]DuplicateKeyMode
+	// noHooks skips the EncodeHook of the structs, as for the split values
+	// of EncodeBatches.
+	noHooks bool
+	// arena holds the bytes of the numbers formatted by format.
+	arena []byte
+}
+
+// arenaSize is the size of the chunks of encodeState.arena.
+const arenaSize = 256
+
+// format returns the value v formatted by app. The strings share the arena
+// of s, so that formatting numbers doesn't allocate for every value. The
+// bytes of the arena are never modified once a string refers to them.
+func (s *encodeState) format(app appendFunc, v reflect.Value) string {
+	// Small integers are formatted without allocating by strconv.
+	n := v
+	if n.Kind() == reflect.Ptr && !n.IsNil() {
+		n = n.Elem()
+	}
+	switch n.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		if n := n.Int(); 0 <= n && n < 100 {
+			return strconv.FormatInt(n, 10)
+		}
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		if n := n.Uint(); n < 100 {
+			return strconv.FormatUint(n, 10)
+		}
+	}
+	if cap(s.arena)-len(s.arena) < 64 {
+		s.arena = make([]byte, 0, arenaSize)
+	}
+	start := len(s.arena)
+	s.arena = app(s.arena, v)
+	b := s.arena[start:]
+	if len(b) == 0 {
+		return ""
+	}
+	return unsafe.String(&b[0], len(b))
+}
 
-	if err := e.encode(v, &values); err != nil {
+// encodeInto encodes src into s, running the hooks.
+func (e *Encoder) encodeInto(src any, s *encodeState) error {
+	s.regenc, s.plans = e.encoders(s.ctx)
+	if s.tag != "" {
+		return e.encode(reflect.ValueOf(src), "", "", s)
+	}
+	for _, hook := range e.beforeEncode {
+		if err := hook(src, &s.values); err != nil {
+			return err
+		}
+	}
+	start := len(s.values)
+	if g, ok := e.generated(src, s); ok {
+		if err := encodeGenerated(g, src, s); err != nil {
+			return err
+		}
+	} else if err := s.withConflicts(e.encode(reflect.ValueOf(src), "", "", s)); err != nil {
+		return err
+	}
+	if s.opts.KeyPrefix != "" {
+		for i := start; i < len(s.values); i++ {
+			s.values[i].Key = s.opts.KeyPrefix + s.values[i].Key
+		}
+	}
+	for _, hook := range e.afterEncode {
+		if err := hook(src, &s.values); err != nil {
+			return err
+		}
+	}
+	if e.dedupe {
+		s.values.RemoveDuplicates()
+	}
+	if e.maxLength > 0 && !s.multipart {
+		if n := encodedLength(s.values, e.escape); n > e.maxLength {
+			return LengthExceededError{Length: n, Max: e.maxLength}
+		}
+	}
+	return nil
+}
+
+// EncodeToString encodes a struct into a query string ("bar=baz&foo=quux"),
+// keeping the order of the struct fields. Keys and values are escaped with
+// the escaper set by SetEscaper, or as by url.QueryEscape.
+func (e *Encoder) EncodeToString(src any) (string, error) {
+	values, err := e.EncodeValues(src)
+	if err != nil {
+		return "", err
+	}
+	return values.EncodeWith(e.escape), nil
+}
+
+// EncodeURL parses base and adds the parameters encoded from src to its
+// query, after any existing parameters.
+func (e *Encoder) EncodeURL(src any, base string) (*url.URL, error) {
+	u, err := url.Parse(base)
+	if err != nil {
 		return nil, err
 	}
-	return values, nil
+	if err := e.EncodeIntoURL(src, u); err != nil {
+		return nil, err
+	}
+	return u, nil
+}
+
+// EncodeIntoURL adds the parameters encoded from src to the query of u,
+// after any existing parameters. The existing query is kept as is.
+func (e *Encoder) EncodeIntoURL(src any, u *url.URL) error {
+	query, err := e.EncodeToString(src)
+	if err != nil {
+		return err
+	}
+	if query == "" {
+		return nil
+	}
+	if u.RawQuery != "" {
+		query = u.RawQuery + "&" + query
+	}
+	u.RawQuery = query
+	u.ForceQuery = false
+	return nil
+}
+
+// BeforeEncode registers a hook called before a struct is encoded.
+// Values added by the hook precede the encoded fields.
+func (e *Encoder) BeforeEncode(hook EncodeHookFunc) {
+	e.beforeEncode = append(e.beforeEncode, hook)
+}
+
+// AfterEncode registers a hook called after a struct has been encoded.
+// The hook may add, change or remove the encoded values.
+func (e *Encoder) AfterEncode(hook EncodeHookFunc) {
+	e.afterEncode = append(e.afterEncode, hook)
 }
 
 // RegisterEncoder registers a converter for encoding a custom type.
 func (e *Encoder) RegisterEncoder(value any, encoder func(reflect.Value) string) {
-	e.regenc[reflect.TypeOf(value)] = encoder
+	t := reflect.TypeOf(value)
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.regenc = maps.Clone(e.regenc)
+	e.regenc[t] = encoder
+	e.regctx = maps.Clone(e.regctx)
+	delete(e.regctx, t)
+	e.plans = e.newPlans()
+}
+
+// RegisterIsZero registers the function reporting whether the values of
+// the type of value are empty, for the omitempty, omitzero and required
+// options and defaults, instead of their IsZero method or the builtin
+// rules. It applies to fields of exactly that type, e.g. to an enum whose
+// zero value is valid, without having to implement IsZero on the type.
+func (e *Encoder) RegisterIsZero(value any, isZero func(reflect.Value) bool) {
+	t := reflect.TypeOf(value)
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.regzero = maps.Clone(e.regzero)
+	if e.regzero == nil {
+		e.regzero = map[reflect.Type]zeroFunc{}
+	}
+	e.regzero[t] = isZero
+	e.plans = e.newPlans()
+}
+
+// AlwaysOmitZero omits the fields of the type of value, or of pointers to
+// it, when they are empty, as if they were tagged "omitempty", e.g.
+// AlwaysOmitZero(time.Time{}) omits the zero timestamps of every struct.
+// Fields tagged with the "include" option are still emitted. Emptiness is
+// checked as by omitempty, including the checks registered with
+// RegisterIsZero, so that pointers are only omitted if nil.
+func (e *Encoder) AlwaysOmitZero(value any) {
+	t := reflect.TypeOf(value)
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.omitZero = maps.Clone(e.omitZero)
+	if e.omitZero == nil {
+		e.omitZero = map[reflect.Type]bool{}
+	}
+	e.omitZero[t] = true
+	e.plans = e.newPlans()
+}
+
+// RegisterEnum registers the values allowed for the type of value, e.g.
+// an enum-like string or integer type. They are listed in the Param.Enum
+// of the fields of this type, or of pointers and slices of it, and checked
+// when encoding if SetCheckEnums is set.
+func (e *Encoder) RegisterEnum(value any, allowed ...string) {
+	t := reflect.TypeOf(value)
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.enums = maps.Clone(e.enums)
+	if e.enums == nil {
+		e.enums = map[reflect.Type][]string{}
+	}
+	e.enums[t] = slices.Clone(allowed)
+	e.plans = e.newPlans()
+}
+
+// enumOf returns the enum type registered in enums of the values of type
+// t, or of its elements if t is a slice or an array, and its allowed
+// values. Pointers are dereferenced.
+func enumOf(enums map[reflect.Type][]string, t reflect.Type) (reflect.Type, []string) {
+	if len(enums) == 0 {
+		return nil, nil
+	}
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	if allowed, ok := enums[t]; ok {
+		return t, allowed
+	}
+	if t.Kind() != reflect.Slice && t.Kind() != reflect.Array {
+		return nil, nil
+	}
+	for t = t.Elem(); t.Kind() == reflect.Ptr; t = t.Elem() {
+	}
+	if allowed, ok := enums[t]; ok {
+		return t, allowed
+	}
+	return nil, nil
+}
+
+// ContextEncoderFunc is a custom encoder receiving the context passed to
+// the encoding methods, such as EncodeContext.
+type ContextEncoderFunc func(ctx context.Context, v reflect.Value) string
+
+// RegisterEncoderContext registers a context-aware converter for encoding a
+// custom type. The encoder receives the context passed to EncodeContext or
+// EncodeValuesContext, and context.Background() for the other methods, so
+// it can use request-scoped data such as a tenant or a locale.
+func (e *Encoder) RegisterEncoderContext(value any, encoder ContextEncoderFunc) {
+	t := reflect.TypeOf(value)
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.regctx = maps.Clone(e.regctx)
+	e.regctx[t] = encoder
+	e.regenc = maps.Clone(e.regenc)
+	delete(e.regenc, t)
+	e.plans = e.newPlans()
+}
+
+// encoders returns the registered encoders, binding the context-aware
+// ones to ctx, and the plans compiled for them.
+func (e *Encoder) encoders(ctx context.Context) (map[reflect.Type]encoderFunc, *planCache) {
+	e.mu.RLock()
+	regenc, regctx, plans := e.regenc, e.regctx, e.plans
+	e.mu.RUnlock()
+	if len(regctx) == 0 {
+		return regenc, plans
+	}
+	reg := maps.Clone(regenc)
+	for t, f := range regctx {
+		reg[t] = func(v reflect.Value) string {
+			return f(ctx, v)
+		}
+	}
+	return reg, plans
+}
+
+// resetPlans discards the compiled plans, after a setting used to build
+// them changed.
+func (e *Encoder) resetPlans() {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.plans = e.newPlans()
 }
 
 // SetAliasTag changes the tag used to locate custom field aliases.
 // The default tag is "schema".
 func (e *Encoder) SetAliasTag(tag string) {
-	e.cache.tag = tag
+	e.cache.tags = []string{tag}
+	e.resetPlans()
+}
+
+// SetAliasTags sets an ordered list of tags used to locate custom field
+// aliases. The first tag present on a field is used, so structs annotated
+// for other libraries can be reused, e.g. SetAliasTags("schema", "json").
+func (e *Encoder) SetAliasTags(tags ...string) {
+	e.cache.tags = tags
+	e.resetPlans()
+}
+
+// SetNamingStrategy sets the function used to derive key names from field
+// names for fields without an alias in their tag.
+// By default the field name is used as is.
+func (e *Encoder) SetNamingStrategy(naming NamingStrategy) {
+	e.naming = naming
+	e.resetPlans()
+}
+
+// SetKeyPrefix sets a prefix added to every key encoded from a struct,
+// e.g. "filter.". Values added by hooks are not prefixed.
+func (e *Encoder) SetKeyPrefix(prefix string) {
+	e.opts.KeyPrefix = prefix
+}
+
+// SetNestedKeyStyle sets how the keys of nested struct fields are built.
+// Embedded structs and fields tagged with the "flatten" (or "squash")
+// option are always merged into the parent namespace. A field can set the
+// style of the keys of its nested fields with the "sep" option, "." for
+// NestedKeyDotted or "[]" for NestedKeyBrackets, e.g.
+// `schema:"filter,sep=[]"` encodes "filter[status]" whatever the style.
+//
+// The default style is NestedKeyFlat.
+func (e *Encoder) SetNestedKeyStyle(style NestedKeyStyle) {
+	e.opts.NestedKeyStyle = style
+}
+
+// SetSliceKeyStyle sets how the keys of slice elements are built.
+// It can be overridden per field with the "slice" tag option, e.g.
+// `schema:"tags,slice=brackets"`. The "comma", "space", "pipe" and "delim="
+// options instead join the elements into a single value, e.g.
+// `schema:"tags,comma"` encodes "tags=a,b", escaping the delimiters in the
+// elements with a backslash. The Decoder splits such values back.
+//
+// The default style is SliceKeyRepeat.
+func (e *Encoder) SetSliceKeyStyle(style SliceKeyStyle) {
+	e.opts.SliceKeyStyle = style
+}
+
+// SetRemoveDuplicates controls whether pairs repeating both the key and the
+// value of a previous pair are dropped from the encoded values, including
+// pairs added by hooks.
+//
+// The default value is false.
+func (e *Encoder) SetRemoveDuplicates(r bool) {
+	e.dedupe = r
+}
+
+// SetDuplicateKeys sets how Encode and EncodeValuesInto handle the keys
+// which already have values in their destination. A field can override it
+// with the "duplicate" tag option, e.g. `schema:"sort,duplicate=replace"`.
+// Values added by hooks follow the mode of the Encoder.
+//
+// The default mode is DuplicateAppend.
+func (e *Encoder) SetDuplicateKeys(mode DuplicateKeyMode) {
+	e.opts.DuplicateKeys = mode
+}
+
+// SetMaxEncodedLength sets the maximum length in bytes of the query
+// encoded from a struct, including the values added by hooks, to fail
+// with a LengthExceededError before a URL is truncated by a proxy or a
+// server. The length is that of the query escaped as by EncodeToString,
+// without any base URL or existing parameters. Multipart forms are not
+// limited.
+//
+// The default value is 0: the length is not limited.
+func (e *Encoder) SetMaxEncodedLength(n int) {
+	e.maxLength = n
+}
+
+// SetCheckEnums controls whether encoding fails when a field of a type
+// registered with RegisterEnum has a value which isn't allowed, reported
+// with an EnumError. Nil pointers and omitted fields are not checked.
+//
+// The default value is false: the values are encoded unchecked.
+func (e *Encoder) SetCheckEnums(c bool) {
+	e.checkEnums = c
+}
+
+// SetEscaper sets the function used to escape keys and values by the
+// methods returning encoded strings, such as EncodeToString.
+// If escape is nil, keys and values are escaped as by url.QueryEscape.
+func (e *Encoder) SetEscaper(escape Escaper) {
+	e.escape = escape
+}
+
+// SetNilString sets the value encoded for nil pointers without a custom
+// encoder. The default value is "null".
+func (e *Encoder) SetNilString(s string) {
+	e.nilString = s
+	e.resetPlans()
+}
+
+// SetOmitEmptyByDefault controls whether zero fields are omitted when the
+// field has no "omitempty" tag option.
+// If o is true every field behaves as if tagged "omitempty", except fields
+// tagged with the "include" option which are always emitted.
+//
+// The default value is false.
+func (e *Encoder) SetOmitEmptyByDefault(o bool) {
+	e.opts.OmitEmpty = o
+}
+
+// SetSkipUnsupported controls whether fields of a type without encoder,
+// such as channels and funcs, are skipped. If s is false, Encode returns an
+// UnsupportedTypeError for each of them.
+//
+// The default value is false.
+func (e *Encoder) SetSkipUnsupported(s bool) {
+	e.skipUnsupported = s
+}
+
+// SetStrictKeys controls whether encoding fails when different fields are
+// encoded as the same key, e.g. because of their aliases or flattened
+// nested structs. If s is true, every conflicting field is reported with a
+// KeyConflictError naming both fields. Values added by hooks are not
+// checked.
+//
+// The default value is false: the values of such fields are all encoded.
+func (e *Encoder) SetStrictKeys(s bool) {
+	e.opts.StrictKeys = s
+}
+
+// fieldAlias returns the alias and tag options of a field, applying the
+// naming strategy when the tag doesn't define an alias.
+func (e *Encoder) fieldAlias(field reflect.StructField) (string, tagOptions) {
+	alias, options := fieldAlias(field, e.cache.tags)
+	if e.naming != nil {
+		if name, _ := parseTag(lookupTag(field, e.cache.tags)); name == "" {
+			alias = e.naming(field.Name)
+		}
+	}
+	return alias, options
+}
+
+// nestedKeySeparators maps the values of the "sep" tag option to the
+// styles of the keys of nested fields.
+var nestedKeySeparators = map[string]NestedKeyStyle{
+	".":  NestedKeyDotted,
+	"[]": NestedKeyBrackets,
 }
 
 // isValidStructPointer test if input value is a valid struct pointer.
@@ -60,37 +845,163 @@ func isValidStructPointer(v reflect.Value) bool {
 	return v.Type().Kind() == reflect.Ptr && v.Elem().IsValid() && v.Elem().Type().Kind() == reflect.Struct
 }
 
-func isZero(v reflect.Value) bool {
+// isNil reports whether v is a nil pointer, slice, map or interface.
+func isNil(v reflect.Value) bool {
 	switch v.Kind() {
-	case reflect.Func:
+	case reflect.Ptr, reflect.Slice, reflect.Map, reflect.Interface:
+		return v.IsNil()
+	}
+	return false
+}
+
+// zeroer is implemented by types that define their own zero value.
+type zeroer interface {
+	IsZero() bool
+}
+
+var zeroerType = reflect.TypeOf((*zeroer)(nil)).Elem()
+
+// zeroFunc reports whether a value is zero.
+type zeroFunc func(reflect.Value) bool
+
+// zeroFuncs and zeroValueFuncs cache the functions returned by zeroCheck
+// and zeroValueCheck by type.
+var zeroFuncs, zeroValueFuncs sync.Map
+
+// isZeroValue reports whether v is zero the way encoding/json v2 does for
+// omitzero: the IsZero method is used if available, otherwise v is compared
+// against the zero value of its type.
+func isZeroValue(v reflect.Value) bool {
+	return zeroValueCheck(v.Type())(v)
+}
+
+// zeroValueCheck returns the function used by isZeroValue for values of
+// type t.
+func zeroValueCheck(t reflect.Type) zeroFunc {
+	if f, ok := zeroValueFuncs.Load(t); ok {
+		return f.(zeroFunc)
+	}
+	var f zeroFunc
+	nillable := false
+	switch t.Kind() {
+	case reflect.Ptr, reflect.Slice, reflect.Map, reflect.Interface:
+		nillable = true
+	}
+	switch {
+	case t.Kind() == reflect.Interface:
+		// The IsZero method of the dynamic value is used.
+		f = func(v reflect.Value) bool {
+			if v.IsNil() {
+				return true
+			}
+			if !v.CanInterface() {
+				return false
+			}
+			if z, ok := v.Elem().Interface().(zeroer); ok {
+				return z.IsZero()
+			}
+			return false
+		}
+	case t.Implements(zeroerType):
+		f = func(v reflect.Value) bool {
+			if nillable && v.IsNil() {
+				return true
+			}
+			if v.CanInterface() {
+				return v.Interface().(zeroer).IsZero()
+			}
+			return v.IsZero()
+		}
+	case reflect.PointerTo(t).Implements(zeroerType):
+		f = func(v reflect.Value) bool {
+			if nillable && v.IsNil() {
+				return true
+			}
+			if v.CanAddr() && v.Addr().CanInterface() {
+				return v.Addr().Interface().(zeroer).IsZero()
+			}
+			return v.IsZero()
+		}
+	default:
+		f = reflect.Value.IsZero
+	}
+	zeroValueFuncs.Store(t, f)
+	return f
+}
+
+// isZero reports whether v is empty for the omitempty and required
+// options and defaults: nil or empty slices and maps are empty, as are
+// arrays and structs whose elements are all empty, unless the struct
+// implements IsZero. Pointers are only empty if nil, so that a pointer to
+// an empty string or a zero number marks a value explicitly set.
+func isZero(v reflect.Value) bool {
+	return zeroCheck(v.Type())(v)
+}
+
+// zeroCheck returns the function used by isZero for values of type t.
+func zeroCheck(t reflect.Type) zeroFunc {
+	if f, ok := zeroFuncs.Load(t); ok {
+		return f.(zeroFunc)
+	}
+	var f zeroFunc
+	switch t.Kind() {
+	case reflect.Func, reflect.Ptr, reflect.Interface, reflect.Chan, reflect.UnsafePointer:
+		f = reflect.Value.IsNil
 	case reflect.Map, reflect.Slice:
-		return v.IsNil() || v.Len() == 0
+		f = func(v reflect.Value) bool {
+			return v.IsNil() || v.Len() == 0
+		}
 	case reflect.Array:
-		z := true
-		for i := 0; i < v.Len(); i++ {
-			z = z && isZero(v.Index(i))
+		elem := zeroCheck(t.Elem())
+		f = func(v reflect.Value) bool {
+			for i := 0; i < v.Len(); i++ {
+				if !elem(v.Index(i)) {
+					return false
+				}
+			}
+			return true
 		}
-		return z
 	case reflect.Struct:
-		type zero interface {
-			IsZero() bool
+		fields := make([]zeroFunc, t.NumField())
+		for i := range fields {
+			fields[i] = zeroCheck(t.Field(i).Type)
 		}
-		if v.Type().Implements(reflect.TypeOf((*zero)(nil)).Elem()) {
-			iz := v.MethodByName("IsZero").Call([]reflect.Value{})[0]
-			return iz.Interface().(bool)
+		structural := func(v reflect.Value) bool {
+			for i, field := range fields {
+				if !field(v.Field(i)) {
+					return false
+				}
+			}
+			return true
 		}
-		z := true
-		for i := 0; i < v.NumField(); i++ {
-			z = z && isZero(v.Field(i))
+		f = structural
+		if t.Implements(zeroerType) {
+			f = func(v reflect.Value) bool {
+				if v.CanInterface() {
+					return v.Interface().(zeroer).IsZero()
+				}
+				return structural(v)
+			}
+		}
+	case reflect.Float32, reflect.Float64:
+		f = func(v reflect.Value) bool {
+			return v.Float() == 0
+		}
+	case reflect.Complex64, reflect.Complex128:
+		f = func(v reflect.Value) bool {
+			return v.Complex() == 0
 		}
-		return z
+	default:
+		f = reflect.Value.IsZero
 	}
-	// Compare other types directly:
-	z := reflect.Zero(v.Type())
-	return v.Interface() == z.Interface()
+	zeroFuncs.Store(t, f)
+	return f
 }
 
-func (e *Encoder) encode(v reflect.Value, values *UrlValues) error {
+// encode encodes the struct v into s. prefix is the key prefix of the
+// fields, combined with their alias according to the NestedKeyStyle, and
+// path is the dotted path of the struct used to select fields.
+func (e *Encoder) encode(v reflect.Value, prefix, path string, s *encodeState) error {
 	if v.Kind() == reflect.Ptr {
 		v = v.Elem()
 	}
@@ -99,60 +1010,238 @@ func (e *Encoder) encode(v reflect.Value, values *UrlValues) error {
 	}
 	t := v.Type()
 
+	plan := s.plans.get(e, t, s.tag)
 	errors := MultiError{}
+	segments := make([]segment, 0, len(plan.fields))
+	ordered := false
+
+	for i := range plan.fields {
+		f := &plan.fields[i]
+		fv := v.Field(f.index)
+		field := s.field(f.name)
+		s.duplicate = nil
+		if f.alias == "-" {
+			s.skip(field, SkipIgnored)
+			continue
+		}
+		enc, elemEnc, custom := f.encoders(e, s)
+		nested := (isValidStructPointer(fv) && !custom) || (fv.Kind() == reflect.Struct && enc == nil)
+		if f.walk && !nested {
+			s.skip(field, SkipIgnored)
+			continue
+		}
+		name := f.alias
+		key := s.opts.NestedKeyStyle.key(prefix, name)
+		// Select the field. Flattened structs are always walked, as their
+		// fields are selected using the path of the parent.
+		fieldPath := path
+		if !f.flatten {
+			fieldPath = path + name + "."
+		}
+		if s.opts.excluded(path + name) {
+			s.skip(field, SkipExcluded)
+			continue
+		}
+		if !(nested && f.flatten) && !s.opts.included(path+name, nested) {
+			s.skip(field, SkipExcluded)
+			continue
+		}
+
+		// Remember where the values of this field start, to be able to move
+		// them according to the order option.
+		if f.orderErr != nil {
+			errors[s.path(f.name)] = f.orderErr
+			continue
+		}
+		if f.ordered {
+			ordered = true
+		}
+		segments = append(segments, segment{order: f.order, start: len(s.values)})
+
+		sliceStyle := s.opts.SliceKeyStyle
+		if f.slice != "" {
+			if !f.validSlice {
+				errors[s.path(f.name)] = fmt.Errorf("schema: invalid slice key style %q for %v", f.slice, key)
+				continue
+			}
+			sliceStyle = f.sliceStyle
+		}
+		if f.sep != "" && !f.validSep {
+			errors[s.path(f.name)] = fmt.Errorf("schema: invalid nested key separator %q for %v", f.sep, key)
+			continue
+		}
+		if f.duplicate != "" {
+			if !f.validDuplicate {
+				errors[s.path(f.name)] = fmt.Errorf("schema: invalid duplicate key mode %q for %v", f.duplicate, key)
+				continue
+			}
+			s.duplicate = &f.duplicateMode
+		}
+
+		if f.file {
+			if s.multipart {
+				s.addFile(key, fv)
+			} else {
+				s.skip(field, SkipFile)
+			}
+			continue
+		}
+
+		if f.required && f.isZero(fv) {
+			errors[s.path(f.name)] = MissingFieldError{Key: key, Field: s.path(f.name), Type: f.typ}
+			continue
+		}
+
+		// Encode the default value of zero fields.
+		if f.def != "" && f.isZero(fv) {
+			defaults := []string{f.def}
+			if fv.Kind() == reflect.Slice {
+				defaults = strings.Split(f.def, "|")
+				if f.delim != "" {
+					defaults = []string{joinDelim(defaults, f.delim)}
+				}
+			}
+			for j, value := range defaults {
+				k := key
+				if fv.Kind() == reflect.Slice {
+					k = sliceStyle.key(key, j)
+				}
+				s.add(field, k, value)
+			}
+			continue
+		}
+
+		if (f.omitNil || e.omitNil) && isNil(fv) {
+			s.skip(field, SkipOmitNil)
+			continue
+		}
 
-	for i := 0; i < v.NumField(); i++ {
-		name, opts := fieldAlias(t.Field(i), e.cache.tag)
-		if name == "-" {
+		if f.omitZero && f.isZeroValue(fv) {
+			s.skip(field, SkipOmitZero)
 			continue
 		}
 
+		omitEmpty := f.omitEmpty || ((s.opts.OmitEmpty || f.alwaysOmit) && !f.include)
+
 		// Encode struct pointer types if the field is a valid pointer and a struct.
-		if isValidStructPointer(v.Field(i)) && !e.hasCustomEncoder(v.Field(i).Type()) {
-			err := e.encode(v.Field(i).Elem(), values)
+		if isValidStructPointer(fv) && !custom {
+			err := e.encodeNested(fv.Elem(), f.nestedPrefix(s.opts.NestedKeyStyle, prefix), fieldPath, f.name, f.nestedStyle(s.opts.NestedKeyStyle), s)
 			if err != nil {
-				errors[v.Field(i).Elem().Type().String()] = err
+				errors.merge(err.(MultiError))
 			}
 			continue
 		}
 
-		encFunc := typeEncoder(v.Field(i).Type(), e.regenc)
-
 		// Encode non-slice types and custom implementations immediately.
-		if encFunc != nil {
-			value := encFunc(v.Field(i))
-			if opts.Contains("omitempty") && isZero(v.Field(i)) {
+		if enc != nil {
+			var value string
+			if app := f.appender(s); app != nil {
+				value = s.format(app, fv)
+			} else {
+				value = enc(fv)
+			}
+			if omitEmpty && f.isZero(fv) {
+				s.skip(field, SkipOmitEmpty)
+				continue
+			}
+			if e.checkEnums && f.enum != nil && fv.Kind() != reflect.Slice && !isNil(fv) && !slices.Contains(f.enum, value) {
+				errors[s.path(f.name)] = EnumError{Type: f.enumType, Value: value, Allowed: f.enum}
 				continue
 			}
 
-			*values = append(*values, UrlValue{Key: name, Value: value})
+			s.add(field, key, value)
 			continue
 		}
 
-		if v.Field(i).Type().Kind() == reflect.Struct {
-			err := e.encode(v.Field(i), values)
+		if fv.Kind() == reflect.Struct {
+			// The fields of an empty nested struct tagged omitempty are
+			// all skipped, whatever their own options.
+			if (f.omitEmpty || (f.alwaysOmit && !f.include)) && f.isZero(fv) {
+				s.skip(field, SkipOmitEmpty)
+				continue
+			}
+			err := e.encodeNested(fv, f.nestedPrefix(s.opts.NestedKeyStyle, prefix), fieldPath, f.name, f.nestedStyle(s.opts.NestedKeyStyle), s)
 			if err != nil {
-				errors[v.Field(i).Type().String()] = err
+				errors.merge(err.(MultiError))
+			}
+			continue
+		}
+
+		if fv.Kind() == reflect.Map && elemEnc != nil && f.keyEnc != nil {
+			if fv.Len() == 0 {
+				if omitEmpty {
+					s.skip(field, SkipOmitEmpty)
+				}
+				continue
+			}
+			style := f.nestedStyle(s.opts.NestedKeyStyle)
+			if err := encodeMap(fv, key, style, f.keyEnc, elemEnc, field, s); err != nil {
+				errors[s.path(f.name)] = err
 			}
 			continue
 		}
 
-		if v.Field(i).Type().Kind() == reflect.Slice {
-			encFunc = typeEncoder(v.Field(i).Type().Elem(), e.regenc)
+		var app appendFunc
+		if fv.Kind() == reflect.Slice {
+			enc, app = elemEnc, f.elemAppender(s)
 		}
 
-		if encFunc == nil {
-			errors[v.Field(i).Type().String()] = fmt.Errorf("schema: encoder not found for %v", v.Field(i))
+		if enc == nil {
+			if !e.skipUnsupported {
+				path := s.path(f.name)
+				errors[path] = UnsupportedTypeError{Field: path, Type: fv.Type(), Value: fmt.Sprint(fv)}
+			}
+			s.skip(field, SkipUnsupported)
 			continue
 		}
 
 		// Encode a slice.
-		if v.Field(i).Len() == 0 && opts.Contains("omitempty") {
+		if fv.Len() == 0 && f.emitEmpty {
+			s.add(field, key, "")
+			continue
+		}
+		if fv.Len() == 0 && omitEmpty {
+			s.skip(field, SkipOmitEmpty)
+			continue
+		}
+
+		if e.checkEnums && f.enum != nil {
+			if err := checkEnum(fv, enc, f); err != nil {
+				errors[s.path(f.name)] = err
+				continue
+			}
+		}
+		if f.delim != "" {
+			values := make([]string, fv.Len())
+			for j := range values {
+				values[j] = enc(fv.Index(j))
+			}
+			s.add(field, key, joinDelim(values, f.delim))
 			continue
 		}
+		for j := 0; j < fv.Len(); j++ {
+			var value string
+			if app != nil {
+				value = s.format(app, fv.Index(j))
+			} else {
+				value = enc(fv.Index(j))
+			}
+			s.add(field, sliceStyle.key(key, j), value)
+		}
+	}
+
+	if ordered {
+		sortSegments(s.values, segments)
+	}
 
-		for j := 0; j < v.Field(i).Len(); j++ {
-			*values = append(*values, UrlValue{Key: name, Value: encFunc(v.Field(i).Index(j))})
+	if hook := encodeHook(v); hook != nil && s.tag == "" && !s.noHooks {
+		if err := hook.EncodeHook(&s.values); err != nil {
+			// Nested structs are identified by their field path.
+			key := t.String()
+			if len(s.parents) > 0 {
+				key = strings.Join(s.parents, ".")
+			}
+			errors[key] = err
 		}
 	}
 
@@ -162,12 +1251,62 @@ func (e *Encoder) encode(v reflect.Value, values *UrlValues) error {
 	return nil
 }
 
-func (e *Encoder) hasCustomEncoder(t reflect.Type) bool {
-	_, exists := e.regenc[t]
+// segment is the range of values encoded from a single field.
+type segment struct {
+	order int
+	start int
+}
+
+// sortSegments stably reorders the values in segments by their order.
+// The values of a segment extend up to the start of the next one.
+func sortSegments(values UrlValues, segments []segment) {
+	if len(segments) == 0 {
+		return
+	}
+	first := segments[0].start
+	type block struct {
+		order  int
+		values UrlValues
+	}
+	blocks := make([]block, len(segments))
+	for i, s := range segments {
+		end := len(values)
+		if i+1 < len(segments) {
+			end = segments[i+1].start
+		}
+		blocks[i] = block{order: s.order, values: values[s.start:end]}
+	}
+	sort.SliceStable(blocks, func(i, j int) bool {
+		return blocks[i].order < blocks[j].order
+	})
+	sorted := make(UrlValues, 0, len(values)-first)
+	for _, b := range blocks {
+		sorted = append(sorted, b.values...)
+	}
+	copy(values[first:], sorted)
+}
+
+// encodeHook returns the EncodeHook implemented by v or its address, if any.
+func encodeHook(v reflect.Value) EncodeHook {
+	if v.CanAddr() && v.Addr().CanInterface() {
+		if hook, ok := v.Addr().Interface().(EncodeHook); ok {
+			return hook
+		}
+	}
+	if v.CanInterface() {
+		if hook, ok := v.Interface().(EncodeHook); ok {
+			return hook
+		}
+	}
+	return nil
+}
+
+func hasCustomEncoder(t reflect.Type, reg map[reflect.Type]encoderFunc) bool {
+	_, exists := reg[t]
 	return exists
 }
 
-func typeEncoder(t reflect.Type, reg map[reflect.Type]encoderFunc) encoderFunc {
+func typeEncoder(t reflect.Type, reg map[reflect.Type]encoderFunc, nilString string) encoderFunc {
 	if f, ok := reg[t]; ok {
 		return f
 	}
@@ -184,10 +1323,15 @@ func typeEncoder(t reflect.Type, reg map[reflect.Type]encoderFunc) encoderFunc {
 	case reflect.Float64:
 		return encodeFloat64
 	case reflect.Ptr:
-		f := typeEncoder(t.Elem(), reg)
+		f := typeEncoder(t.Elem(), reg, nilString)
+		if f == nil && t.Elem().Kind() != reflect.Struct {
+			// Valid pointers to structs are walked, and nil ones encoded
+			// as nilString.
+			return nil
+		}
 		return func(v reflect.Value) string {
 			if v.IsNil() {
-				return "null"
+				return nilString
 			}
 			return f(v.Elem())
 		}
@@ -198,6 +1342,145 @@ func typeEncoder(t reflect.Type, reg map[reflect.Type]encoderFunc) encoderFunc {
 	}
 }
 
+// checkEnum returns an EnumError for the first element of the slice v of
+// the field f, encoded by enc, which isn't allowed by its enum.
+func checkEnum(v reflect.Value, enc encoderFunc, f *fieldPlan) error {
+	for j := 0; j < v.Len(); j++ {
+		if ev := v.Index(j); !isNil(ev) {
+			if value := enc(ev); !slices.Contains(f.enum, value) {
+				return EnumError{Type: f.enumType, Value: value, Allowed: f.enum}
+			}
+		}
+	}
+	return nil
+}
+
+// mapKeyEncoder formats a map key.
+type mapKeyEncoder func(reflect.Value) (string, error)
+
+// mapKeyEncoderOf returns the mapKeyEncoder of the map keys of type t, or
+// nil if they can't be encoded. Keys are formatted by their MarshalText
+// method if they implement encoding.TextMarshaler, else as their kind if
+// they are strings or integers, even if they implement fmt.Stringer, else
+// by their String method if they implement fmt.Stringer.
+//
+// The keys decode back into the map if they are strings or integers, or
+// implement encoding.TextUnmarshaler as well, but not those formatted by
+// their String method.
+func mapKeyEncoderOf(t reflect.Type) mapKeyEncoder {
+	if t.Implements(textMarshalerType) {
+		return func(v reflect.Value) (string, error) {
+			b, err := v.Interface().(encoding.TextMarshaler).MarshalText()
+			return string(b), err
+		}
+	}
+	switch t.Kind() {
+	case reflect.String:
+		return func(v reflect.Value) (string, error) { return v.String(), nil }
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return func(v reflect.Value) (string, error) { return encodeInt(v), nil }
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return func(v reflect.Value) (string, error) { return encodeUint(v), nil }
+	}
+	if t.Implements(stringerType) {
+		return func(v reflect.Value) (string, error) {
+			return v.Interface().(fmt.Stringer).String(), nil
+		}
+	}
+	return nil
+}
+
+var (
+	textMarshalerType = reflect.TypeOf((*encoding.TextMarshaler)(nil)).Elem()
+	stringerType      = reflect.TypeOf((*fmt.Stringer)(nil)).Elem()
+)
+
+// encodeMap adds the entries of the map v of field to s, with their keys
+// formatted by keyEnc and put after key in the given style, or in brackets
+// for NestedKeyFlat, e.g. "scores[3]". Integer keys are sorted in numeric
+// order and the others by their formatted key.
+func encodeMap(v reflect.Value, key string, style NestedKeyStyle, keyEnc mapKeyEncoder, enc encoderFunc, field string, s *encodeState) error {
+	if style == NestedKeyFlat {
+		style = NestedKeyBrackets
+	}
+	type entry struct {
+		key  reflect.Value
+		name string
+	}
+	entries := make([]entry, 0, v.Len())
+	iter := v.MapRange()
+	for iter.Next() {
+		name, err := keyEnc(iter.Key())
+		if err != nil {
+			return fmt.Errorf("schema: encoding map key of %v: %w", key, err)
+		}
+		entries = append(entries, entry{iter.Key(), name})
+	}
+	slices.SortFunc(entries, func(a, b entry) int {
+		switch a.key.Kind() {
+		case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+			return cmp.Compare(a.key.Int(), b.key.Int())
+		case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+			return cmp.Compare(a.key.Uint(), b.key.Uint())
+		}
+		return strings.Compare(a.name, b.name)
+	})
+	for _, en := range entries {
+		s.add(field, style.key(key, en.name), enc(v.MapIndex(en.key)))
+	}
+	return nil
+}
+
+// appendFunc appends the encoding of a value to buf.
+type appendFunc func(buf []byte, v reflect.Value) []byte
+
+// typeAppender returns the appendFunc equivalent to the builtin encoder of
+// the numeric type t, or of pointers to it, and nil for other types or if
+// t has a registered encoder.
+func typeAppender(t reflect.Type, reg map[reflect.Type]encoderFunc, nilString string) appendFunc {
+	if _, ok := reg[t]; ok {
+		return nil
+	}
+	switch t.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return appendInt
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return appendUint
+	case reflect.Float32:
+		return appendFloat32
+	case reflect.Float64:
+		return appendFloat64
+	case reflect.Ptr:
+		f := typeAppender(t.Elem(), reg, nilString)
+		if f == nil {
+			return nil
+		}
+		return func(buf []byte, v reflect.Value) []byte {
+			if v.IsNil() {
+				return append(buf, nilString...)
+			}
+			return f(buf, v.Elem())
+		}
+	}
+	return nil
+}
+
+func appendInt(buf []byte, v reflect.Value) []byte {
+	return strconv.AppendInt(buf, v.Int(), 10)
+}
+
+func appendUint(buf []byte, v reflect.Value) []byte {
+	return strconv.AppendUint(buf, v.Uint(), 10)
+}
+
+func appendFloat32(buf []byte, v reflect.Value) []byte {
+	return strconv.AppendFloat(buf, v.Float(), 'f', 6, 32)
+}
+
+func appendFloat64(buf []byte, v reflect.Value) []byte {
+	return strconv.AppendFloat(buf, v.Float(), 'f', 6, 64)
+}
+
 func encodeBool(v reflect.Value) string {
 	return strconv.FormatBool(v.Bool())
 }
@@ -1,8 +1,16 @@
 package schema
 
 import (
+	"context"
+	"errors"
 	"fmt"
+	"net/netip"
+	"net/url"
 	"reflect"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
 	"testing"
 	"time"
 )
@@ -119,7 +127,7 @@ func TestEmpty(t *testing.T) {
 		F03: "three",
 	}
 
-	estr := "schema: encoder not found for <nil>"
+	estr := "schema: unsupported type func() for field F10"
 	vals := make(map[string][]string)
 	err := NewEncoder().Encode(s, vals)
 	if err.Error() != estr {
@@ -382,6 +390,53 @@ func TestEncoderWithOmitempty(t *testing.T) {
 	valsExist(t, "f09", []string{"test"}, vals)
 }
 
+func TestEncoderOmitemptyKeepsSetPointers(t *testing.T) {
+	type S struct {
+		Name  *string `schema:"name,omitempty"`
+		Count *int    `schema:"count,omitempty"`
+		Unset *string `schema:"unset,omitempty"`
+	}
+	name, count := "", 0
+	src := S{Name: &name, Count: &count}
+
+	for _, encoder := range []*Encoder{NewEncoder(), NewEncoder(WithOmitEmpty())} {
+		vals := map[string][]string{}
+		noError(t, encoder.Encode(src, vals))
+		valExists(t, "name", "", vals)
+		valExists(t, "count", "0", vals)
+		valNotExists(t, "unset", vals)
+	}
+}
+
+func TestEncoderOmitemptyNestedStruct(t *testing.T) {
+	type Filter struct {
+		Status string `schema:"status"`
+		Limit  int    `schema:"limit,include"`
+	}
+	type S struct {
+		Filter  Filter  `schema:"filter,omitempty"`
+		Search  Filter  `schema:"search"`
+		Pointer *Filter `schema:"pointer,omitempty"`
+	}
+	encoder := NewEncoder(WithNestedStyle(NestedKeyDotted))
+
+	values, report, err := encoder.EncodeWithReport(S{Pointer: &Filter{}})
+	noError(t, err)
+	want := UrlValues{{"search.status", ""}, {"search.limit", "0"}, {"pointer.status", ""}, {"pointer.limit", "0"}}
+	if !reflect.DeepEqual(values, want) {
+		t.Errorf("expected %v, got %v", want, values)
+	}
+	if skipped := (SkippedField{Field: "Filter", Reason: SkipOmitEmpty}); !slices.Contains(report.Skipped, skipped) {
+		t.Errorf("expected %v to be reported, got %v", skipped, report.Skipped)
+	}
+
+	values, err = encoder.EncodeValues(S{Filter: Filter{Limit: 10}})
+	noError(t, err)
+	if got := values.Get("filter.limit"); got != "10" {
+		t.Errorf("expected the non-empty filter to be encoded, got %v", values)
+	}
+}
+
 type E6 struct {
 	F01 *inner
 	F02 *inner
@@ -510,3 +565,1461 @@ func TestRegisterEncoderWithPtrType(t *testing.T) {
 	valExists(t, "DateStart", ss.DateStart.time.String(), vals)
 	valExists(t, "DateEnd", "", vals)
 }
+
+type E7 struct {
+	F01 string `schema:"f01"`
+	F02 int    `schema:"f02"`
+}
+
+func (s *E7) EncodeHook(values *UrlValues) error {
+	*values = append(*values, UrlValue{Key: "sum", Value: fmt.Sprint(len(s.F01) + s.F02)})
+	return nil
+}
+
+type E8 struct {
+	F01 string `schema:"f01"`
+}
+
+func (E8) EncodeHook(values *UrlValues) error {
+	return fmt.Errorf("hook failed")
+}
+
+func TestEncodeHooks(t *testing.T) {
+	encoder := NewEncoder()
+	encoder.BeforeEncode(func(src any, values *UrlValues) error {
+		*values = append(*values, UrlValue{Key: "api_key", Value: "secret"})
+		return nil
+	})
+	encoder.AfterEncode(func(src any, values *UrlValues) error {
+		*values = append(*values, UrlValue{Key: "count", Value: fmt.Sprint(len(*values))})
+		return nil
+	})
+
+	values, err := encoder.EncodeValues(&E7{F01: "abc", F02: 2})
+	noError(t, err)
+
+	expected := UrlValues{
+		{Key: "api_key", Value: "secret"},
+		{Key: "f01", Value: "abc"},
+		{Key: "f02", Value: "2"},
+		{Key: "sum", Value: "5"},
+		{Key: "count", Value: "4"},
+	}
+	if !reflect.DeepEqual(values, expected) {
+		t.Errorf("Expected %v, got %v", expected, values)
+	}
+}
+
+func TestEncodeHooksError(t *testing.T) {
+	vals := map[string][]string{}
+	if err := NewEncoder().Encode(E8{F01: "test"}, vals); err == nil {
+		t.Error("Expected error from EncodeHook, got nil")
+	}
+
+	encoder := NewEncoder()
+	encoder.BeforeEncode(func(src any, values *UrlValues) error {
+		return fmt.Errorf("before failed")
+	})
+	if _, err := encoder.EncodeValues(&E7{}); err == nil || err.Error() != "before failed" {
+		t.Errorf("Expected before hook error, got %v", err)
+	}
+}
+
+func TestEncoderWithOmitnil(t *testing.T) {
+	type S struct {
+		F01 int      `schema:"f01,omitnil"`
+		F02 string   `schema:"f02,omitnil"`
+		F03 *int     `schema:"f03,omitnil"`
+		F04 *int     `schema:"f04,omitnil"`
+		F05 []string `schema:"f05,omitnil"`
+		F06 *inner   `schema:"f06,omitnil"`
+	}
+	zero := 0
+	vals := map[string][]string{}
+	err := NewEncoder().Encode(S{F04: &zero}, vals)
+	noError(t, err)
+
+	valExists(t, "f01", "0", vals)
+	valExists(t, "f02", "", vals)
+	valNotExists(t, "f03", vals)
+	valExists(t, "f04", "0", vals)
+	valNotExists(t, "f05", vals)
+	valNotExists(t, "f06", vals)
+	valNotExists(t, "F12", vals)
+}
+
+type zeroAtOne int
+
+func (z zeroAtOne) IsZero() bool {
+	return z == 1
+}
+
+func TestEncoderRegisterIsZero(t *testing.T) {
+	type Priority int
+	type Range struct {
+		From int `schema:"from"`
+		To   int `schema:"to"`
+	}
+	type S struct {
+		Priority Priority `schema:"priority,omitempty"`
+		Level    Priority `schema:"level,omitzero"`
+		Range    Range    `schema:"range,omitempty"`
+		Name     string   `schema:"name,omitempty"`
+	}
+	src := S{Range: Range{To: 10}}
+	encodeRange := func(v reflect.Value) string {
+		r := v.Interface().(Range)
+		return fmt.Sprintf("%d-%d", r.From, r.To)
+	}
+
+	encoder := NewEncoder()
+	encoder.RegisterEncoder(Range{}, encodeRange)
+	vals := map[string][]string{}
+	noError(t, encoder.Encode(src, vals))
+	valNotExists(t, "priority", vals)
+	valNotExists(t, "level", vals)
+	valExists(t, "range", "0-10", vals)
+
+	encoder.RegisterIsZero(Priority(0), func(reflect.Value) bool { return false })
+	encoder.RegisterIsZero(Range{}, func(v reflect.Value) bool { return v.Field(0).Int() == 0 })
+	vals = map[string][]string{}
+	noError(t, encoder.Encode(src, vals))
+	valExists(t, "priority", "0", vals)
+	valExists(t, "level", "0", vals)
+	valNotExists(t, "range", vals)
+	valNotExists(t, "name", vals)
+
+	type R struct {
+		Range Range `schema:"range,required"`
+	}
+	if err := encoder.Encode(R{Range{To: 1}}, map[string][]string{}); err == nil {
+		t.Error("expected a MissingFieldError for the empty range")
+	}
+}
+
+func TestEncoderAlwaysOmitZero(t *testing.T) {
+	type NullString struct {
+		String string
+		Valid  bool
+	}
+	type S struct {
+		Created time.Time  `schema:"created"`
+		Updated *time.Time `schema:"updated"`
+		Deleted *time.Time `schema:"deleted"`
+		Seen    time.Time  `schema:"seen,include"`
+		Note    NullString `schema:"note"`
+		Count   int        `schema:"count"`
+	}
+	encodeTime := func(v reflect.Value) string {
+		if v.Kind() == reflect.Ptr && v.IsNil() {
+			return ""
+		}
+		return reflect.Indirect(v).Interface().(time.Time).Format(time.DateOnly)
+	}
+	encoder := NewEncoder()
+	encoder.RegisterEncoder(time.Time{}, encodeTime)
+	encoder.RegisterEncoder(&time.Time{}, encodeTime)
+	encoder.RegisterEncoder(NullString{}, func(v reflect.Value) string {
+		return v.Interface().(NullString).String
+	})
+	encoder.RegisterIsZero(NullString{}, func(v reflect.Value) bool { return !v.Interface().(NullString).Valid })
+	encoder.AlwaysOmitZero(time.Time{})
+	encoder.AlwaysOmitZero(NullString{})
+
+	var zero time.Time
+	vals := map[string][]string{}
+	noError(t, encoder.Encode(S{Updated: &zero, Note: NullString{String: "x"}}, vals))
+	valNotExists(t, "created", vals)
+	valExists(t, "updated", "0001-01-01", vals)
+	valNotExists(t, "deleted", vals)
+	valExists(t, "seen", "0001-01-01", vals)
+	valNotExists(t, "note", vals)
+	valExists(t, "count", "0", vals)
+
+	day := time.Date(2024, 5, 1, 0, 0, 0, 0, time.UTC)
+	vals = map[string][]string{}
+	noError(t, encoder.Encode(S{Created: day, Note: NullString{Valid: true}}, vals))
+	valExists(t, "created", "2024-05-01", vals)
+	valExists(t, "note", "", vals)
+}
+
+func TestEncoderWithOmitzero(t *testing.T) {
+	type S struct {
+		F01 int       `schema:"f01,omitzero"`
+		F02 []string  `schema:"f02,omitzero"`
+		F03 []string  `schema:"f03,omitzero"`
+		F04 *int      `schema:"f04,omitzero"`
+		F05 zeroAtOne `schema:"f05,omitzero"`
+		F06 zeroAtOne `schema:"f06,omitzero"`
+		F07 time.Time `schema:"f07,omitzero"`
+		F08 inner     `schema:"f08,omitzero"`
+	}
+	encoder := NewEncoder()
+	encoder.RegisterEncoder(time.Time{}, func(v reflect.Value) string {
+		return v.Interface().(time.Time).Format(time.RFC3339)
+	})
+	vals := map[string][]string{}
+	err := encoder.Encode(S{F03: []string{}, F05: 1}, vals)
+	noError(t, err)
+
+	valNotExists(t, "f01", vals)
+	valNotExists(t, "f02", vals)
+	valNotExists(t, "f04", vals)
+	valNotExists(t, "f05", vals)
+	valExists(t, "f06", "0", vals)
+	valNotExists(t, "f07", vals)
+	valNotExists(t, "F12", vals)
+}
+
+func TestZeroChecks(t *testing.T) {
+	type hidden struct {
+		n    int
+		tags []string
+	}
+	type withZeroer struct {
+		T time.Time
+	}
+	var nilPtr *int
+	one := 1
+	tests := []struct {
+		v         any
+		zero      bool
+		zeroValue bool
+	}{
+		{0, true, true},
+		{-0.0, true, true},
+		{"", true, true},
+		{"a", false, false},
+		{nilPtr, true, true},
+		{&one, false, false},
+		{[]string{}, true, false},
+		{[2]int{}, true, true},
+		{[2]int{0, 1}, false, false},
+		{hidden{tags: []string{}}, true, false},
+		{hidden{n: 1}, false, false},
+		{time.Time{}, true, true},
+		{withZeroer{}, true, true},
+		{zeroAtOne(1), false, true},
+		{func() {}, false, false},
+	}
+	for _, tc := range tests {
+		v := reflect.ValueOf(tc.v)
+		if got := isZero(v); got != tc.zero {
+			t.Errorf("isZero(%#v) = %v, want %v", tc.v, got, tc.zero)
+		}
+		if got := isZeroValue(v); got != tc.zeroValue {
+			t.Errorf("isZeroValue(%#v) = %v, want %v", tc.v, got, tc.zeroValue)
+		}
+	}
+}
+
+func TestEncoderSetOmitEmptyByDefault(t *testing.T) {
+	type S struct {
+		F01 int      `schema:"f01"`
+		F02 string   `schema:"f02"`
+		F03 *int     `schema:"f03"`
+		F04 []string `schema:"f04"`
+		F05 int      `schema:"f05,include"`
+		F06 string   `schema:"f06"`
+	}
+	encoder := NewEncoder()
+	encoder.SetOmitEmptyByDefault(true)
+
+	vals := map[string][]string{}
+	err := encoder.Encode(S{F06: "six"}, vals)
+	noError(t, err)
+
+	valsLength(t, 2, vals)
+	valExists(t, "f05", "0", vals)
+	valExists(t, "f06", "six", vals)
+}
+
+func TestEncoderRequiredField(t *testing.T) {
+	type S struct {
+		F01 int      `schema:"f01,required"`
+		F02 string   `schema:"f02,required"`
+		F03 []string `schema:"f03,required"`
+		F04 *int     `schema:"f04,required"`
+	}
+	zero := 0
+	vals := map[string][]string{}
+	err := NewEncoder().Encode(S{F01: 1, F04: &zero}, vals)
+
+	errs, ok := err.(MultiError)
+	if !ok {
+		t.Fatalf("Expected MultiError, got %#v", err)
+	}
+	if len(errs) != 2 {
+		t.Fatalf("Expected 2 errors, got %v", errs)
+	}
+	for _, key := range []string{"F02", "F03"} {
+		if _, ok := errs[key].(EmptyFieldError); !ok {
+			t.Errorf("Expected EmptyFieldError for %s, got %#v", key, errs[key])
+		}
+	}
+
+	vals = map[string][]string{}
+	err = NewEncoder().Encode(S{F01: 1, F02: "two", F03: []string{"three"}, F04: &zero}, vals)
+	noError(t, err)
+	valExists(t, "f04", "0", vals)
+}
+
+func TestEncoderDefaultValues(t *testing.T) {
+	type S struct {
+		F01 int      `schema:"f01,default=50"`
+		F02 string   `schema:"f02,default:asc"`
+		F03 []string `schema:"f03,default:a|b"`
+		F04 *int     `schema:"f04,default=1"`
+		F05 int      `schema:"f05,omitempty,default=5"`
+		F06 string   `schema:"f06,default=10:30"`
+	}
+	vals := map[string][]string{}
+	err := NewEncoder().Encode(S{F01: 20}, vals)
+	noError(t, err)
+
+	valExists(t, "f01", "20", vals)
+	valExists(t, "f02", "asc", vals)
+	valsExist(t, "f03", []string{"a", "b"}, vals)
+	valExists(t, "f04", "1", vals)
+	valExists(t, "f05", "5", vals)
+	valExists(t, "f06", "10:30", vals)
+}
+
+func TestNamingStrategies(t *testing.T) {
+	tests := []struct {
+		name  string
+		snake string
+		kebab string
+		camel string
+	}{
+		{"Name", "name", "name", "name"},
+		{"UserID", "user_id", "user-id", "userId"},
+		{"HTTPServer", "http_server", "http-server", "httpServer"},
+		{"PageSize2", "page_size2", "page-size2", "pageSize2"},
+		{"already_snake", "already_snake", "already-snake", "alreadySnake"},
+	}
+	for _, tc := range tests {
+		if got := SnakeCase(tc.name); got != tc.snake {
+			t.Errorf("SnakeCase(%q): expected %q, got %q", tc.name, tc.snake, got)
+		}
+		if got := KebabCase(tc.name); got != tc.kebab {
+			t.Errorf("KebabCase(%q): expected %q, got %q", tc.name, tc.kebab, got)
+		}
+		if got := CamelCase(tc.name); got != tc.camel {
+			t.Errorf("CamelCase(%q): expected %q, got %q", tc.name, tc.camel, got)
+		}
+	}
+}
+
+func TestEncoderSetNamingStrategy(t *testing.T) {
+	type S struct {
+		UserID   int
+		PageSize int    `schema:",omitempty"`
+		Sort     string `schema:"order_by"`
+	}
+	encoder := NewEncoder()
+	encoder.SetNamingStrategy(SnakeCase)
+
+	vals := map[string][]string{}
+	err := encoder.Encode(S{UserID: 1, PageSize: 10, Sort: "asc"}, vals)
+	noError(t, err)
+
+	valsLength(t, 3, vals)
+	valExists(t, "user_id", "1", vals)
+	valExists(t, "page_size", "10", vals)
+	valExists(t, "order_by", "asc", vals)
+}
+
+func TestEncoderSetAliasTags(t *testing.T) {
+	type S struct {
+		F01 string `schema:"f01" json:"json01"`
+		F02 string `form:"form02" json:"json02"`
+		F03 string `json:"json03,omitempty"`
+		F04 string `json:"-"`
+		F05 string
+	}
+	encoder := NewEncoder()
+	encoder.SetAliasTags("schema", "form", "json")
+
+	vals := map[string][]string{}
+	err := encoder.Encode(S{F01: "1", F02: "2", F04: "4", F05: "5"}, vals)
+	noError(t, err)
+
+	valsLength(t, 3, vals)
+	valExists(t, "f01", "1", vals)
+	valExists(t, "form02", "2", vals)
+	valExists(t, "F05", "5", vals)
+}
+
+func TestEncoderFieldOrder(t *testing.T) {
+	type S struct {
+		F01 string   `schema:"f01"`
+		F02 string   `schema:"f02,order=1"`
+		F03 []string `schema:"f03,order=-1"`
+		F04 string   `schema:"f04"`
+		F05 inner    `schema:"f05,order=-1"`
+		F06 string   `schema:"f06,order=1"`
+	}
+	values, err := NewEncoder().EncodeValues(S{"1", "2", []string{"3a", "3b"}, "4", inner{5}, "6"})
+	noError(t, err)
+
+	expected := UrlValues{
+		{Key: "f03", Value: "3a"},
+		{Key: "f03", Value: "3b"},
+		{Key: "F12", Value: "5"},
+		{Key: "f01", Value: "1"},
+		{Key: "f04", Value: "4"},
+		{Key: "f02", Value: "2"},
+		{Key: "f06", Value: "6"},
+	}
+	if !reflect.DeepEqual(values, expected) {
+		t.Errorf("Expected %v, got %v", expected, values)
+	}
+
+	type Invalid struct {
+		F01 string `schema:"f01,order=first"`
+	}
+	if _, err := NewEncoder().EncodeValues(Invalid{}); err == nil {
+		t.Error("Expected error for invalid order, got nil")
+	}
+}
+
+func TestEncoderSetKeyPrefix(t *testing.T) {
+	encoder := NewEncoder()
+	encoder.SetKeyPrefix("filter.")
+	encoder.BeforeEncode(func(src any, values *UrlValues) error {
+		*values = append(*values, UrlValue{Key: "page", Value: "1"})
+		return nil
+	})
+
+	vals := map[string][]string{}
+	err := encoder.Encode(E6{F01: &inner{1}, F02: &inner{2}}, vals)
+	noError(t, err)
+
+	valsLength(t, 2, vals)
+	valExists(t, "page", "1", vals)
+	valsExist(t, "filter.F12", []string{"1", "2"}, vals)
+}
+
+func TestEncoderNestedKeyStyle(t *testing.T) {
+	type Range struct {
+		From int `schema:"from"`
+		To   int `schema:"to"`
+	}
+	type Embedded struct {
+		Page int `schema:"page"`
+	}
+	type S struct {
+		Embedded
+		Price  Range  `schema:"price"`
+		Size   *Range `schema:"size"`
+		Weight Range  `schema:"weight,flatten"`
+	}
+	src := S{Embedded{1}, Range{2, 3}, &Range{4, 5}, Range{6, 7}}
+
+	vals := map[string][]string{}
+	noError(t, NewEncoder().Encode(src, vals))
+	valsLength(t, 3, vals)
+	valsExist(t, "from", []string{"2", "4", "6"}, vals)
+
+	encoder := NewEncoder()
+	encoder.SetNestedKeyStyle(NestedKeyDotted)
+	vals = map[string][]string{}
+	noError(t, encoder.Encode(src, vals))
+
+	valsLength(t, 7, vals)
+	valExists(t, "page", "1", vals)
+	valExists(t, "price.from", "2", vals)
+	valExists(t, "price.to", "3", vals)
+	valExists(t, "size.from", "4", vals)
+	valExists(t, "size.to", "5", vals)
+	valExists(t, "from", "6", vals)
+	valExists(t, "to", "7", vals)
+
+	dst := S{}
+	if err := NewDecoder().Decode(&dst, map[string][]string{
+		"page": vals["page"], "price.from": vals["price.from"], "price.to": vals["price.to"],
+		"size.from": vals["size.from"], "size.to": vals["size.to"],
+	}); err != nil {
+		t.Fatalf("Failed to decode: %v", err)
+	}
+	if dst.Page != 1 || dst.Price != src.Price || *dst.Size != *src.Size {
+		t.Errorf("Expected %+v, got %+v", src, dst)
+	}
+}
+
+func TestEncoderNestedKeySeparator(t *testing.T) {
+	type Range struct {
+		From int `schema:"from"`
+	}
+	type Filter struct {
+		Status string `schema:"status"`
+		Price  Range  `schema:"price"`
+		Size   Range  `schema:"size,sep=."`
+	}
+	type S struct {
+		Filter Filter `schema:"filter,sep=[]"`
+		Page   Range  `schema:"page"`
+	}
+	src := S{Filter: Filter{"open", Range{1}, Range{2}}, Page: Range{3}}
+
+	for _, style := range []NestedKeyStyle{NestedKeyFlat, NestedKeyDotted} {
+		encoder := NewEncoder(WithNestedStyle(style))
+		values, err := encoder.EncodeValues(src)
+		noError(t, err)
+		page := "from"
+		if style == NestedKeyDotted {
+			page = "page.from"
+		}
+		want := UrlValues{{"filter[status]", "open"}, {"filter[price][from]", "1"}, {"filter[size].from", "2"}, {page, "3"}}
+		if !reflect.DeepEqual(values, want) {
+			t.Errorf("style %v: expected %v, got %v", style, want, values)
+		}
+		if style == NestedKeyDotted {
+			var dst S
+			noError(t, NewDecoder().Decode(&dst, values.Values()))
+			if dst != src {
+				t.Errorf("expected %+v, got %+v", src, dst)
+			}
+		}
+
+		params, err := encoder.Describe(src)
+		noError(t, err)
+		for i, p := range params {
+			if p.Name != want[i].Key {
+				t.Errorf("style %v: expected parameter %q, got %q", style, want[i].Key, p.Name)
+			}
+		}
+	}
+
+	type Invalid struct {
+		Filter Filter `schema:"filter,sep=/"`
+	}
+	if err := NewEncoder().Encode(Invalid{}, map[string][]string{}); err == nil {
+		t.Error("expected an error for an invalid separator")
+	}
+	if err := NewEncoder().Check(Invalid{}); err == nil {
+		t.Error("expected Check to report the invalid separator")
+	}
+}
+
+func TestEncoderSliceKeyStyle(t *testing.T) {
+	type S struct {
+		F01 []string `schema:"f01"`
+		F02 []int    `schema:"f02,slice=indexed"`
+		F03 []string `schema:"f03,slice=repeat"`
+		F04 []string `schema:"f04,default:x|y"`
+	}
+	src := S{F01: []string{"a", "b"}, F02: []int{1, 2}, F03: []string{"c", "d"}}
+
+	encoder := NewEncoder()
+	encoder.SetSliceKeyStyle(SliceKeyBrackets)
+	values, err := encoder.EncodeValues(src)
+	noError(t, err)
+
+	expected := UrlValues{
+		{Key: "f01[]", Value: "a"},
+		{Key: "f01[]", Value: "b"},
+		{Key: "f02[0]", Value: "1"},
+		{Key: "f02[1]", Value: "2"},
+		{Key: "f03", Value: "c"},
+		{Key: "f03", Value: "d"},
+		{Key: "f04[]", Value: "x"},
+		{Key: "f04[]", Value: "y"},
+	}
+	if !reflect.DeepEqual(values, expected) {
+		t.Errorf("Expected %v, got %v", expected, values)
+	}
+
+	type Invalid struct {
+		F01 []string `schema:"f01,slice=php"`
+	}
+	if _, err := NewEncoder().EncodeValues(Invalid{}); err == nil {
+		t.Error("Expected error for invalid slice key style, got nil")
+	}
+}
+
+func TestEncoderEmitEmptySlice(t *testing.T) {
+	type S struct {
+		F01 []string `schema:"f01,emitempty"`
+		F02 []int    `schema:"f02,emitempty,omitempty"`
+		F03 []string `schema:"f03"`
+		F04 []string `schema:"f04,emitempty"`
+	}
+	vals := map[string][]string{}
+	err := NewEncoder().Encode(S{F02: []int{}, F04: []string{"a"}}, vals)
+	noError(t, err)
+
+	valsLength(t, 3, vals)
+	valExists(t, "f01", "", vals)
+	valExists(t, "f02", "", vals)
+	valNotExists(t, "f03", vals)
+	valExists(t, "f04", "a", vals)
+}
+
+func TestEncoderSetRemoveDuplicates(t *testing.T) {
+	type S struct {
+		F01 []string `schema:"tag"`
+		F02 string   `schema:"tag"`
+	}
+	encoder := NewEncoder()
+	encoder.SetRemoveDuplicates(true)
+	encoder.AfterEncode(func(src any, values *UrlValues) error {
+		values.Add("tag", "b")
+		return nil
+	})
+
+	vals := map[string][]string{}
+	err := encoder.Encode(S{F01: []string{"a", "b", "a"}, F02: "c"}, vals)
+	noError(t, err)
+	valsExist(t, "tag", []string{"a", "b", "c"}, vals)
+}
+
+func TestEncodeToString(t *testing.T) {
+	type S struct {
+		Q    string   `schema:"q"`
+		Tags []string `schema:"tag"`
+		Page int      `schema:"page"`
+	}
+	src := S{Q: "a b", Tags: []string{"x", "y"}, Page: 2}
+
+	encoder := NewEncoder()
+	str, err := encoder.EncodeToString(src)
+	noError(t, err)
+	if expected := "q=a+b&tag=x&tag=y&page=2"; str != expected {
+		t.Errorf("Expected %q, got %q", expected, str)
+	}
+
+	encoder.SetEscaper(EscapeRFC3986)
+	str, err = encoder.EncodeToString(src)
+	noError(t, err)
+	if expected := "q=a%20b&tag=x&tag=y&page=2"; str != expected {
+		t.Errorf("Expected %q, got %q", expected, str)
+	}
+
+	if _, err := encoder.EncodeToString("not a struct"); err == nil {
+		t.Error("Expected error, got nil")
+	}
+}
+
+func TestEncodeURL(t *testing.T) {
+	type S struct {
+		Q    string `schema:"q"`
+		Page int    `schema:"page,omitempty"`
+	}
+	encoder := NewEncoder()
+
+	u, err := encoder.EncodeURL(S{Q: "a b", Page: 2}, "https://api.test/search?z=1&a=%2F")
+	noError(t, err)
+	if expected := "https://api.test/search?z=1&a=%2F&q=a+b&page=2"; u.String() != expected {
+		t.Errorf("Expected %q, got %q", expected, u.String())
+	}
+
+	u, err = encoder.EncodeURL(S{Q: "x"}, "/search")
+	noError(t, err)
+	if expected := "/search?q=x"; u.String() != expected {
+		t.Errorf("Expected %q, got %q", expected, u.String())
+	}
+
+	u = &url.URL{Path: "/search", RawQuery: "a=1"}
+	noError(t, encoder.EncodeIntoURL(struct{}{}, u))
+	if expected := "/search?a=1"; u.String() != expected {
+		t.Errorf("Expected %q, got %q", expected, u.String())
+	}
+
+	if _, err := encoder.EncodeURL(S{}, "http://[::1"); err == nil {
+		t.Error("Expected error for invalid URL, got nil")
+	}
+}
+
+func TestEncodeWithOptions(t *testing.T) {
+	type Range struct {
+		From int `schema:"from"`
+		To   int `schema:"to"`
+	}
+	type Embedded struct {
+		Page int `schema:"page"`
+	}
+	type S struct {
+		Embedded
+		Q     string   `schema:"q"`
+		Tags  []string `schema:"tag"`
+		Price Range    `schema:"price"`
+		Size  *Range   `schema:"size"`
+		Note  string   `schema:"note,include"`
+	}
+	src := S{Embedded{1}, "x", []string{"a"}, Range{2, 3}, &Range{4, 5}, ""}
+
+	encoder := NewEncoder()
+	opts := encoder.Options()
+	opts.OmitEmpty = true
+	opts.KeyPrefix = "f."
+	opts.NestedKeyStyle = NestedKeyDotted
+	opts.SliceKeyStyle = SliceKeyBrackets
+	opts.Include = []string{"page", "tag", "price", "size.to", "note"}
+	opts.Exclude = []string{"price.to"}
+
+	values, err := encoder.EncodeWithOptions(src, opts)
+	noError(t, err)
+	expected := UrlValues{
+		{Key: "f.page", Value: "1"},
+		{Key: "f.tag[]", Value: "a"},
+		{Key: "f.price.from", Value: "2"},
+		{Key: "f.size.to", Value: "5"},
+		{Key: "f.note", Value: ""},
+	}
+	if !reflect.DeepEqual(values, expected) {
+		t.Errorf("Expected %v, got %v", expected, values)
+	}
+
+	// The Encoder settings are not modified.
+	values, err = encoder.EncodeValues(src)
+	noError(t, err)
+	if len(values) != 8 || values[0].Key != "page" {
+		t.Errorf("Unexpected values %v", values)
+	}
+}
+
+func TestNewEncoderOptions(t *testing.T) {
+	type S struct {
+		UserID int
+		Name   *string  `form:"name"`
+		Tags   []string `form:"tag"`
+		Empty  string   `form:"empty"`
+	}
+	encoder := NewEncoder(
+		WithTag("form"),
+		WithNilString(""),
+		WithSliceStyle(SliceKeyBrackets),
+		WithNamingStrategy(SnakeCase),
+		WithKeyPrefix("p."),
+		WithEscaper(EscapeRFC3986),
+	)
+	str, err := encoder.EncodeToString(S{UserID: 1, Tags: []string{"a b"}})
+	noError(t, err)
+	if expected := "p.user_id=1&p.name=&p.tag%5B%5D=a%20b&p.empty="; str != expected {
+		t.Errorf("Expected %q, got %q", expected, str)
+	}
+
+	encoder = NewEncoder(WithOmitEmpty(), WithNestedStyle(NestedKeyDotted))
+	values, err := encoder.EncodeValues(E6{F01: &inner{1}})
+	noError(t, err)
+	if expected := (UrlValues{{Key: "F01.F12", Value: "1"}}); !reflect.DeepEqual(values, expected) {
+		t.Errorf("Expected %v, got %v", expected, values)
+	}
+}
+
+func TestMarshalUnmarshal(t *testing.T) {
+	type S struct {
+		Q    string   `schema:"q"`
+		Tags []string `schema:"tag"`
+		Page int      `schema:"page"`
+	}
+	src := S{Q: "x", Tags: []string{"a", "b"}, Page: 3}
+
+	values, err := Marshal(src)
+	noError(t, err)
+	if expected := "q=x&tag=a&tag=b&page=3"; values.Encode() != expected {
+		t.Errorf("Expected %q, got %q", expected, values.Encode())
+	}
+
+	dst, err := Unmarshal[S](values.Values())
+	noError(t, err)
+	if !reflect.DeepEqual(src, dst) {
+		t.Errorf("Expected %v, got %v", src, dst)
+	}
+
+	if _, err := Unmarshal[int](values.Values()); err == nil {
+		t.Error("Expected error for non-struct type, got nil")
+	}
+}
+
+func TestGenericDecode(t *testing.T) {
+	type ListParams struct {
+		Q    string `schema:"q"`
+		Page int    `schema:"page"`
+	}
+	src := map[string][]string{"q": {"x"}, "page": {"2"}, "other": {"o"}}
+
+	if _, err := Decode[ListParams](src); err == nil {
+		t.Error("Expected error for unknown key, got nil")
+	}
+	dst, err := Decode[ListParams](src, WithIgnoreUnknownKeys())
+	noError(t, err)
+	if expected := (ListParams{Q: "x", Page: 2}); dst != expected {
+		t.Errorf("Expected %v, got %v", expected, dst)
+	}
+}
+
+func TestEncoderCheck(t *testing.T) {
+	type Node struct {
+		Value int   `schema:"value"`
+		Next  *Node `schema:"next"`
+	}
+	type Valid struct {
+		Q    string   `schema:"q"`
+		Tags []string `schema:"tag"`
+		Node Node     `schema:"node"`
+		Skip func()   `schema:"-"`
+	}
+	encoder := NewEncoder()
+	noError(t, encoder.Check(Valid{}))
+	noError(t, encoder.Check(reflect.TypeOf(&Valid{})))
+
+	type Invalid struct {
+		F01 func()         `schema:"f01"`
+		F02 chan int       `schema:"f02"`
+		F03 []func()       `schema:"f03"`
+		F04 string         `schema:"q"`
+		F05 inner          `schema:"f05"`
+		F06 int            `schema:"F12"`
+		F07 string         `schema:"f07,order=x"`
+		F08 []string       `schema:"f08,slice=php"`
+		F09 map[[2]int]int `schema:"f09"`
+	}
+	err := encoder.Check(&Invalid{})
+	errs, ok := err.(MultiError)
+	if !ok {
+		t.Fatalf("Expected MultiError, got %v", err)
+	}
+	for _, path := range []string{"F01", "F02", "F03", "F06", "F07", "F08", "F09"} {
+		if errs[path] == nil {
+			t.Errorf("Expected error for %s", path)
+		}
+	}
+	if len(errs) != 7 {
+		t.Errorf("Expected 7 errors, got %v", errs)
+	}
+
+	// Nested fields don't conflict with dotted keys.
+	encoder.SetNestedKeyStyle(NestedKeyDotted)
+	if errs := encoder.Check(&Invalid{}).(MultiError); errs["F06"] != nil {
+		t.Errorf("Unexpected conflict: %v", errs["F06"])
+	}
+
+	if err := encoder.Check(42); err == nil {
+		t.Error("Expected error for non-struct type, got nil")
+	}
+}
+
+func TestEncodeDiff(t *testing.T) {
+	type S struct {
+		Name  string   `schema:"name"`
+		Age   int      `schema:"age"`
+		Tags  []string `schema:"tag"`
+		Note  string   `schema:"note,omitempty"`
+		Inner inner
+	}
+	baseline := S{Name: "a", Age: 1, Tags: []string{"x", "y"}, Note: "n", Inner: inner{1}}
+	current := baseline
+	current.Age = 2
+	current.Tags = []string{"x", "z"}
+	current.Note = ""
+
+	encoder := NewEncoder()
+	values, err := encoder.EncodeDiff(&current, baseline)
+	noError(t, err)
+	expected := UrlValues{
+		{Key: "age", Value: "2"},
+		{Key: "tag", Value: "x"},
+		{Key: "tag", Value: "z"},
+		{Key: "note", Value: ""},
+	}
+	if !reflect.DeepEqual(values, expected) {
+		t.Errorf("Expected %v, got %v", expected, values)
+	}
+
+	values, err = encoder.EncodeDiff(baseline, baseline)
+	noError(t, err)
+	if len(values) != 0 {
+		t.Errorf("Expected no values, got %v", values)
+	}
+
+	if _, err := encoder.EncodeDiff(baseline, E1{}); err == nil {
+		t.Error("Expected error for different types, got nil")
+	}
+	if _, err := encoder.EncodeDiff(baseline, nil); err == nil {
+		t.Error("Expected error for nil baseline, got nil")
+	}
+}
+
+func TestEncodeOnlyExcept(t *testing.T) {
+	type Filter struct {
+		Status string `schema:"status"`
+		Owner  string `schema:"owner"`
+	}
+	type S struct {
+		Q      string `schema:"q"`
+		Page   int    `schema:"page"`
+		Filter Filter `schema:"filter"`
+	}
+	src := S{Q: "x", Page: 2, Filter: Filter{"open", "me"}}
+	encoder := NewEncoder()
+
+	values, err := encoder.EncodeOnly(src, "q", "filter.status")
+	noError(t, err)
+	expected := UrlValues{{Key: "q", Value: "x"}, {Key: "status", Value: "open"}}
+	if !reflect.DeepEqual(values, expected) {
+		t.Errorf("Expected %v, got %v", expected, values)
+	}
+
+	values, err = encoder.EncodeExcept(src, "page", "filter.owner")
+	noError(t, err)
+	if !reflect.DeepEqual(values, expected) {
+		t.Errorf("Expected %v, got %v", expected, values)
+	}
+
+	values, err = encoder.EncodeExcept(src, "filter")
+	noError(t, err)
+	if expected := (UrlValues{{Key: "q", Value: "x"}, {Key: "page", Value: "2"}}); !reflect.DeepEqual(values, expected) {
+		t.Errorf("Expected %v, got %v", expected, values)
+	}
+}
+
+func TestEncoderClone(t *testing.T) {
+	type S struct {
+		Time time.Time `json:"time"`
+		Name string    `json:"name"`
+	}
+	base := NewEncoder(WithTag("json"))
+	base.RegisterEncoder(time.Time{}, func(v reflect.Value) string {
+		return v.Interface().(time.Time).Format("2006-01-02")
+	})
+
+	clone := base.Clone()
+	clone.SetKeyPrefix("p.")
+	clone.SetAliasTag("schema")
+	clone.RegisterEncoder("", func(v reflect.Value) string { return "name" })
+
+	src := S{Time: time.Date(2020, 1, 2, 0, 0, 0, 0, time.UTC), Name: "x"}
+	str, err := base.EncodeToString(src)
+	noError(t, err)
+	if expected := "time=2020-01-02&name=x"; str != expected {
+		t.Errorf("Expected %q, got %q", expected, str)
+	}
+
+	str, err = clone.EncodeToString(src)
+	noError(t, err)
+	if expected := "p.Time=2020-01-02&p.Name=name"; str != expected {
+		t.Errorf("Expected %q, got %q", expected, str)
+	}
+}
+
+type localeKey struct{}
+
+type price float64
+
+func TestEncodeContext(t *testing.T) {
+	type S struct {
+		Price price `schema:"price"`
+		Other price `schema:"other,omitempty"`
+	}
+	encoder := NewEncoder()
+	encoder.RegisterEncoderContext(price(0), func(ctx context.Context, v reflect.Value) string {
+		if locale, _ := ctx.Value(localeKey{}).(string); locale == "de" {
+			return strings.Replace(fmt.Sprintf("%.2f", v.Float()), ".", ",", 1)
+		}
+		return fmt.Sprintf("%.2f", v.Float())
+	})
+
+	ctx := context.WithValue(context.Background(), localeKey{}, "de")
+	vals := map[string][]string{}
+	noError(t, encoder.EncodeContext(ctx, S{Price: 1.5}, vals))
+	valsLength(t, 1, vals)
+	valExists(t, "price", "1,50", vals)
+
+	values, err := encoder.EncodeValues(S{Price: 1.5})
+	noError(t, err)
+	if values.Get("price") != "1.50" {
+		t.Errorf("Expected 1.50, got %v", values)
+	}
+
+	encoder.RegisterEncoder(price(0), func(v reflect.Value) string { return "plain" })
+	values, err = encoder.EncodeValuesContext(ctx, S{Price: 1.5})
+	noError(t, err)
+	if values.Get("price") != "plain" {
+		t.Errorf("Expected plain encoder to replace the context encoder, got %v", values)
+	}
+}
+
+func TestEncoderConcurrentRegister(t *testing.T) {
+	type Item struct {
+		Name  string    `schema:"name"`
+		Price int       `schema:"price"`
+		When  time.Time `schema:"when"`
+	}
+	e := NewEncoder()
+	src := Item{Name: "a", Price: 2, When: time.Date(2024, 1, 2, 0, 0, 0, 0, time.UTC)}
+
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 100; j++ {
+				values, err := e.EncodeValues(src)
+				if err != nil {
+					// time.Time has no encoder until it is registered.
+					continue
+				}
+				if got := values.Get("when"); got != "2024-01-02" {
+					t.Errorf("got when %q", got)
+					return
+				}
+			}
+		}()
+	}
+	for j := 0; j < 50; j++ {
+		e.RegisterEncoder(time.Time{}, func(v reflect.Value) string {
+			return v.Interface().(time.Time).Format(time.DateOnly)
+		})
+		e.RegisterEncoderContext(0, func(ctx context.Context, v reflect.Value) string {
+			return encodeInt(v)
+		})
+	}
+	wg.Wait()
+}
+
+func TestEncodeNumbersArena(t *testing.T) {
+	type S struct {
+		Ints   []int64   `schema:"i"`
+		Floats []float32 `schema:"f"`
+		Ptr    *uint     `schema:"p"`
+		Nil    *int      `schema:"n"`
+	}
+	src := S{}
+	var want UrlValues
+	for i := 0; i < 200; i++ {
+		n := int64(i*7919 - 500)
+		src.Ints = append(src.Ints, n)
+		want = append(want, UrlValue{"i", strconv.FormatInt(n, 10)})
+	}
+	for i := 0; i < 200; i++ {
+		f := float32(i) / 3
+		src.Floats = append(src.Floats, f)
+		want = append(want, UrlValue{"f", strconv.FormatFloat(float64(f), 'f', 6, 32)})
+	}
+	p := uint(123456)
+	src.Ptr = &p
+	want = append(want, UrlValue{"p", "123456"}, UrlValue{"n", "null"})
+
+	values, err := NewEncoder().EncodeValues(src)
+	noError(t, err)
+	if !reflect.DeepEqual(values, want) {
+		t.Errorf("Expected %v, got %v", want, values)
+	}
+}
+
+func BenchmarkEncodeNumbers(b *testing.B) {
+	type Order struct {
+		ID       int64     `schema:"id"`
+		Customer uint32    `schema:"customer"`
+		Amount   float64   `schema:"amount"`
+		Tax      float32   `schema:"tax"`
+		Items    []int     `schema:"item"`
+		Prices   []float64 `schema:"price"`
+		Created  *int64    `schema:"created"`
+	}
+	created := int64(1700000000)
+	src := Order{
+		ID: 123456789, Customer: 4242, Amount: 199.99, Tax: 19.5,
+		Items: []int{1001, 1002, 1003}, Prices: []float64{49.5, 75.25, 75.24},
+		Created: &created,
+	}
+	e := NewEncoder()
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		if _, err := e.EncodeValues(src); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func TestEncodeTypedErrors(t *testing.T) {
+	type inner struct {
+		Fn   func()  `schema:"fn"`
+		Name *string `schema:"name,required"`
+	}
+	type S struct {
+		Inner inner `schema:"inner"`
+	}
+	vals := map[string][]string{}
+	errs := NewEncoder().Encode(S{}, vals).(MultiError)
+
+	unsupported, ok := errs["Inner.Fn"].(UnsupportedTypeError)
+	if !ok || unsupported.Field != "Inner.Fn" || unsupported.Type != reflect.TypeOf(func() {}) || unsupported.Value != "<nil>" {
+		t.Errorf("unexpected error %#v", errs["Inner.Fn"])
+	}
+	missing := MissingFieldError{Key: "name", Field: "Inner.Name", Type: reflect.TypeOf((*string)(nil))}
+	if errs["Inner.Name"] != missing {
+		t.Errorf("expected %#v, got %#v", missing, errs["Inner.Name"])
+	}
+}
+
+func TestEncodeNestedErrorPaths(t *testing.T) {
+	type dateRange struct {
+		From func() `schema:"from"`
+	}
+	type filter struct {
+		Created dateRange  `schema:"created"`
+		Updated *dateRange `schema:"updated"`
+	}
+	type S struct {
+		Filter filter `schema:"filter"`
+	}
+	vals := map[string][]string{}
+	err := NewEncoder().Encode(S{Filter: filter{Updated: &dateRange{}}}, vals)
+	errs, ok := err.(MultiError)
+	if !ok || len(errs) != 2 {
+		t.Fatalf("expected 2 errors, got %#v", err)
+	}
+	for _, path := range []string{"Filter.Created.From", "Filter.Updated.From"} {
+		if e, ok := errs[path].(UnsupportedTypeError); !ok || e.Field != path {
+			t.Errorf("expected an UnsupportedTypeError for %s, got %#v", path, errs[path])
+		}
+	}
+}
+
+func TestEncodeNestedRequiredErrorPaths(t *testing.T) {
+	type dateRange struct {
+		From string `schema:"from,required"`
+	}
+	type filter struct {
+		Created dateRange `schema:"created"`
+		Updated dateRange `schema:"updated"`
+	}
+	// The keys of both fields are "from" in the NestedKeyFlat style.
+	err := NewEncoder().Encode(filter{}, map[string][]string{})
+	errs, ok := err.(MultiError)
+	if !ok || len(errs) != 2 {
+		t.Fatalf("expected 2 errors, got %#v", err)
+	}
+	for _, path := range []string{"Created.From", "Updated.From"} {
+		if e, ok := errs[path].(MissingFieldError); !ok || e.Field != path || e.Key != "from" {
+			t.Errorf("expected a MissingFieldError for %s, got %#v", path, errs[path])
+		}
+	}
+}
+
+func TestEncodeStrictKeys(t *testing.T) {
+	type Page struct {
+		Size int `schema:"size"`
+	}
+	type S struct {
+		Page
+		Limit int      `schema:"size"`
+		Tags  []string `schema:"tag"`
+	}
+	src := S{Page: Page{Size: 1}, Limit: 2, Tags: []string{"a", "b"}}
+
+	vals := map[string][]string{}
+	noError(t, NewEncoder().Encode(src, vals))
+	valsExist(t, "size", []string{"1", "2"}, vals)
+
+	e := NewEncoder()
+	e.SetStrictKeys(true)
+	e.SetKeyPrefix("q.")
+	err := e.Encode(src, map[string][]string{})
+	errs, ok := err.(MultiError)
+	if !ok || len(errs) != 1 {
+		t.Fatalf("expected 1 error, got %v", err)
+	}
+	want := KeyConflictError{Key: "q.size", Fields: [2]string{"Page.Size", "Limit"}}
+	if errs["Limit"] != want {
+		t.Errorf("expected %#v, got %#v", want, errs["Limit"])
+	}
+	var conflict KeyConflictError
+	if !errors.As(err, &conflict) {
+		t.Error("expected errors.As to find the KeyConflictError")
+	}
+}
+
+func TestEncodeDuplicateKeys(t *testing.T) {
+	type S struct {
+		Page int      `schema:"page"`
+		Tags []string `schema:"tag"`
+		Sort string   `schema:"sort,duplicate=replace"`
+	}
+	src := S{Page: 2, Tags: []string{"c"}, Sort: "asc"}
+	existing := func() map[string][]string {
+		return map[string][]string{"page": {"1"}, "tag": {"a", "b"}, "sort": {"desc"}, "q": {"x"}}
+	}
+
+	vals := existing()
+	noError(t, NewEncoder().Encode(src, vals))
+	valsExist(t, "page", []string{"1", "2"}, vals)
+	valsExist(t, "tag", []string{"a", "b", "c"}, vals)
+	valsExist(t, "sort", []string{"asc"}, vals)
+
+	e := NewEncoder(WithDuplicateKeys(DuplicateReplace))
+	vals = existing()
+	noError(t, e.Encode(src, vals))
+	valsExist(t, "page", []string{"2"}, vals)
+	valsExist(t, "tag", []string{"c"}, vals)
+	valsExist(t, "q", []string{"x"}, vals)
+
+	values := UrlValues{{"page", "1"}, {"q", "x"}, {"tag", "a"}}
+	noError(t, e.EncodeValuesInto(src, &values))
+	if want := (UrlValues{{"q", "x"}, {"page", "2"}, {"tag", "c"}, {"sort", "asc"}}); !reflect.DeepEqual(values, want) {
+		t.Errorf("expected %v, got %v", want, values)
+	}
+
+	e.SetDuplicateKeys(DuplicateError)
+	vals = existing()
+	err := e.Encode(src, vals)
+	errs, ok := err.(MultiError)
+	if !ok || len(errs) != 2 {
+		t.Fatalf("expected 2 errors, got %v", err)
+	}
+	if want := (DuplicateKeyError{Key: "tag"}); errs["tag"] != want {
+		t.Errorf("expected %#v, got %#v", want, errs["tag"])
+	}
+	if !reflect.DeepEqual(vals, existing()) {
+		t.Errorf("expected the values to be unchanged, got %v", vals)
+	}
+	values = UrlValues{{"page", "1"}}
+	if err := e.EncodeValuesInto(src, &values); err == nil || len(values) != 1 {
+		t.Errorf("expected an error and unchanged values, got %v and %v", err, values)
+	}
+
+	type Invalid struct {
+		Sort string `schema:"sort,duplicate=merge"`
+	}
+	if err := NewEncoder().Encode(Invalid{}, map[string][]string{}); err == nil {
+		t.Error("expected an error for an invalid duplicate key mode")
+	}
+	if err := NewEncoder().Check(Invalid{}); err == nil {
+		t.Error("expected Check to report the invalid duplicate key mode")
+	}
+
+	// The tag option applies to the prefixed keys.
+	type Prefixed struct {
+		Sort  string `schema:"sort,duplicate=replace"`
+		Order string `schema:"order,duplicate=error"`
+	}
+	e = NewEncoder(WithKeyPrefix("f_"))
+	vals = map[string][]string{"f_sort": {"old"}}
+	noError(t, e.Encode(Prefixed{Sort: "new"}, vals))
+	valsExist(t, "f_sort", []string{"new"}, vals)
+	err = e.Encode(Prefixed{Order: "asc"}, map[string][]string{"f_order": {"desc"}})
+	if errs, _ := err.(MultiError); errs["f_order"] != (DuplicateKeyError{Key: "f_order"}) {
+		t.Errorf("expected a DuplicateKeyError for f_order, got %#v", err)
+	}
+}
+
+func TestEncoderMaxEncodedLength(t *testing.T) {
+	type S struct {
+		Q    string   `schema:"q"`
+		Tags []string `schema:"tag"`
+	}
+	src := S{Q: "a b/é", Tags: []string{"x", "y"}}
+	query, err := NewEncoder().EncodeToString(src)
+	noError(t, err)
+
+	encoder := NewEncoder(WithMaxEncodedLength(len(query)))
+	got, err := encoder.EncodeToString(src)
+	noError(t, err)
+	if got != query {
+		t.Errorf("expected %q, got %q", query, got)
+	}
+
+	encoder.SetMaxEncodedLength(len(query) - 1)
+	_, err = encoder.EncodeURL(src, "https://example.com/search?v=1")
+	want := LengthExceededError{Length: len(query), Max: len(query) - 1}
+	if err != want {
+		t.Errorf("expected %#v, got %#v", want, err)
+	}
+	if err := encoder.Encode(src, map[string][]string{}); err != want {
+		t.Errorf("expected %#v, got %#v", want, err)
+	}
+
+	encoder.SetEscaper(EscapeRFC3986)
+	query = UrlValues{{"q", src.Q}, {"tag", "x"}, {"tag", "y"}}.EncodeRFC3986()
+	encoder.SetMaxEncodedLength(len(query))
+	_, err = encoder.EncodeToString(src)
+	noError(t, err)
+	encoder.SetMaxEncodedLength(len(query) - 1)
+	if _, err := encoder.EncodeToString(src); !errors.As(err, &want) || want.Length != len(query) {
+		t.Errorf("expected a LengthExceededError of %d bytes, got %v", len(query), err)
+	}
+}
+
+func TestEncodeSkipUnsupported(t *testing.T) {
+	type S struct {
+		Name  string     `schema:"name"`
+		Done  chan bool  `schema:"done"`
+		Funcs []func()   `schema:"funcs"`
+		Meta  complex128 `schema:"meta"`
+	}
+	src := S{Name: "n", Funcs: []func(){func() {}}}
+	e := NewEncoder()
+	if err := e.Encode(src, map[string][]string{}); err == nil {
+		t.Error("expected an error for unsupported fields")
+	}
+
+	e.SetSkipUnsupported(true)
+	vals := map[string][]string{}
+	noError(t, e.Encode(src, vals))
+	valExists(t, "name", "n", vals)
+	valsLength(t, 1, vals)
+	noError(t, e.Check(src))
+	noError(t, e.Clone().Encode(src, map[string][]string{}))
+}
+
+func TestEncodePointerToUnsupported(t *testing.T) {
+	type S struct {
+		Name string    `schema:"name"`
+		Ch   *chan int `schema:"ch"`
+		Fns  []*func() `schema:"fns"`
+	}
+	ch, fn := make(chan int), func() {}
+	for _, src := range []S{{Name: "n"}, {Name: "n", Ch: &ch, Fns: []*func(){&fn}}} {
+		e := NewEncoder()
+		errs, ok := e.Encode(src, map[string][]string{}).(MultiError)
+		if !ok || len(errs) != 2 {
+			t.Fatalf("expected 2 errors, got %v", errs)
+		}
+		for _, path := range []string{"Ch", "Fns"} {
+			if _, ok := errs[path].(UnsupportedTypeError); !ok {
+				t.Errorf("expected an UnsupportedTypeError for %s, got %#v", path, errs[path])
+			}
+		}
+		if errs, ok := e.Check(src).(MultiError); !ok || len(errs) != 2 {
+			t.Errorf("expected 2 errors from Check, got %v", errs)
+		}
+
+		e.SetSkipUnsupported(true)
+		vals := map[string][]string{}
+		noError(t, e.Encode(src, vals))
+		valExists(t, "name", "n", vals)
+		valsLength(t, 1, vals)
+		noError(t, e.Check(src))
+	}
+}
+
+// mapPoint is a map key formatted by its String method.
+type mapPoint struct{ X, Y int }
+
+func (p mapPoint) String() string {
+	return strconv.Itoa(p.X) + ":" + strconv.Itoa(p.Y)
+}
+
+func TestEncodeMapFields(t *testing.T) {
+	type S struct {
+		Scores map[int]string        `schema:"scores"`
+		Months map[time.Month]int    `schema:"month"`
+		Hosts  map[netip.Addr]string `schema:"host"`
+		Points map[mapPoint]int      `schema:"point"`
+		Empty  map[string]int        `schema:"empty,omitempty"`
+	}
+	src := S{
+		Scores: map[int]string{10: "b", 3: "a"},
+		Months: map[time.Month]int{time.March: 3, time.January: 1},
+		Hosts:  map[netip.Addr]string{netip.MustParseAddr("10.0.0.1"): "db"},
+		Points: map[mapPoint]int{{1, 2}: 3},
+	}
+	e := NewEncoder()
+	vals, err := e.EncodeValues(src)
+	noError(t, err)
+	// Integer keys are encoded as integers, even if they implement
+	// fmt.Stringer as time.Month does, so that they decode back.
+	want := UrlValues{
+		{Key: "scores[3]", Value: "a"},
+		{Key: "scores[10]", Value: "b"},
+		{Key: "month[1]", Value: "1"},
+		{Key: "month[3]", Value: "3"},
+		{Key: "host[10.0.0.1]", Value: "db"},
+		{Key: "point[1:2]", Value: "3"},
+	}
+	if !reflect.DeepEqual(vals, want) {
+		t.Errorf("expected %v, got %v", want, vals)
+	}
+	noError(t, e.Check(src))
+
+	e.SetNestedKeyStyle(NestedKeyDotted)
+	m := map[string][]string{}
+	noError(t, e.Encode(src, m))
+	valExists(t, "scores.10", "b", m)
+	valExists(t, "month.3", "3", m)
+
+	// The keys decode back into the map, except those formatted by their
+	// String method.
+	d := NewDecoder()
+	d.IgnoreUnknownKeys(true)
+	var dst S
+	noError(t, d.Decode(&dst, m))
+	if want := (S{Scores: src.Scores, Months: src.Months, Hosts: src.Hosts}); !reflect.DeepEqual(dst, want) {
+		t.Errorf("expected %v, got %v", want, dst)
+	}
+}
+
+func TestEncoderRegisterEnum(t *testing.T) {
+	type color string
+	type S struct {
+		Color  color   `schema:"color"`
+		Colors []color `schema:"colors"`
+		Ptr    *color  `schema:"ptr"`
+		Other  color   `schema:"other,omitempty"`
+	}
+	e := NewEncoder()
+	e.RegisterEnum(color(""), "red", "green")
+	src := S{Color: "blue", Colors: []color{"red", "pink"}}
+
+	// Values are only checked with SetCheckEnums.
+	vals := map[string][]string{}
+	noError(t, e.Encode(src, vals))
+	valExists(t, "color", "blue", vals)
+
+	e.SetCheckEnums(true)
+	errs, ok := e.Encode(src, map[string][]string{}).(MultiError)
+	if !ok || len(errs) != 2 {
+		t.Fatalf("expected 2 errors, got %v", errs)
+	}
+	for key, value := range map[string]string{"Color": "blue", "Colors": "pink"} {
+		if e, ok := errs[key].(EnumError); !ok || e.Value != value {
+			t.Errorf("expected an EnumError for %s=%s, got %v", key, value, errs[key])
+		}
+	}
+	vals = map[string][]string{}
+	noError(t, e.Clone().Encode(S{Color: "green", Colors: []color{"red"}}, vals))
+	valsLength(t, 3, vals)
+	valExists(t, "ptr", "null", vals)
+
+	params, err := e.Describe(S{})
+	noError(t, err)
+	for _, p := range params {
+		want := []string{"red", "green"}
+		if !reflect.DeepEqual(p.Enum, want) {
+			t.Errorf("expected the enum %v for %s, got %v", want, p.Name, p.Enum)
+		}
+	}
+}
+
+func TestEncodeNestedKeyBrackets(t *testing.T) {
+	type Range struct {
+		From int `schema:"from"`
+	}
+	type Filter struct {
+		Status string `schema:"status"`
+		Range  Range  `schema:"range"`
+	}
+	type S struct {
+		Filter Filter `schema:"filter"`
+		Page   int    `schema:"page"`
+	}
+	src := S{Filter: Filter{Status: "open", Range: Range{From: 3}}, Page: 2}
+	e := NewEncoder()
+	e.SetNestedKeyStyle(NestedKeyBrackets)
+	vals := map[string][]string{}
+	noError(t, e.Encode(src, vals))
+	valExists(t, "filter[status]", "open", vals)
+	valExists(t, "filter[range][from]", "3", vals)
+	valExists(t, "page", "2", vals)
+	valsLength(t, 3, vals)
+
+	var dst S
+	noError(t, NewDecoder().Decode(&dst, vals))
+	if dst != src {
+		t.Errorf("expected %+v, got %+v", src, dst)
+	}
+}
@@ -0,0 +1,104 @@
+package schema
+
+import (
+	"reflect"
+	"slices"
+	"strings"
+)
+
+// GeneratedEncoder is implemented by structs with an encoding method
+// generated by cmd/schemagen. The Encoder calls EncodeSchema instead of
+// walking the struct with reflection, unless one of its settings changes
+// the encoding: SetAliasTag, SetNamingStrategy, SetNilString,
+// SetSliceKeyStyle, SetOmitEmptyByDefault, SetStrictKeys, registered
+// encoders or zero checks, AlwaysOmitZero, or the Include and Exclude
+// options. Hooks and the key prefix still apply.
+type GeneratedEncoder interface {
+	EncodeSchema(values *UrlValues) error
+}
+
+// GeneratedDecoder is implemented by structs with a decoding method
+// generated by cmd/schemagen. The Decoder calls DecodeSchema instead of
+// walking the struct with reflection, unless one of its settings changes
+// the decoding: SetAliasTag, SetNamingStrategy, ZeroEmpty, EmptyValues,
+// IgnoreUnknownKeys, WeaklyTypedInput, MaxSliceLength, BoolValues or
+// registered converters. Sources with keys deeper than MaxDepth are also
+// decoded with reflection.
+type GeneratedDecoder interface {
+	DecodeSchema(src map[string][]string) error
+}
+
+// generated returns the generated encoder of src, if any, and if the
+// settings of s and the Encoder match the generated code.
+func (e *Encoder) generated(src any, s *encodeState) (GeneratedEncoder, bool) {
+	g, ok := src.(GeneratedEncoder)
+	if !ok || s.tag != "" || s.multipart || s.report != nil {
+		return nil, false
+	}
+	if v := reflect.ValueOf(src); v.Kind() == reflect.Ptr && v.IsNil() {
+		return nil, false
+	}
+	if e.naming != nil || e.nilString != "null" || e.omitNil || !slices.Equal(e.cache.tags, []string{"schema"}) {
+		return nil, false
+	}
+	if len(s.regenc) > 0 || len(s.plans.regzero) > 0 || len(s.plans.omitZero) > 0 || s.plans.contextual {
+		return nil, false
+	}
+	if e.checkEnums && len(s.plans.enums) > 0 {
+		return nil, false
+	}
+	o := s.opts
+	if o.OmitEmpty || o.StrictKeys || o.SliceKeyStyle != SliceKeyRepeat || len(o.Include) > 0 || len(o.Exclude) > 0 {
+		return nil, false
+	}
+	return g, true
+}
+
+// encodeGenerated encodes src with its generated encoder, then calls its
+// EncodeHook like encode does.
+func encodeGenerated(g GeneratedEncoder, src any, s *encodeState) error {
+	err := g.EncodeSchema(&s.values)
+	v := reflect.ValueOf(src)
+	if v.Kind() == reflect.Ptr {
+		v = v.Elem()
+	}
+	hook := encodeHook(v)
+	if hook == nil {
+		return err
+	}
+	errors, _ := err.(MultiError)
+	if errors == nil {
+		if err != nil {
+			return err
+		}
+		errors = MultiError{}
+	}
+	if herr := hook.EncodeHook(&s.values); herr != nil {
+		errors[v.Type().String()] = herr
+	}
+	if len(errors) > 0 {
+		return errors
+	}
+	return nil
+}
+
+// generated returns the generated decoder of dst, if any, and if the
+// settings of the Decoder match the generated code. Sources with keys deeper
+// than MaxDepth are left to reflection, which reports them as DepthErrors.
+func (d *Decoder) generated(dst any, src map[string][]string) (GeneratedDecoder, bool) {
+	g, ok := dst.(GeneratedDecoder)
+	if !ok || d.empty != EmptyPointer || d.ignoreUnknownKeys || d.weaklyTyped || d.maxSliceLength > 0 || d.cache.taggedOnly {
+		return nil, false
+	}
+	if len(d.cache.regconv) > 0 || len(d.cache.enums) > 0 || len(d.boolValues) > 0 || d.cache.naming != nil || d.cache.preferExact || !slices.Equal(d.cache.tags, []string{"schema"}) {
+		return nil, false
+	}
+	if d.maxDepth > 0 {
+		for key := range src {
+			if strings.Count(dottedKey(key), ".") >= d.maxDepth {
+				return nil, false
+			}
+		}
+	}
+	return g, true
+}
@@ -0,0 +1,85 @@
+package schema
+
+import (
+	"reflect"
+	"testing"
+)
+
+type generatedStub struct {
+	A int `schema:"a"`
+}
+
+func (g generatedStub) EncodeSchema(values *UrlValues) error {
+	values.Add("generated", "1")
+	return nil
+}
+
+func (g *generatedStub) DecodeSchema(src map[string][]string) error {
+	g.A = -1
+	return nil
+}
+
+func TestGeneratedEncoder(t *testing.T) {
+	values, err := NewEncoder().EncodeValues(&generatedStub{A: 1})
+	noError(t, err)
+	want := UrlValues{{"generated", "1"}}
+	if !reflect.DeepEqual(values, want) {
+		t.Errorf("got %v, want %v", values, want)
+	}
+
+	// Settings the generated code doesn't know about disable it.
+	e := NewEncoder()
+	e.SetSliceKeyStyle(SliceKeyIndexed)
+	values, err = e.EncodeValues(generatedStub{A: 1})
+	noError(t, err)
+	want = UrlValues{{"a", "1"}}
+	if !reflect.DeepEqual(values, want) {
+		t.Errorf("got %v, want %v", values, want)
+	}
+
+	if _, err := NewEncoder().EncodeValues((*generatedStub)(nil)); err == nil {
+		t.Error("expected an error for a nil struct pointer")
+	}
+}
+
+func TestGeneratedDecoder(t *testing.T) {
+	var dst generatedStub
+	noError(t, NewDecoder().Decode(&dst, map[string][]string{"a": {"1"}}))
+	if dst.A != -1 {
+		t.Errorf("expected the generated decoder to be used, got %d", dst.A)
+	}
+
+	d := NewDecoder()
+	d.IgnoreUnknownKeys(true)
+	noError(t, d.Decode(&dst, map[string][]string{"a": {"1"}}))
+	if dst.A != 1 {
+		t.Errorf("expected reflection to be used, got %d", dst.A)
+	}
+
+	d = NewDecoder()
+	d.MaxDepth(2)
+	err := d.Decode(&dst, map[string][]string{"a": {"2"}, "a[b][c]": {"1"}})
+	want := DepthError{Key: "a[b][c]", Depth: 3, Max: 2}
+	if got := err.(MultiError)["a[b][c]"]; !reflect.DeepEqual(got, want) {
+		t.Errorf("got %#v, want %#v", got, want)
+	}
+	if dst.A != 2 {
+		t.Errorf("expected reflection to be used for deep keys, got %d", dst.A)
+	}
+}
+
+type generatedHook struct{ generatedStub }
+
+func (g generatedHook) EncodeHook(values *UrlValues) error {
+	values.Add("hook", "1")
+	return nil
+}
+
+func TestGeneratedEncoderHook(t *testing.T) {
+	values, err := NewEncoder().EncodeValues(generatedHook{})
+	noError(t, err)
+	want := UrlValues{{"generated", "1"}, {"hook", "1"}}
+	if !reflect.DeepEqual(values, want) {
+		t.Errorf("got %v, want %v", values, want)
+	}
+}
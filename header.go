@@ -0,0 +1,80 @@
+package schema
+
+import (
+	"context"
+	"errors"
+	"net/http"
+)
+
+var errNilHeader = errors.New("schema: nil header")
+
+// EncodeHeader encodes the fields of src tagged with "header" into h.
+//
+// Keys are canonicalized with http.CanonicalHeaderKey and values are added
+// to the existing ones. Fields without a "header" tag are skipped, except
+// embedded structs, which are walked. Tag options such as omitempty,
+// required and default apply as for query parameters.
+//
+// The settings of the Encoder apply, such as SetOmitEmptyByDefault, except
+// the key prefix and the slice key style: the values of a slice are added
+// under the same name.
+//
+// Fields with only a "header" tag are skipped when encoding or decoding
+// query parameters.
+func (e *Encoder) EncodeHeader(src any, h http.Header) error {
+	if h == nil {
+		return errNilHeader
+	}
+	s := &encodeState{ctx: context.Background(), values: UrlValues{}, opts: e.sideOptions(), tag: "header"}
+	if err := e.encodeInto(src, s); err != nil {
+		return err
+	}
+	for _, v := range s.values {
+		h.Add(v.Key, v.Value)
+	}
+	return nil
+}
+
+// sideOptions returns the options of e for the fields with a side tag,
+// such as "header", whose keys are not prefixed and don't index slices.
+func (e *Encoder) sideOptions() EncodeOptions {
+	opts := e.opts
+	opts.KeyPrefix = ""
+	opts.SliceKeyStyle = SliceKeyRepeat
+	return opts
+}
+
+// DecodeHeader decodes the fields of dst tagged with "header" from h.
+//
+// Header names are matched case-insensitively. Fields without a "header"
+// tag are ignored, except embedded structs, and so are unknown headers.
+func (d *Decoder) DecodeHeader(dst any, h http.Header) error {
+	return d.side("header").decodeValues(dst, h)
+}
+
+// side returns a Decoder for the fields tagged with tag. It shares the
+// converters and settings of d, and ignores unknown keys.
+func (d *Decoder) side(tag string) *Decoder {
+	c, ok := d.sideCaches.Load(tag)
+	if !ok {
+		c, _ = d.sideCaches.LoadOrStore(tag, &cache{
+			regconv:     d.cache.regconv,
+			enums:       d.cache.enums,
+			tags:        []string{tag},
+			naming:      d.cache.naming,
+			preferExact: d.cache.preferExact,
+			taggedOnly:  true,
+		})
+	}
+	return &Decoder{
+		cache:             c.(*cache),
+		empty:             d.empty,
+		ignoreUnknownKeys: true,
+		weaklyTyped:       d.weaklyTyped,
+		maxSize:           d.maxSize,
+		maxSliceLength:    d.maxSliceLength,
+		maxDepth:          d.maxDepth,
+		maxFileSize:       d.maxFileSize,
+		boolValues:        d.boolValues,
+	}
+}
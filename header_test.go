@@ -0,0 +1,105 @@
+package schema
+
+import (
+	"net/http"
+	"reflect"
+	"testing"
+)
+
+type headerMeta struct {
+	RequestID string `header:"x-request-id"`
+}
+
+type headerRequest struct {
+	headerMeta
+	Token  string   `header:"Authorization"`
+	Accept []string `header:"accept"`
+	ETag   string   `header:"if-none-match,omitempty"`
+	Page   int      `schema:"page"`
+	Both   string   `schema:"both" header:"X-Both"`
+}
+
+func TestEncodeHeader(t *testing.T) {
+	src := headerRequest{
+		headerMeta: headerMeta{RequestID: "42"},
+		Token:      "Bearer t",
+		Accept:     []string{"text/html", "application/json"},
+		Page:       2,
+		Both:       "b",
+	}
+	h := http.Header{"Accept": {"text/plain"}}
+	noError(t, NewEncoder().EncodeHeader(&src, h))
+
+	want := http.Header{
+		"X-Request-Id":  {"42"},
+		"Authorization": {"Bearer t"},
+		"Accept":        {"text/plain", "text/html", "application/json"},
+		"X-Both":        {"b"},
+	}
+	if !reflect.DeepEqual(h, want) {
+		t.Errorf("got %v, want %v", h, want)
+	}
+
+	if err := NewEncoder().EncodeHeader(src, nil); err == nil {
+		t.Error("expected an error for a nil header")
+	}
+}
+
+func TestEncodeHeaderOptions(t *testing.T) {
+	e := NewEncoder()
+	e.SetOmitEmptyByDefault(true)
+	e.SetKeyPrefix("filter.")
+	e.SetSliceKeyStyle(SliceKeyIndexed)
+	h := http.Header{}
+	noError(t, e.EncodeHeader(headerRequest{Accept: []string{"text/html", "text/plain"}}, h))
+
+	want := http.Header{"Accept": {"text/html", "text/plain"}}
+	if !reflect.DeepEqual(h, want) {
+		t.Errorf("got %v, want %v", h, want)
+	}
+}
+
+func TestEncodeSkipsHeaderFields(t *testing.T) {
+	src := headerRequest{Token: "secret", Page: 2, Both: "b"}
+	vals := map[string][]string{}
+	noError(t, NewEncoder().Encode(src, vals))
+
+	valExists(t, "page", "2", vals)
+	valExists(t, "both", "b", vals)
+	valsLength(t, 2, vals)
+}
+
+func TestDecodeHeader(t *testing.T) {
+	h := http.Header{}
+	h.Set("X-Request-Id", "42")
+	h.Set("Authorization", "Bearer t")
+	h.Add("Accept", "text/html")
+	h.Add("Accept", "application/json")
+	h.Set("X-Both", "b")
+	h.Set("User-Agent", "test")
+
+	var dst headerRequest
+	noError(t, NewDecoder().DecodeHeader(&dst, h))
+
+	want := headerRequest{
+		headerMeta: headerMeta{RequestID: "42"},
+		Token:      "Bearer t",
+		Accept:     []string{"text/html", "application/json"},
+		Both:       "b",
+	}
+	if !reflect.DeepEqual(dst, want) {
+		t.Errorf("got %+v, want %+v", dst, want)
+	}
+}
+
+func TestDecodeSkipsHeaderFields(t *testing.T) {
+	d := NewDecoder()
+	var dst headerRequest
+	err := d.Decode(&dst, map[string][]string{"page": {"2"}, "Authorization": {"secret"}})
+	if _, ok := err.(MultiError)["Authorization"].(UnknownKeyError); !ok {
+		t.Errorf("expected an UnknownKeyError for a header field, got %v", err)
+	}
+	if dst.Page != 2 {
+		t.Errorf("expected page 2, got %d", dst.Page)
+	}
+}
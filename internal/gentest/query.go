@@ -0,0 +1,35 @@
+// Package gentest holds structs with methods generated by schemagen, to
+// test that they behave like the reflection based encoding and decoding.
+package gentest
+
+//go:generate go run ../../cmd/schemagen -type Query,Empty
+
+// Query uses every field type and tag option supported by schemagen.
+type Query struct {
+	Q       string   `schema:"q,required"`
+	Page    int      `schema:"page,omitempty"`
+	Limit   uint16   `schema:"limit,default=20"`
+	Sort    string   `schema:"sort,default:date"`
+	Desc    bool     `schema:"desc"`
+	Min     float32  `schema:"min,omitempty"`
+	Max     *float64 `schema:"max"`
+	Offset  *int64   `schema:"offset,omitempty"`
+	Tags    []string `schema:"tag"`
+	IDs     []int    `schema:"id,default=1|2"`
+	Flags   []bool   `schema:"flag,omitempty"`
+	Cursor  *string  `schema:"cursor,default=start"`
+	Count   int16    `schema:"count,required"`
+	Token   string   `header:"Authorization"`
+	Ignored string   `schema:"-"`
+	Name    string
+	Small   int8
+	Big     uint64
+	Ratio   float64
+	Level   uint8
+	Code    int32
+	Rank    uint32
+	Any     uint
+}
+
+// Empty has no fields.
+type Empty struct{}
@@ -0,0 +1,441 @@
+// Code generated by schemagen; DO NOT EDIT.
+
+package gentest
+
+import (
+	"reflect"
+	"strconv"
+	"strings"
+
+	"github.com/stormyyd/schema"
+)
+
+// EncodeSchema encodes x into values. It is used by schema.Encoder instead of
+// reflection.
+func (x Query) EncodeSchema(values *schema.UrlValues) error {
+	errs := schema.MultiError{}
+	if x.Q == "" {
+		errs["Q"] = schema.MissingFieldError{Key: "q", Field: "Q", Type: reflect.TypeOf(x.Q)}
+	} else {
+		values.Add("q", x.Q)
+	}
+	if x.Page != 0 {
+		values.Add("page", strconv.FormatInt(int64(x.Page), 10))
+	}
+	if x.Limit == 0 {
+		values.Add("limit", "20")
+	} else {
+		values.Add("limit", strconv.FormatUint(uint64(x.Limit), 10))
+	}
+	if x.Sort == "" {
+		values.Add("sort", "date")
+	} else {
+		values.Add("sort", x.Sort)
+	}
+	values.Add("desc", strconv.FormatBool(x.Desc))
+	if x.Min != 0 {
+		values.Add("min", strconv.FormatFloat(float64(x.Min), 'f', 6, 32))
+	}
+	if x.Max == nil {
+		values.Add("max", "null")
+	} else {
+		values.Add("max", strconv.FormatFloat(*x.Max, 'f', 6, 64))
+	}
+	if x.Offset != nil {
+		values.Add("offset", strconv.FormatInt(*x.Offset, 10))
+	}
+	for _, v := range x.Tags {
+		values.Add("tag", v)
+	}
+	if len(x.IDs) == 0 {
+		values.Add("id", "1")
+		values.Add("id", "2")
+	} else {
+		for _, v := range x.IDs {
+			values.Add("id", strconv.FormatInt(int64(v), 10))
+		}
+	}
+	for _, v := range x.Flags {
+		values.Add("flag", strconv.FormatBool(v))
+	}
+	if x.Cursor == nil {
+		values.Add("cursor", "start")
+	} else {
+		values.Add("cursor", *x.Cursor)
+	}
+	if x.Count == 0 {
+		errs["Count"] = schema.MissingFieldError{Key: "count", Field: "Count", Type: reflect.TypeOf(x.Count)}
+	} else {
+		values.Add("count", strconv.FormatInt(int64(x.Count), 10))
+	}
+	values.Add("Name", x.Name)
+	values.Add("Small", strconv.FormatInt(int64(x.Small), 10))
+	values.Add("Big", strconv.FormatUint(x.Big, 10))
+	values.Add("Ratio", strconv.FormatFloat(x.Ratio, 'f', 6, 64))
+	values.Add("Level", strconv.FormatUint(uint64(x.Level), 10))
+	values.Add("Code", strconv.FormatInt(int64(x.Code), 10))
+	values.Add("Rank", strconv.FormatUint(uint64(x.Rank), 10))
+	values.Add("Any", strconv.FormatUint(uint64(x.Any), 10))
+	if len(errs) > 0 {
+		return errs
+	}
+	return nil
+}
+
+// DecodeSchema decodes src into x. It is used by schema.Decoder instead of
+// reflection.
+func (x *Query) DecodeSchema(src map[string][]string) error {
+	errs := schema.MultiError{}
+	for key, values := range src {
+		switch {
+		case strings.EqualFold(key, "q"):
+			val := ""
+			if len(values) > 0 {
+				val = values[len(values)-1]
+			}
+			if val != "" {
+				x.Q = val
+			}
+		case strings.EqualFold(key, "page"):
+			val := ""
+			if len(values) > 0 {
+				val = values[len(values)-1]
+			}
+			if val != "" {
+				if v, err := strconv.ParseInt(val, 10, 0); err == nil {
+					x.Page = int(v)
+				} else {
+					errs[key] = schema.ConversionError{Key: key, Field: "Page", Type: reflect.TypeOf(int(0)), Index: -1, Value: val}
+				}
+			}
+		case strings.EqualFold(key, "limit"):
+			val := ""
+			if len(values) > 0 {
+				val = values[len(values)-1]
+			}
+			if val != "" {
+				if v, err := strconv.ParseUint(val, 10, 16); err == nil {
+					x.Limit = uint16(v)
+				} else {
+					errs[key] = schema.ConversionError{Key: key, Field: "Limit", Type: reflect.TypeOf(uint16(0)), Index: -1, Value: val}
+				}
+			}
+		case strings.EqualFold(key, "sort"):
+			val := ""
+			if len(values) > 0 {
+				val = values[len(values)-1]
+			}
+			if val != "" {
+				x.Sort = val
+			}
+		case strings.EqualFold(key, "desc"):
+			val := ""
+			if len(values) > 0 {
+				val = values[len(values)-1]
+			}
+			if val != "" {
+				if v, err := schema.ParseBool(val); err == nil {
+					x.Desc = v
+				} else {
+					errs[key] = schema.ConversionError{Key: key, Field: "Desc", Type: reflect.TypeOf(false), Index: -1, Value: val}
+				}
+			}
+		case strings.EqualFold(key, "min"):
+			val := ""
+			if len(values) > 0 {
+				val = values[len(values)-1]
+			}
+			if val != "" {
+				if v, err := strconv.ParseFloat(val, 32); err == nil {
+					x.Min = float32(v)
+				} else {
+					errs[key] = schema.ConversionError{Key: key, Field: "Min", Type: reflect.TypeOf(float32(0)), Index: -1, Value: val}
+				}
+			}
+		case strings.EqualFold(key, "max"):
+			val := ""
+			if len(values) > 0 {
+				val = values[len(values)-1]
+			}
+			if x.Max == nil {
+				x.Max = new(float64)
+			}
+			if val != "" {
+				if v, err := strconv.ParseFloat(val, 64); err == nil {
+					*x.Max = v
+				} else {
+					errs[key] = schema.ConversionError{Key: key, Field: "Max", Type: reflect.TypeOf(float64(0)), Index: -1, Value: val}
+				}
+			}
+		case strings.EqualFold(key, "offset"):
+			val := ""
+			if len(values) > 0 {
+				val = values[len(values)-1]
+			}
+			if x.Offset == nil {
+				x.Offset = new(int64)
+			}
+			if val != "" {
+				if v, err := strconv.ParseInt(val, 10, 64); err == nil {
+					*x.Offset = v
+				} else {
+					errs[key] = schema.ConversionError{Key: key, Field: "Offset", Type: reflect.TypeOf(int64(0)), Index: -1, Value: val}
+				}
+			}
+		case strings.EqualFold(key, "tag"):
+			items := make([]string, 0, len(values))
+			for _, value := range values {
+				if value != "" {
+					items = append(items, value)
+				}
+			}
+			x.Tags = items
+		case strings.EqualFold(key, "id"):
+			if err := func() error {
+				items := make([]int, 0, len(values))
+				for i, value := range values {
+					if value == "" {
+						continue
+					}
+					if v, err := strconv.ParseInt(value, 10, 0); err == nil {
+						items = append(items, int(v))
+						continue
+					}
+					if !strings.Contains(value, ",") {
+						return schema.ConversionError{Key: key, Field: "IDs", Type: reflect.TypeOf(int(0)), Index: i, Value: value}
+					}
+					for _, value := range strings.Split(value, ",") {
+						if value == "" {
+							continue
+						}
+						if v, err := strconv.ParseInt(value, 10, 0); err == nil {
+							items = append(items, int(v))
+						} else {
+							return schema.ConversionError{Key: key, Field: "IDs", Type: reflect.TypeOf(int(0)), Index: i, Value: value}
+						}
+					}
+				}
+				x.IDs = items
+				return nil
+			}(); err != nil {
+				errs[key] = err
+			}
+		case strings.EqualFold(key, "flag"):
+			if err := func() error {
+				items := make([]bool, 0, len(values))
+				for i, value := range values {
+					if value == "" {
+						continue
+					}
+					if v, err := schema.ParseBool(value); err == nil {
+						items = append(items, v)
+						continue
+					}
+					if !strings.Contains(value, ",") {
+						return schema.ConversionError{Key: key, Field: "Flags", Type: reflect.TypeOf(false), Index: i, Value: value}
+					}
+					for _, value := range strings.Split(value, ",") {
+						if value == "" {
+							continue
+						}
+						if v, err := schema.ParseBool(value); err == nil {
+							items = append(items, v)
+						} else {
+							return schema.ConversionError{Key: key, Field: "Flags", Type: reflect.TypeOf(false), Index: i, Value: value}
+						}
+					}
+				}
+				x.Flags = items
+				return nil
+			}(); err != nil {
+				errs[key] = err
+			}
+		case strings.EqualFold(key, "cursor"):
+			val := ""
+			if len(values) > 0 {
+				val = values[len(values)-1]
+			}
+			if x.Cursor == nil {
+				x.Cursor = new(string)
+			}
+			if val != "" {
+				*x.Cursor = val
+			}
+		case strings.EqualFold(key, "count"):
+			val := ""
+			if len(values) > 0 {
+				val = values[len(values)-1]
+			}
+			if val != "" {
+				if v, err := strconv.ParseInt(val, 10, 16); err == nil {
+					x.Count = int16(v)
+				} else {
+					errs[key] = schema.ConversionError{Key: key, Field: "Count", Type: reflect.TypeOf(int16(0)), Index: -1, Value: val}
+				}
+			}
+		case strings.EqualFold(key, "Name"):
+			val := ""
+			if len(values) > 0 {
+				val = values[len(values)-1]
+			}
+			if val != "" {
+				x.Name = val
+			}
+		case strings.EqualFold(key, "Small"):
+			val := ""
+			if len(values) > 0 {
+				val = values[len(values)-1]
+			}
+			if val != "" {
+				if v, err := strconv.ParseInt(val, 10, 8); err == nil {
+					x.Small = int8(v)
+				} else {
+					errs[key] = schema.ConversionError{Key: key, Field: "Small", Type: reflect.TypeOf(int8(0)), Index: -1, Value: val}
+				}
+			}
+		case strings.EqualFold(key, "Big"):
+			val := ""
+			if len(values) > 0 {
+				val = values[len(values)-1]
+			}
+			if val != "" {
+				if v, err := strconv.ParseUint(val, 10, 64); err == nil {
+					x.Big = v
+				} else {
+					errs[key] = schema.ConversionError{Key: key, Field: "Big", Type: reflect.TypeOf(uint64(0)), Index: -1, Value: val}
+				}
+			}
+		case strings.EqualFold(key, "Ratio"):
+			val := ""
+			if len(values) > 0 {
+				val = values[len(values)-1]
+			}
+			if val != "" {
+				if v, err := strconv.ParseFloat(val, 64); err == nil {
+					x.Ratio = v
+				} else {
+					errs[key] = schema.ConversionError{Key: key, Field: "Ratio", Type: reflect.TypeOf(float64(0)), Index: -1, Value: val}
+				}
+			}
+		case strings.EqualFold(key, "Level"):
+			val := ""
+			if len(values) > 0 {
+				val = values[len(values)-1]
+			}
+			if val != "" {
+				if v, err := strconv.ParseUint(val, 10, 8); err == nil {
+					x.Level = uint8(v)
+				} else {
+					errs[key] = schema.ConversionError{Key: key, Field: "Level", Type: reflect.TypeOf(uint8(0)), Index: -1, Value: val}
+				}
+			}
+		case strings.EqualFold(key, "Code"):
+			val := ""
+			if len(values) > 0 {
+				val = values[len(values)-1]
+			}
+			if val != "" {
+				if v, err := strconv.ParseInt(val, 10, 32); err == nil {
+					x.Code = int32(v)
+				} else {
+					errs[key] = schema.ConversionError{Key: key, Field: "Code", Type: reflect.TypeOf(int32(0)), Index: -1, Value: val}
+				}
+			}
+		case strings.EqualFold(key, "Rank"):
+			val := ""
+			if len(values) > 0 {
+				val = values[len(values)-1]
+			}
+			if val != "" {
+				if v, err := strconv.ParseUint(val, 10, 32); err == nil {
+					x.Rank = uint32(v)
+				} else {
+					errs[key] = schema.ConversionError{Key: key, Field: "Rank", Type: reflect.TypeOf(uint32(0)), Index: -1, Value: val}
+				}
+			}
+		case strings.EqualFold(key, "Any"):
+			val := ""
+			if len(values) > 0 {
+				val = values[len(values)-1]
+			}
+			if val != "" {
+				if v, err := strconv.ParseUint(val, 10, 0); err == nil {
+					x.Any = uint(v)
+				} else {
+					errs[key] = schema.ConversionError{Key: key, Field: "Any", Type: reflect.TypeOf(uint(0)), Index: -1, Value: val}
+				}
+			}
+		default:
+			errs[key] = schema.UnknownKeyError{Key: key}
+		}
+	}
+	if x.Limit == 0 {
+		x.Limit = 20
+	}
+	if x.Sort == "" {
+		x.Sort = "date"
+	}
+	if x.IDs == nil {
+		x.IDs = []int{1, 2}
+	}
+	if x.Cursor == nil {
+		v := "start"
+		x.Cursor = &v
+	}
+	if errs["q"] == nil && !func() bool {
+		for key, values := range src {
+			if i := strings.IndexAny(key, ".["); i >= 0 {
+				key = key[:i]
+			}
+			if strings.EqualFold(key, "q") && len(values) > 0 && values[0] != "" {
+				return true
+			}
+		}
+		return false
+	}() {
+		errs["q"] = schema.MissingFieldError{Key: "q", Field: "Q", Type: reflect.TypeOf(x.Q)}
+	}
+	if errs["count"] == nil && !func() bool {
+		for key, values := range src {
+			if i := strings.IndexAny(key, ".["); i >= 0 {
+				key = key[:i]
+			}
+			if strings.EqualFold(key, "count") && len(values) > 0 {
+				return true
+			}
+		}
+		return false
+	}() {
+		errs["count"] = schema.MissingFieldError{Key: "count", Field: "Count", Type: reflect.TypeOf(x.Count)}
+	}
+	if len(errs) > 0 {
+		return errs
+	}
+	return nil
+}
+
+// EncodeSchema encodes x into values. It is used by schema.Encoder instead of
+// reflection.
+func (x Empty) EncodeSchema(values *schema.UrlValues) error {
+	errs := schema.MultiError{}
+	if len(errs) > 0 {
+		return errs
+	}
+	return nil
+}
+
+// DecodeSchema decodes src into x. It is used by schema.Decoder instead of
+// reflection.
+func (x *Empty) DecodeSchema(src map[string][]string) error {
+	errs := schema.MultiError{}
+	for key := range src {
+		switch {
+		default:
+			errs[key] = schema.UnknownKeyError{Key: key}
+		}
+	}
+	if len(errs) > 0 {
+		return errs
+	}
+	return nil
+}
@@ -0,0 +1,64 @@
+package gentest
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/stormyyd/schema"
+)
+
+// plainQuery has the fields of Query without the generated methods, so it
+// is encoded and decoded with reflection.
+type plainQuery Query
+
+func TestEncodeSchemaMatchesReflection(t *testing.T) {
+	max, offset, cursor := 2.5, int64(-3), "c"
+	tests := []Query{
+		{},
+		{Q: "shoes", Count: 1},
+		{
+			Q: "shoes", Page: 2, Limit: 50, Sort: "price", Desc: true, Min: 1.25,
+			Max: &max, Offset: &offset, Tags: []string{"a", "b"}, IDs: []int{3},
+			Flags: []bool{true, false}, Cursor: &cursor, Count: -1, Token: "t",
+			Ignored: "i", Name: "n", Small: -8, Big: 1 << 60, Ratio: 0.1,
+			Level: 255, Code: -32, Rank: 32, Any: 7,
+		},
+	}
+	e := schema.NewEncoder()
+	for _, q := range tests {
+		got, gotErr := e.EncodeValues(q)
+		want, wantErr := e.EncodeValues(plainQuery(q))
+		if !reflect.DeepEqual(got, want) || !reflect.DeepEqual(gotErr, wantErr) {
+			t.Errorf("%+v:\ngot  %v, %v\nwant %v, %v", q, got, gotErr, want, wantErr)
+		}
+	}
+}
+
+func TestDecodeSchemaMatchesReflection(t *testing.T) {
+	tests := []map[string][]string{
+		{},
+		{"q": {"shoes"}, "count": {"1"}},
+		{
+			"Q": {"a", "b"}, "page": {"2"}, "limit": {"50"}, "sort": {""},
+			"desc": {"on"}, "min": {"1.5"}, "max": {""}, "offset": {"-3"},
+			"tag": {"a", "", "b,c"}, "id": {"1,2", "", "3"}, "flag": {"true", "0,1", "yes,off"},
+			"cursor": {"c"}, "count": {""}, "name": {"n"}, "small": {"-8"},
+			"big": {"18446744073709551615"}, "ratio": {"0.1"}, "level": {"255"},
+			"code": {"-32"}, "rank": {"32"}, "any": {"7"},
+		},
+		{"page": {"x"}, "limit": {"-1"}, "desc": {"maybe"}, "small": {"128"}},
+		{"id": {"1", "x"}, "flag": {"true,x"}, "unknown": {"1"}, "Authorization": {"t"}},
+		{"qq": {"prefix"}, "count": {"5"}, "max": {"1e400"}},
+		{"q": {"", "late"}, "count": {}},
+	}
+	d := schema.NewDecoder()
+	for _, src := range tests {
+		var got Query
+		var want plainQuery
+		gotErr := d.Decode(&got, src)
+		wantErr := d.Decode(&want, src)
+		if !reflect.DeepEqual(plainQuery(got), want) || !reflect.DeepEqual(gotErr, wantErr) {
+			t.Errorf("%v:\ngot  %+v, %v\nwant %+v, %v", src, got, gotErr, want, wantErr)
+		}
+	}
+}
@@ -0,0 +1,43 @@
+package schema
+
+import "sync"
+
+// defaultEncoder and defaultDecoder are used by the package level helpers.
+// They are created on first use.
+var (
+	defaultEncoder = sync.OnceValue(func() *Encoder { return NewEncoder() })
+	defaultDecoder = sync.OnceValue(func() *Decoder { return NewDecoder() })
+)
+
+// Marshal encodes v into UrlValues using a default Encoder.
+// See Encoder.EncodeValues.
+func Marshal[T any](v T) (UrlValues, error) {
+	return defaultEncoder().EncodeValues(v)
+}
+
+// Unmarshal decodes values into a new T using a default Decoder.
+// T must be a struct type, or a map[string]any or map[string][]string.
+// See Decoder.Decode.
+func Unmarshal[T any](values map[string][]string) (T, error) {
+	var v T
+	err := defaultDecoder().Decode(&v, values)
+	return v, err
+}
+
+// Decode decodes values into a new T, configured by opts, as in
+//
+//	params, err := schema.Decode[ListParams](r.URL.Query())
+//
+// T must be a struct type, or a map[string]any or map[string][]string.
+// Without options, the default Decoder of Unmarshal is used; otherwise a new
+// Decoder is created, so a Decoder configured once should be preferred for
+// repeated use with options. See Decoder.Decode.
+func Decode[T any](values map[string][]string, opts ...DecoderOption) (T, error) {
+	d := defaultDecoder()
+	if len(opts) > 0 {
+		d = NewDecoder(opts...)
+	}
+	var v T
+	err := d.Decode(&v, values)
+	return v, err
+}
@@ -0,0 +1,322 @@
+package schema
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"net/textproto"
+	"net/url"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+)
+
+// File is the content of a file part in a multipart form.
+type File struct {
+	// Name is the file name sent with the part.
+	Name string
+	// ContentType is the content type of the part. If empty,
+	// "application/octet-stream" is used.
+	ContentType string
+	// Body is read to get the content of the part.
+	Body io.Reader
+}
+
+var (
+	fileType       = reflect.TypeOf(File{})
+	readerType     = reflect.TypeOf((*io.Reader)(nil)).Elem()
+	fileHeaderType = reflect.TypeOf((*multipart.FileHeader)(nil))
+)
+
+// filePart is a file field collected while encoding a multipart form.
+type filePart struct {
+	key  string
+	file File
+}
+
+// isFileField reports whether a field of type t is encoded as a file part.
+// Fields of type File or *File always are; io.Reader implementations, such
+// as *os.File, need the "file" tag option.
+func isFileField(t reflect.Type, opts tagOptions) bool {
+	if t == fileType || t == reflect.PointerTo(fileType) {
+		return true
+	}
+	return opts.Contains("file") && t.Implements(readerType)
+}
+
+// addFile collects the file part of field v. Nil files are skipped.
+func (s *encodeState) addFile(key string, v reflect.Value) {
+	if isNil(v) {
+		return
+	}
+	if v.Kind() == reflect.Ptr && v.Elem().Type() == fileType {
+		v = v.Elem()
+	}
+	if v.Type() == fileType {
+		if f := v.Interface().(File); f.Body != nil {
+			s.files = append(s.files, filePart{key: key, file: f})
+		}
+		return
+	}
+	r := v.Interface().(io.Reader)
+	f := File{Name: key, Body: r}
+	if named, ok := r.(interface{ Name() string }); ok {
+		f.Name = filepath.Base(named.Name())
+	}
+	s.files = append(s.files, filePart{key: key, file: f})
+}
+
+// EncodeMultipart encodes a struct into a multipart form written to w.
+//
+// Fields of type File or *File, and io.Reader fields tagged with the "file"
+// option, such as an *os.File, are written as file parts. Other fields are
+// written as form fields. Form fields are written first, in order, followed
+// by the file parts. The caller is responsible for closing w.
+//
+// File fields are skipped by the other encoding methods.
+func (e *Encoder) EncodeMultipart(src any, w *multipart.Writer) error {
+	s := &encodeState{ctx: context.Background(), values: UrlValues{}, opts: e.opts, multipart: true}
+	if err := e.encodeInto(src, s); err != nil {
+		return err
+	}
+	for _, p := range s.values {
+		if err := w.WriteField(p.Key, p.Value); err != nil {
+			return err
+		}
+	}
+	for _, p := range s.files {
+		contentType := p.file.ContentType
+		if contentType == "" {
+			contentType = "application/octet-stream"
+		}
+		h := make(textproto.MIMEHeader)
+		h.Set("Content-Disposition", fmt.Sprintf(`form-data; name="%s"; filename="%s"`,
+			escapeQuotes(p.key), escapeQuotes(p.file.Name)))
+		h.Set("Content-Type", contentType)
+		part, err := w.CreatePart(h)
+		if err != nil {
+			return err
+		}
+		if _, err := io.Copy(part, p.file.Body); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+var quoteEscaper = strings.NewReplacer("\\", "\\\\", `"`, "\\\"")
+
+// escapeQuotes escapes a Content-Disposition parameter as mime/multipart.
+func escapeQuotes(s string) string {
+	return quoteEscaper.Replace(s)
+}
+
+// readMultipart parses the multipart body of r into r.MultipartForm and
+// r.PostForm, as r.ParseMultipartForm(maxMemory) does. When MaxFileSize is
+// set, the parts are read one at a time and reading stops at the first file
+// larger than it, returning a FileSizeError, so the rest of the upload is
+// never read.
+func (d *Decoder) readMultipart(r *http.Request, maxMemory int64) error {
+	if d.maxFileSize <= 0 || r.MultipartForm != nil {
+		return r.ParseMultipartForm(maxMemory)
+	}
+	if err := r.ParseForm(); err != nil {
+		return err
+	}
+	mr, err := r.MultipartReader()
+	if err != nil {
+		return err
+	}
+	// The parts within the limits are copied to buf, which is parsed by
+	// mime/multipart once the whole body is read. Past maxMemory bytes,
+	// buf is written to a temporary file.
+	buf := &spillWriter{max: maxMemory}
+	defer buf.close()
+	w := multipart.NewWriter(buf)
+	var valuesSize int64
+	for {
+		p, err := mr.NextPart()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return err
+		}
+		pw, err := w.CreatePart(p.Header)
+		if err != nil {
+			return err
+		}
+		if p.FileName() == "" {
+			n, err := io.Copy(pw, io.LimitReader(p, maxMemory-valuesSize+1))
+			if err != nil {
+				return err
+			}
+			if valuesSize += n; valuesSize > maxMemory {
+				return multipart.ErrMessageTooLarge
+			}
+			continue
+		}
+		n, err := io.Copy(pw, io.LimitReader(p, d.maxFileSize+1))
+		if err != nil {
+			return err
+		}
+		if n > d.maxFileSize {
+			return FileSizeError{Key: p.FormName(), Name: p.FileName(), Size: n, Max: d.maxFileSize}
+		}
+	}
+	if err := w.Close(); err != nil {
+		return err
+	}
+	body, err := buf.reader()
+	if err != nil {
+		return err
+	}
+	form, err := multipart.NewReader(body, w.Boundary()).ReadForm(maxMemory)
+	if err != nil {
+		return err
+	}
+	if r.PostForm == nil {
+		r.PostForm = url.Values{}
+	}
+	for key, vs := range form.Value {
+		r.Form[key] = append(r.Form[key], vs...)
+		r.PostForm[key] = append(r.PostForm[key], vs...)
+	}
+	r.MultipartForm = form
+	return nil
+}
+
+// spillWriter keeps up to max bytes in memory, and writes the rest to a
+// temporary file, as multipart.Reader.ReadForm does with large files.
+type spillWriter struct {
+	buf  bytes.Buffer
+	file *os.File
+	max  int64
+}
+
+func (w *spillWriter) Write(p []byte) (int, error) {
+	if w.file == nil && int64(w.buf.Len()+len(p)) <= w.max {
+		return w.buf.Write(p)
+	}
+	if w.file == nil {
+		f, err := os.CreateTemp("", "schema-multipart-")
+		if err != nil {
+			return 0, err
+		}
+		w.file = f
+	}
+	return w.file.Write(p)
+}
+
+// reader returns a reader of the bytes written to w.
+func (w *spillWriter) reader() (io.Reader, error) {
+	if w.file == nil {
+		return &w.buf, nil
+	}
+	if _, err := w.file.Seek(0, io.SeekStart); err != nil {
+		return nil, err
+	}
+	return io.MultiReader(&w.buf, w.file), nil
+}
+
+// close removes the temporary file of w, if any.
+func (w *spillWriter) close() {
+	if w.file != nil {
+		w.file.Close()
+		os.Remove(w.file.Name())
+	}
+}
+
+// fileError sets the field of err to the field of dst the file is decoded
+// into, if any.
+func (d *Decoder) fileError(dst any, err FileSizeError) error {
+	t := reflect.TypeOf(dst)
+	if t == nil || t.Kind() != reflect.Ptr || t.Elem().Kind() != reflect.Struct {
+		return err
+	}
+	f := d.cache.get(t.Elem()).get(err.Key, d.cache.preferExact)
+	if f == nil {
+		return err
+	}
+	return withField(err, []pathPart{{field: f, path: []string{f.name}, index: -1}})
+}
+
+// decodeFiles sets the fields of dst of type *multipart.FileHeader or
+// []*multipart.FileHeader, or of its embedded structs, to the files of the
+// same name. A single file field is set to the last file.
+func (d *Decoder) decodeFiles(dst any, files map[string][]*multipart.FileHeader) error {
+	v := reflect.ValueOf(dst)
+	if v.Kind() != reflect.Ptr || v.Elem().Kind() != reflect.Struct {
+		return nil
+	}
+	v = v.Elem()
+	struc := d.cache.get(v.Type())
+	errs := MultiError{}
+	for key, headers := range files {
+		f := struc.get(key, d.cache.preferExact)
+		if f == nil || f.typ != fileHeaderType && f.typ != reflect.SliceOf(fileHeaderType) {
+			continue
+		}
+		parts := []pathPart{{field: f, path: []string{f.name}, index: -1}}
+		if err := d.checkFiles(key, f, headers); err != nil {
+			errs[key] = withField(err, parts)
+			continue
+		}
+		field := promotedField(v, f.name)
+		if !field.CanSet() {
+			continue
+		}
+		if f.typ == fileHeaderType {
+			field.Set(reflect.ValueOf(headers[len(headers)-1]))
+		} else {
+			field.Set(reflect.ValueOf(headers))
+		}
+	}
+	if len(errs) > 0 {
+		return errs
+	}
+	return nil
+}
+
+// checkFiles checks the number and size of the files of the field f.
+func (d *Decoder) checkFiles(key string, f *fieldInfo, headers []*multipart.FileHeader) error {
+	limit, err := d.sliceLimit(f)
+	if err != nil {
+		return err
+	}
+	if limit > 0 && len(headers) > limit && f.typ != fileHeaderType {
+		return SliceLengthError{Key: key, Len: len(headers), Max: limit}
+	}
+	for _, h := range headers {
+		if d.maxFileSize > 0 && h.Size > d.maxFileSize {
+			return FileSizeError{Key: key, Name: h.Filename, Size: h.Size, Max: d.maxFileSize}
+		}
+	}
+	return nil
+}
+
+// promotedField returns the field name of the struct v, possibly promoted
+// from embedded structs, allocating the nil embedded structs on the way.
+func promotedField(v reflect.Value, name string) reflect.Value {
+	sf, ok := v.Type().FieldByName(name)
+	if !ok {
+		return reflect.Value{}
+	}
+	for i, x := range sf.Index {
+		if i > 0 && v.Kind() == reflect.Ptr {
+			if v.IsNil() {
+				if !v.CanSet() {
+					return reflect.Value{}
+				}
+				v.Set(reflect.New(v.Type().Elem()))
+			}
+			v = v.Elem()
+		}
+		v = v.Field(x)
+	}
+	return v
+}
@@ -0,0 +1,132 @@
+package schema
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestEncodeMultipart(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "report.csv")
+	if err := os.WriteFile(path, []byte("a,b"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	osFile, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer osFile.Close()
+
+	type Upload struct {
+		Title  string    `schema:"title"`
+		Avatar File      `schema:"avatar"`
+		Report *os.File  `schema:"report,file"`
+		Raw    io.Reader `schema:"raw,file"`
+		None   *File     `schema:"none"`
+		Tags   []string  `schema:"tag"`
+	}
+	src := Upload{
+		Title:  "hello",
+		Avatar: File{Name: "me.png", ContentType: "image/png", Body: strings.NewReader("png")},
+		Report: osFile,
+		Raw:    strings.NewReader("raw"),
+		Tags:   []string{"a", "b"},
+	}
+
+	var buf bytes.Buffer
+	w := multipart.NewWriter(&buf)
+	noError(t, NewEncoder().EncodeMultipart(src, w))
+	noError(t, w.Close())
+
+	form, err := multipart.NewReader(&buf, w.Boundary()).ReadForm(1 << 20)
+	noError(t, err)
+	valExists(t, "title", "hello", form.Value)
+	valsExist(t, "tag", []string{"a", "b"}, form.Value)
+	valsLength(t, 2, form.Value)
+
+	expected := map[string][3]string{
+		"avatar": {"me.png", "image/png", "png"},
+		"report": {"report.csv", "application/octet-stream", "a,b"},
+		"raw":    {"raw", "application/octet-stream", "raw"},
+	}
+	if len(form.File) != len(expected) {
+		t.Fatalf("Expected %d files, got %v", len(expected), form.File)
+	}
+	for key, e := range expected {
+		fh := form.File[key][0]
+		f, err := fh.Open()
+		noError(t, err)
+		content, _ := io.ReadAll(f)
+		if fh.Filename != e[0] || fh.Header.Get("Content-Type") != e[1] || string(content) != e[2] {
+			t.Errorf("Unexpected file %s: %s %s %s", key, fh.Filename, fh.Header.Get("Content-Type"), content)
+		}
+	}
+
+	// File fields are skipped by the other methods.
+	vals := map[string][]string{}
+	noError(t, NewEncoder().Encode(src, vals))
+	valsLength(t, 2, vals)
+}
+
+func TestReadMultipartManyFiles(t *testing.T) {
+	var body bytes.Buffer
+	w := multipart.NewWriter(&body)
+	w.WriteField("title", "hello")
+	for i := 0; i < 200; i++ {
+		fw, _ := w.CreateFormFile("attachment", fmt.Sprintf("file%d.txt", i))
+		fmt.Fprintf(fw, "content %03d", i)
+	}
+	w.Close()
+	r, _ := http.NewRequest(http.MethodPost, "/", &body)
+	r.Header.Set("Content-Type", w.FormDataContentType())
+
+	d := NewDecoder()
+	d.MaxFileSize(16)
+	// The parts are buffered in a temporary file past 256 bytes.
+	noError(t, d.readMultipart(r, 256))
+	defer r.MultipartForm.RemoveAll()
+	valExists(t, "title", "hello", r.PostForm)
+	files := r.MultipartForm.File["attachment"]
+	if len(files) != 200 {
+		t.Fatalf("Expected 200 files, got %d", len(files))
+	}
+	for i, fh := range files {
+		f, err := fh.Open()
+		noError(t, err)
+		content, _ := io.ReadAll(f)
+		f.Close()
+		if want := fmt.Sprintf("content %03d", i); string(content) != want {
+			t.Errorf("Expected %q, got %q", want, content)
+		}
+	}
+}
+
+func TestSpillWriter(t *testing.T) {
+	w := &spillWriter{max: 256}
+	defer w.close()
+	data := bytes.Repeat([]byte("0123456789"), 100)
+	for i := 0; i < len(data); i += 100 {
+		if _, err := w.Write(data[i : i+100]); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if w.buf.Len() > 256 || w.file == nil {
+		t.Errorf("Expected at most 256 bytes in memory, got %d", w.buf.Len())
+	}
+	r, err := w.reader()
+	noError(t, err)
+	if got, _ := io.ReadAll(r); !bytes.Equal(got, data) {
+		t.Errorf("Expected the written bytes, got %q", got)
+	}
+	name := w.file.Name()
+	w.close()
+	if _, err := os.Stat(name); !os.IsNotExist(err) {
+		t.Errorf("Expected the temporary file to be removed, got %v", err)
+	}
+}
@@ -0,0 +1,61 @@
+package schema
+
+import (
+	"strings"
+	"unicode"
+)
+
+// NamingStrategy converts a struct field name into a key name. It is applied
+// to fields that don't define an alias in their tag.
+type NamingStrategy func(fieldName string) string
+
+// SnakeCase converts a field name to snake_case, e.g. "UserID" to "user_id".
+func SnakeCase(fieldName string) string {
+	return strings.Join(splitWords(fieldName), "_")
+}
+
+// KebabCase converts a field name to kebab-case, e.g. "UserID" to "user-id".
+func KebabCase(fieldName string) string {
+	return strings.Join(splitWords(fieldName), "-")
+}
+
+// CamelCase converts a field name to camelCase, e.g. "UserID" to "userId".
+func CamelCase(fieldName string) string {
+	words := splitWords(fieldName)
+	for i := 1; i < len(words); i++ {
+		r := []rune(words[i])
+		r[0] = unicode.ToUpper(r[0])
+		words[i] = string(r)
+	}
+	return strings.Join(words, "")
+}
+
+// splitWords splits a Go identifier into lower case words. Runs of upper
+// case letters are treated as a single word, so "HTTPServer" becomes
+// "http" and "server".
+func splitWords(s string) []string {
+	var words []string
+	r := []rune(s)
+	start := 0
+	for i := 0; i < len(r); i++ {
+		if r[i] == '_' || r[i] == '-' || unicode.IsSpace(r[i]) {
+			if i > start {
+				words = append(words, strings.ToLower(string(r[start:i])))
+			}
+			start = i + 1
+			continue
+		}
+		if i > start && unicode.IsUpper(r[i]) {
+			prev := r[i-1]
+			nextLower := i+1 < len(r) && unicode.IsLower(r[i+1])
+			if unicode.IsLower(prev) || unicode.IsDigit(prev) || (unicode.IsUpper(prev) && nextLower) {
+				words = append(words, strings.ToLower(string(r[start:i])))
+				start = i
+			}
+		}
+	}
+	if start < len(r) {
+		words = append(words, strings.ToLower(string(r[start:])))
+	}
+	return words
+}
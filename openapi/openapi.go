@@ -0,0 +1,189 @@
+// Package openapi converts the structs used with schema into OpenAPI 3
+// parameter definitions, so they can also drive API documentation.
+//
+// The parameters are described as by schema.Encoder.Describe, so their
+// names follow the settings of the Encoder:
+//
+//	g := openapi.New(schema.NewEncoder())
+//	g.RegisterEnum(Status(""), StatusOpen, StatusClosed)
+//	params, err := g.Parameters(ListParams{})
+//
+// The result can be marshaled to JSON as the "parameters" of an operation.
+package openapi
+
+import (
+	"reflect"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/stormyyd/schema"
+)
+
+// Parameter is an OpenAPI 3 parameter object.
+type Parameter struct {
+	Name     string  `json:"name"`
+	In       string  `json:"in"`
+	Required bool    `json:"required,omitempty"`
+	Style    string  `json:"style,omitempty"`
+	Explode  *bool   `json:"explode,omitempty"`
+	Schema   *Schema `json:"schema"`
+}
+
+// Schema is the subset of the JSON Schema of OpenAPI 3 describing
+// parameters.
+type Schema struct {
+	Type     string  `json:"type"`
+	Format   string  `json:"format,omitempty"`
+	Items    *Schema `json:"items,omitempty"`
+	Enum     []any   `json:"enum,omitempty"`
+	Default  any     `json:"default,omitempty"`
+	Minimum  *int    `json:"minimum,omitempty"`
+	MaxItems *int    `json:"maxItems,omitempty"`
+}
+
+// Generator generates the parameters of structs.
+type Generator struct {
+	encoder *schema.Encoder
+	enums   map[reflect.Type][]any
+}
+
+// New returns a Generator naming the parameters as e encodes them. If e is
+// nil, a default Encoder is used.
+func New(e *schema.Encoder) *Generator {
+	if e == nil {
+		e = schema.NewEncoder()
+	}
+	return &Generator{encoder: e, enums: map[reflect.Type][]any{}}
+}
+
+// RegisterEnum registers the values allowed for the type of sample, listed
+// as the enum of the parameters of this type or of slices of it. They take
+// precedence over the values registered with schema.Encoder.RegisterEnum.
+func (g *Generator) RegisterEnum(sample any, values ...any) {
+	g.enums[reflect.TypeOf(sample)] = values
+}
+
+// Parameters returns the parameters of the struct type of sample, which may
+// be a struct, a pointer to a struct or a reflect.Type, in field order.
+//
+// The "comma", "space" and "pipe" tag options set the style of arrays, and
+// the "max" option their maximum number of items. Path parameters are
+// always required.
+func (g *Generator) Parameters(sample any) ([]Parameter, error) {
+	described, err := g.encoder.Describe(sample)
+	if err != nil {
+		return nil, err
+	}
+	params := make([]Parameter, 0, len(described))
+	for _, d := range described {
+		p := Parameter{
+			Name:     d.Name,
+			In:       d.In,
+			Required: d.Required || d.In == "path",
+			Schema:   g.schema(d.Type),
+		}
+		if p.Schema.Type == "array" {
+			switch {
+			case slices.Contains(d.Options, "comma"):
+				p.Style, p.Explode = "form", new(bool)
+			case slices.Contains(d.Options, "space"):
+				p.Style = "spaceDelimited"
+			case slices.Contains(d.Options, "pipe"):
+				p.Style = "pipeDelimited"
+			}
+			if n, ok := optionInt(d.Options, "max"); ok && n > 0 {
+				p.Schema.MaxItems = &n
+			}
+		}
+		if s := p.Schema; len(d.Enum) > 0 {
+			if s.Type == "array" {
+				s = s.Items
+			}
+			if s.Enum == nil {
+				for _, v := range d.Enum {
+					s.Enum = append(s.Enum, defaultValue(s, v))
+				}
+			}
+		}
+		if d.Default != "" {
+			p.Schema.Default = defaultValue(p.Schema, d.Default)
+		}
+		params = append(params, p)
+	}
+	return params, nil
+}
+
+var timeType = reflect.TypeOf(time.Time{})
+
+// schema returns the schema of the type t.
+func (g *Generator) schema(t reflect.Type) *Schema {
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	s := &Schema{Enum: g.enums[t]}
+	switch t.Kind() {
+	case reflect.Bool:
+		s.Type = "boolean"
+	case reflect.Int8, reflect.Int16, reflect.Int32:
+		s.Type, s.Format = "integer", "int32"
+	case reflect.Int, reflect.Int64:
+		s.Type, s.Format = "integer", "int64"
+	case reflect.Uint8, reflect.Uint16, reflect.Uint32:
+		s.Type, s.Format, s.Minimum = "integer", "int32", new(int)
+	case reflect.Uint, reflect.Uint64:
+		s.Type, s.Format, s.Minimum = "integer", "int64", new(int)
+	case reflect.Float32:
+		s.Type, s.Format = "number", "float"
+	case reflect.Float64:
+		s.Type, s.Format = "number", "double"
+	case reflect.Slice, reflect.Array:
+		s.Type, s.Items = "array", g.schema(t.Elem())
+	default:
+		s.Type = "string"
+		if t == timeType {
+			s.Format = "date-time"
+		}
+	}
+	return s
+}
+
+// defaultValue returns the default value of the schema s, parsed from the
+// default tag option. The values of arrays are separated by "|".
+func defaultValue(s *Schema, value string) any {
+	if s.Type == "array" {
+		var values []any
+		for _, v := range strings.Split(value, "|") {
+			values = append(values, defaultValue(s.Items, v))
+		}
+		return values
+	}
+	switch s.Type {
+	case "boolean":
+		if v, err := schema.ParseBool(value); err == nil {
+			return v
+		}
+	case "integer":
+		if v, err := strconv.ParseInt(value, 10, 64); err == nil {
+			return v
+		}
+	case "number":
+		if v, err := strconv.ParseFloat(value, 64); err == nil {
+			return v
+		}
+	}
+	return value
+}
+
+// optionInt returns the integer value of the tag option written as
+// "name=value".
+func optionInt(options []string, name string) (int, bool) {
+	for _, o := range options {
+		if v, ok := strings.CutPrefix(o, name+"="); ok {
+			n, err := strconv.Atoi(v)
+			return n, err == nil
+		}
+	}
+	return 0, false
+}
@@ -0,0 +1,71 @@
+package openapi
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stormyyd/schema"
+)
+
+type status string
+
+type listParams struct {
+	ID       int      `path:"id"`
+	Q        string   `schema:"q,required"`
+	Page     uint     `schema:"page,default=1"`
+	Statuses []status `schema:"status,comma,max=3"`
+	Ratio    *float32 `schema:"ratio"`
+	Filter   struct {
+		Tags []string `schema:"tag,default=a|b"`
+	} `schema:"filter"`
+}
+
+func TestParameters(t *testing.T) {
+	g := New(schema.NewEncoder(schema.WithNestedStyle(schema.NestedKeyBrackets)))
+	g.RegisterEnum(status(""), "open", "closed")
+	params, err := g.Parameters(&listParams{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, _ := json.Marshal(params)
+	want := `[` +
+		`{"name":"id","in":"path","required":true,"schema":{"type":"integer","format":"int64"}},` +
+		`{"name":"q","in":"query","required":true,"schema":{"type":"string"}},` +
+		`{"name":"page","in":"query","schema":{"type":"integer","format":"int64","default":1,"minimum":0}},` +
+		`{"name":"status","in":"query","style":"form","explode":false,"schema":{"type":"array","items":{"type":"string","enum":["open","closed"]},"maxItems":3}},` +
+		`{"name":"ratio","in":"query","schema":{"type":"number","format":"float"}},` +
+		`{"name":"filter[tag]","in":"query","schema":{"type":"array","items":{"type":"string"},"default":["a","b"]}}` +
+		`]`
+	if string(got) != want {
+		t.Errorf("got  %s\nwant %s", got, want)
+	}
+
+	if _, err := g.Parameters(0); err == nil {
+		t.Error("expected an error for a non-struct")
+	}
+}
+
+func TestParametersEncoderEnum(t *testing.T) {
+	type priority int
+	type ticketParams struct {
+		Priority priority `schema:"priority"`
+		Status   status   `schema:"status"`
+	}
+	e := schema.NewEncoder()
+	e.RegisterEnum(priority(0), "1", "2", "3")
+	e.RegisterEnum(status(""), "open", "closed")
+	g := New(e)
+	g.RegisterEnum(status(""), "open")
+	params, err := g.Parameters(ticketParams{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, _ := json.Marshal(params)
+	want := `[` +
+		`{"name":"priority","in":"query","schema":{"type":"integer","format":"int64","enum":[1,2,3]}},` +
+		`{"name":"status","in":"query","schema":{"type":"string","enum":["open"]}}` +
+		`]`
+	if string(got) != want {
+		t.Errorf("got  %s\nwant %s", got, want)
+	}
+}
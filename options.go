@@ -0,0 +1,135 @@
+package schema
+
+// EncoderOption configures an Encoder created by NewEncoder.
+type EncoderOption func(*Encoder)
+
+// WithTag sets the tags used to locate custom field aliases, in order of
+// preference. See Encoder.SetAliasTags.
+func WithTag(tags ...string) EncoderOption {
+	return func(e *Encoder) {
+		e.SetAliasTags(tags...)
+	}
+}
+
+// WithNilString sets the value encoded for nil pointers.
+// See Encoder.SetNilString.
+func WithNilString(s string) EncoderOption {
+	return func(e *Encoder) {
+		e.SetNilString(s)
+	}
+}
+
+// WithSliceStyle sets how the keys of slice elements are built.
+// See Encoder.SetSliceKeyStyle.
+func WithSliceStyle(style SliceKeyStyle) EncoderOption {
+	return func(e *Encoder) {
+		e.SetSliceKeyStyle(style)
+	}
+}
+
+// WithNestedStyle sets how the keys of nested struct fields are built.
+// See Encoder.SetNestedKeyStyle.
+func WithNestedStyle(style NestedKeyStyle) EncoderOption {
+	return func(e *Encoder) {
+		e.SetNestedKeyStyle(style)
+	}
+}
+
+// WithNamingStrategy sets the naming strategy of fields without an alias.
+// See Encoder.SetNamingStrategy.
+func WithNamingStrategy(naming NamingStrategy) EncoderOption {
+	return func(e *Encoder) {
+		e.SetNamingStrategy(naming)
+	}
+}
+
+// WithOmitEmpty omits zero fields by default.
+// See Encoder.SetOmitEmptyByDefault.
+func WithOmitEmpty() EncoderOption {
+	return func(e *Encoder) {
+		e.SetOmitEmptyByDefault(true)
+	}
+}
+
+// WithKeyPrefix sets a prefix added to every encoded key.
+// See Encoder.SetKeyPrefix.
+func WithKeyPrefix(prefix string) EncoderOption {
+	return func(e *Encoder) {
+		e.SetKeyPrefix(prefix)
+	}
+}
+
+// WithEscaper sets the function used to escape keys and values.
+// See Encoder.SetEscaper.
+func WithEscaper(escape Escaper) EncoderOption {
+	return func(e *Encoder) {
+		e.SetEscaper(escape)
+	}
+}
+
+// WithDuplicateKeys sets how keys which already have values in the
+// destination are handled. See Encoder.SetDuplicateKeys.
+func WithDuplicateKeys(mode DuplicateKeyMode) EncoderOption {
+	return func(e *Encoder) {
+		e.SetDuplicateKeys(mode)
+	}
+}
+
+// WithMaxEncodedLength sets the maximum length of the encoded query.
+// See Encoder.SetMaxEncodedLength.
+func WithMaxEncodedLength(n int) EncoderOption {
+	return func(e *Encoder) {
+		e.SetMaxEncodedLength(n)
+	}
+}
+
+// DecoderOption configures a Decoder created by NewDecoder.
+type DecoderOption func(*Decoder)
+
+// WithDecodeTag sets the tags used to locate custom field aliases when
+// decoding, in order of preference. See Decoder.SetAliasTags.
+func WithDecodeTag(tags ...string) DecoderOption {
+	return func(d *Decoder) {
+		d.SetAliasTags(tags...)
+	}
+}
+
+// WithEmptyValues sets how empty values are decoded.
+// See Decoder.EmptyValues.
+func WithEmptyValues(mode EmptyMode) DecoderOption {
+	return func(d *Decoder) {
+		d.EmptyValues(mode)
+	}
+}
+
+// WithIgnoreUnknownKeys ignores the keys without a matching field.
+// See Decoder.IgnoreUnknownKeys.
+func WithIgnoreUnknownKeys() DecoderOption {
+	return func(d *Decoder) {
+		d.IgnoreUnknownKeys(true)
+	}
+}
+
+// WithWeaklyTypedInput decodes basic values loosely.
+// See Decoder.WeaklyTypedInput.
+func WithWeaklyTypedInput() DecoderOption {
+	return func(d *Decoder) {
+		d.WeaklyTypedInput(true)
+	}
+}
+
+// WithMaxSliceLength limits the number of elements decoded into a slice.
+// See Decoder.MaxSliceLength.
+func WithMaxSliceLength(n int) DecoderOption {
+	return func(d *Decoder) {
+		d.MaxSliceLength(n)
+	}
+}
+
+// WithValidator sets the function validating the decoded values.
+// See Decoder.SetValidator.
+func WithValidator(validator func(any) error) DecoderOption {
+	return func(d *Decoder) {
+		d.SetValidator(validator)
+	}
+}
@@ -0,0 +1,73 @@
+package schema
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"strings"
+)
+
+// ExpandURL fills the URL template tmpl from the fields of src.
+//
+// Path expressions such as {user_id} are replaced by the path escaped value
+// of the field tagged `path:"user_id"`; it is an error for one to be
+// missing. Query expressions such as {?status,limit} are replaced by the
+// listed query parameters encoded from src, in the order of the template,
+// and are omitted when none of them is set. {&status} continues a query
+// started in the template itself. For example:
+//
+//	u, err := encoder.ExpandURL(req, "/users/{user_id}/orders{?status,limit}")
+//
+// Fields with only a "path" tag are skipped when encoding query parameters.
+func (e *Encoder) ExpandURL(src any, tmpl string) (string, error) {
+	s := &encodeState{ctx: context.Background(), values: UrlValues{}, tag: "path"}
+	if err := e.encodeInto(src, s); err != nil {
+		return "", err
+	}
+	var query UrlValues
+	if strings.Contains(tmpl, "{?") || strings.Contains(tmpl, "{&") {
+		var err error
+		if query, err = e.EncodeValues(src); err != nil {
+			return "", err
+		}
+	}
+
+	var b strings.Builder
+	for {
+		start := strings.IndexByte(tmpl, '{')
+		if start < 0 {
+			break
+		}
+		end := strings.IndexByte(tmpl[start:], '}')
+		if end < 0 {
+			return "", fmt.Errorf("schema: unterminated expression in URL template %q", tmpl)
+		}
+		end += start
+		b.WriteString(tmpl[:start])
+		expr := tmpl[start+1 : end]
+		tmpl = tmpl[end+1:]
+
+		switch {
+		case strings.HasPrefix(expr, "?"), strings.HasPrefix(expr, "&"):
+			var selected UrlValues
+			for _, key := range strings.Split(expr[1:], ",") {
+				for _, v := range query {
+					if v.Key == key {
+						selected = append(selected, v)
+					}
+				}
+			}
+			if len(selected) > 0 {
+				b.WriteByte(expr[0])
+				b.WriteString(selected.EncodeWith(e.escape))
+			}
+		default:
+			if !s.values.Has(expr) {
+				return "", fmt.Errorf("schema: missing path parameter %q", expr)
+			}
+			b.WriteString(url.PathEscape(s.values.Get(expr)))
+		}
+	}
+	b.WriteString(tmpl)
+	return b.String(), nil
+}
@@ -0,0 +1,63 @@
+package schema
+
+import (
+	"testing"
+)
+
+type orderQuery struct {
+	UserID string   `path:"user_id"`
+	Status []string `schema:"status"`
+	Limit  int      `schema:"limit,omitempty"`
+	Sort   string   `schema:"sort"`
+}
+
+func TestExpandURL(t *testing.T) {
+	tests := []struct {
+		tmpl string
+		src  orderQuery
+		want string
+	}{
+		{
+			"/users/{user_id}/orders{?status,limit}",
+			orderQuery{UserID: "42", Status: []string{"open", "paid"}, Limit: 10, Sort: "date"},
+			"/users/42/orders?status=open&status=paid&limit=10",
+		},
+		{
+			"/users/{user_id}/orders{?limit,status}",
+			orderQuery{UserID: "a/b c", Status: []string{"open"}, Limit: 10},
+			"/users/a%2Fb%20c/orders?limit=10&status=open",
+		},
+		{
+			"/users/{user_id}/orders{?limit}",
+			orderQuery{UserID: "42"},
+			"/users/42/orders",
+		},
+		{
+			"/users/{user_id}?fixed=1{&sort}",
+			orderQuery{UserID: "42", Sort: "date"},
+			"/users/42?fixed=1&sort=date",
+		},
+	}
+	for _, tc := range tests {
+		got, err := NewEncoder().ExpandURL(tc.src, tc.tmpl)
+		noError(t, err)
+		if got != tc.want {
+			t.Errorf("%s: got %q, want %q", tc.tmpl, got, tc.want)
+		}
+	}
+}
+
+func TestExpandURLErrors(t *testing.T) {
+	for _, tmpl := range []string{"/users/{id}", "/users/{user_id"} {
+		if _, err := NewEncoder().ExpandURL(orderQuery{UserID: "42"}, tmpl); err == nil {
+			t.Errorf("%s: expected an error", tmpl)
+		}
+	}
+}
+
+func TestEncodeSkipsPathFields(t *testing.T) {
+	vals := map[string][]string{}
+	noError(t, NewEncoder().Encode(orderQuery{UserID: "42", Sort: "date"}, vals))
+	valExists(t, "sort", "date", vals)
+	valNotExists(t, "user_id", vals)
+}
@@ -0,0 +1,300 @@
+package schema
+
+import (
+	"fmt"
+	"reflect"
+	"strconv"
+)
+
+// structPlan is the precompiled encoding of a struct type, so encoding
+// doesn't parse the tags of its fields on every call.
+type structPlan struct {
+	fields []fieldPlan
+}
+
+// fieldPlan holds the resolved name, tag options and encoders of a field.
+type fieldPlan struct {
+	index int
+	// name is the Go name of the field, and alias its encoded name, or "-"
+	// if the field is ignored.
+	name  string
+	alias string
+	opts  tagOptions
+	typ   reflect.Type
+	// walk is set for untagged embedded fields when encoding a side tag:
+	// they are only encoded if they are nested structs.
+	walk bool
+	// flatten is set for fields whose nested fields aren't prefixed by
+	// their alias.
+	flatten bool
+
+	order    int
+	ordered  bool
+	orderErr error
+	// slice is the value of the slice option, and sliceStyle its style if
+	// it is valid.
+	slice      string
+	sliceStyle SliceKeyStyle
+	validSlice bool
+	// delim is the delimiter joining the elements of slices into a single
+	// value, if any.
+	delim string
+	// sep is the value of the sep option, and sepStyle the style of the
+	// keys of the nested fields if it is valid.
+	sep      string
+	sepStyle NestedKeyStyle
+	validSep bool
+	// duplicate is the value of the duplicate option, and duplicateMode
+	// its mode if it is valid.
+	duplicate      string
+	duplicateMode  DuplicateKeyMode
+	validDuplicate bool
+
+	file      bool
+	required  bool
+	def       string
+	omitNil   bool
+	omitZero  bool
+	omitEmpty bool
+	include   bool
+	emitEmpty bool
+	// alwaysOmit is set for fields of the types set by AlwaysOmitZero.
+	alwaysOmit bool
+	// enum holds the values allowed for the field, or its elements, if
+	// enumType was registered with RegisterEnum.
+	enum     []string
+	enumType reflect.Type
+	// isZero and isZeroValue are the zero checks of the field type, as by
+	// isZero and isZeroValue.
+	isZero      zeroFunc
+	isZeroValue zeroFunc
+
+	// enc and elemEnc are the encoders of the field and of its elements,
+	// resolved with the registered encoders without context, and keyEnc
+	// the encoder of its keys if it is a map.
+	enc     encoderFunc
+	elemEnc encoderFunc
+	keyEnc  mapKeyEncoder
+	custom  bool
+	// app and elemApp are the appendFuncs equivalent to enc and elemEnc,
+	// if any.
+	app     appendFunc
+	elemApp appendFunc
+}
+
+// planKey identifies a plan by struct type and side tag.
+type planKey struct {
+	t   reflect.Type
+	tag string
+}
+
+// planCache caches the plans compiled for a set of registered encoders.
+// The Encoder replaces it when a setting used to build the plans changes.
+type planCache struct {
+	m cowMap[planKey, *structPlan]
+	// regenc holds the registered encoders without context, and
+	// contextual is set if there are context-aware encoders too. regzero
+	// holds the registered zero checks, and omitZero the types set by
+	// AlwaysOmitZero, and enums the values registered by RegisterEnum.
+	regenc     map[reflect.Type]encoderFunc
+	regzero    map[reflect.Type]zeroFunc
+	omitZero   map[reflect.Type]bool
+	enums      map[reflect.Type][]string
+	contextual bool
+}
+
+// newPlans returns an empty planCache for the registered encoders and zero
+// checks of e. The caller must hold e.mu, unless e is not shared yet.
+func (e *Encoder) newPlans() *planCache {
+	return &planCache{regenc: e.regenc, regzero: e.regzero, omitZero: e.omitZero, enums: e.enums, contextual: len(e.regctx) > 0}
+}
+
+// get returns the plan of the struct type t for the side tag, or the
+// query parameters if tag is empty.
+func (c *planCache) get(e *Encoder, t reflect.Type, tag string) *structPlan {
+	key := planKey{t: t, tag: tag}
+	p, ok := c.m.load(key)
+	if !ok {
+		p = e.compilePlan(t, tag, c)
+		c.m.store(key, p)
+	}
+	return p
+}
+
+// compilePlan builds the plan of the struct type t.
+func (e *Encoder) compilePlan(t reflect.Type, tag string, c *planCache) *structPlan {
+	regenc := c.regenc
+	p := &structPlan{fields: make([]fieldPlan, t.NumField())}
+	for i := range p.fields {
+		field := t.Field(i)
+		f := &p.fields[i]
+		f.index, f.name, f.typ = i, field.Name, field.Type
+		f.alias, f.opts, f.walk = e.planFieldAlias(field, tag)
+		if f.alias == "-" {
+			continue
+		}
+		f.flatten = field.Anonymous || f.opts.Contains("flatten") || f.opts.Contains("squash")
+
+		if o, ok := f.opts.getOptionValue("order"); ok {
+			n, err := strconv.Atoi(o)
+			if err != nil {
+				f.orderErr = fmt.Errorf("schema: invalid order %q for %v", o, f.alias)
+			}
+			f.order, f.ordered = n, true
+		}
+		f.slice, _ = f.opts.getOptionValue("slice")
+		f.sliceStyle, f.validSlice = sliceKeyStyles[f.slice]
+		f.delim = f.opts.getDelimiter()
+		f.sep, _ = f.opts.getOptionValue("sep")
+		f.sepStyle, f.validSep = nestedKeySeparators[f.sep]
+		f.duplicate, _ = f.opts.getOptionValue("duplicate")
+		f.duplicateMode, f.validDuplicate = duplicateKeyModes[f.duplicate]
+
+		f.file = isFileField(field.Type, f.opts)
+		f.required = f.opts.Contains("required")
+		f.def = f.opts.getDefaultOptionValue()
+		f.omitNil = f.opts.Contains("omitnil")
+		f.omitZero = f.opts.Contains("omitzero")
+		f.omitEmpty = f.opts.Contains("omitempty")
+		f.include = f.opts.Contains("include")
+		f.alwaysOmit = c.omitZero[field.Type] || (field.Type.Kind() == reflect.Ptr && c.omitZero[field.Type.Elem()])
+		f.emitEmpty = f.opts.Contains("emitempty")
+		f.enumType, f.enum = enumOf(c.enums, field.Type)
+		if isZero, ok := c.regzero[field.Type]; ok {
+			f.isZero, f.isZeroValue = isZero, isZero
+		} else {
+			f.isZero = zeroCheck(field.Type)
+			f.isZeroValue = zeroValueCheck(field.Type)
+		}
+
+		f.enc = typeEncoder(field.Type, regenc, e.nilString)
+		switch field.Type.Kind() {
+		case reflect.Slice:
+			f.elemEnc = typeEncoder(field.Type.Elem(), regenc, e.nilString)
+		case reflect.Map:
+			f.elemEnc = typeEncoder(field.Type.Elem(), regenc, e.nilString)
+			f.keyEnc = mapKeyEncoderOf(field.Type.Key())
+		}
+		f.custom = hasCustomEncoder(field.Type, regenc)
+		f.app = typeAppender(field.Type, regenc, e.nilString)
+		if field.Type.Kind() == reflect.Slice {
+			f.elemApp = typeAppender(field.Type.Elem(), regenc, e.nilString)
+		}
+	}
+	return p
+}
+
+// planFieldAlias returns the alias and tag options of field when encoding
+// the side tag, or the query parameters if tag is empty. When encoding a
+// side tag such as "header", only fields with that tag are encoded, and
+// untagged embedded structs are walked. Otherwise, fields with only a side
+// tag are skipped.
+func (e *Encoder) planFieldAlias(field reflect.StructField, tag string) (alias string, opts tagOptions, walk bool) {
+	if tag == "" {
+		if hasSideTag(field, e.cache.tags) {
+			return "-", nil, false
+		}
+		alias, opts = e.fieldAlias(field)
+		return alias, opts, false
+	}
+	value := field.Tag.Get(tag)
+	if value == "" {
+		if field.Anonymous {
+			return field.Name, nil, true
+		}
+		return "-", nil, false
+	}
+	alias, opts = parseTag(value)
+	if alias == "" {
+		alias = field.Name
+	}
+	return alias, opts, false
+}
+
+// nestedStyle returns the style of the keys of the fields of the nested
+// struct of the field, given the style of its parent.
+func (f *fieldPlan) nestedStyle(style NestedKeyStyle) NestedKeyStyle {
+	if f.sep != "" {
+		return f.sepStyle
+	}
+	return style
+}
+
+// nestedPrefix returns the key prefix for the fields of the nested struct
+// of the field, given the style of its parent.
+func (f *fieldPlan) nestedPrefix(style NestedKeyStyle, prefix string) string {
+	if f.flatten {
+		return prefix
+	}
+	if style == NestedKeyFlat {
+		style = f.nestedStyle(style)
+	}
+	if style == NestedKeyFlat {
+		return prefix
+	}
+	return style.key(prefix, f.alias)
+}
+
+// encoders returns the encoders of the field and of its elements for the
+// encoding s, and whether the field type has a custom encoder. The
+// precompiled encoders are used unless s has context-aware encoders.
+func (f *fieldPlan) encoders(e *Encoder, s *encodeState) (enc, elemEnc encoderFunc, custom bool) {
+	if !s.plans.contextual {
+		return f.enc, f.elemEnc, f.custom
+	}
+	enc = typeEncoder(f.typ, s.regenc, e.nilString)
+	if k := f.typ.Kind(); k == reflect.Slice || k == reflect.Map {
+		elemEnc = typeEncoder(f.typ.Elem(), s.regenc, e.nilString)
+	}
+	return enc, elemEnc, hasCustomEncoder(f.typ, s.regenc)
+}
+
+// appender returns the appendFunc of the field for the encoding s, or nil
+// if it must be encoded by its encoder.
+func (f *fieldPlan) appender(s *encodeState) appendFunc {
+	if s.plans.contextual {
+		return nil
+	}
+	return f.app
+}
+
+// elemAppender is like appender for the elements of a slice field.
+func (f *fieldPlan) elemAppender(s *encodeState) appendFunc {
+	if s.plans.contextual {
+		return nil
+	}
+	return f.elemApp
+}
+
+// walkPlan walks the plans of the struct type t and of its nested structs
+// for the encoding s, as encode does without a value, and calls visit for
+// each field with its key and Go path. Ignored fields are visited too,
+// with the alias "-". nested is set for the fields whose struct is walked
+// after the call. Recursive types are only walked once.
+func (e *Encoder) walkPlan(s *encodeState, t reflect.Type, prefix, path string, visiting map[reflect.Type]bool, visit func(t reflect.Type, f *fieldPlan, key, path string, nested bool)) {
+	visiting[t] = true
+	defer delete(visiting, t)
+
+	plan := s.plans.get(e, t, s.tag)
+	for i := range plan.fields {
+		f := &plan.fields[i]
+		fieldPath := path + f.name
+		if f.alias == "-" {
+			visit(t, f, "", fieldPath, false)
+			continue
+		}
+		_, _, custom := f.encoders(e, s)
+		ft := f.typ
+		if ft.Kind() == reflect.Ptr && ft.Elem().Kind() == reflect.Struct && !custom {
+			ft = ft.Elem()
+		}
+		nested := !f.file && ft.Kind() == reflect.Struct && typeEncoder(ft, s.regenc, e.nilString) == nil
+		style := s.opts.NestedKeyStyle
+		visit(t, f, style.key(prefix, f.alias), fieldPath, nested)
+		if nested && !visiting[ft] && (f.sep == "" || f.validSep) {
+			s.opts.NestedKeyStyle = f.nestedStyle(style)
+			e.walkPlan(s, ft, f.nestedPrefix(style, prefix), fieldPath+".", visiting, visit)
+			s.opts.NestedKeyStyle = style
+		}
+	}
+}
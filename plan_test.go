@@ -0,0 +1,62 @@
+package schema
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestEncodePlanReset(t *testing.T) {
+	type Item struct {
+		Name  string `schema:"name"`
+		Price int
+	}
+	e := NewEncoder()
+	values, err := e.EncodeValues(Item{Name: "a", Price: 2})
+	noError(t, err)
+	want := UrlValues{{"name", "a"}, {"Price", "2"}}
+	if !reflect.DeepEqual(values, want) {
+		t.Errorf("got %v, want %v", values, want)
+	}
+
+	// Settings used by the cached plans must apply to the next encoding.
+	e.RegisterEncoder(0, func(v reflect.Value) string { return "n" + encodeInt(v) })
+	e.SetNamingStrategy(SnakeCase)
+	values, err = e.EncodeValues(Item{Name: "a", Price: 2})
+	noError(t, err)
+	want = UrlValues{{"name", "a"}, {"price", "n2"}}
+	if !reflect.DeepEqual(values, want) {
+		t.Errorf("got %v, want %v", values, want)
+	}
+
+	e.SetAliasTag("json")
+	values, err = e.EncodeValues(Item{Name: "a", Price: 2})
+	noError(t, err)
+	want = UrlValues{{"name", "a"}, {"price", "n2"}}
+	if !reflect.DeepEqual(values, want) {
+		t.Errorf("got %v, want %v", values, want)
+	}
+}
+
+func BenchmarkEncode(b *testing.B) {
+	type Filter struct {
+		Status []string `schema:"status"`
+		Owner  *string  `schema:"owner,omitnil"`
+	}
+	type Query struct {
+		Q      string  `schema:"q,required"`
+		Limit  int     `schema:"limit,omitempty"`
+		Sort   string  `schema:"sort,default=date"`
+		Price  float64 `schema:"price,omitzero"`
+		Filter Filter  `schema:"filter"`
+		Page   *int    `schema:"page"`
+	}
+	page := 2
+	src := Query{Q: "shoes", Limit: 10, Filter: Filter{Status: []string{"open", "paid"}}, Page: &page}
+	e := NewEncoder()
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		if _, err := e.EncodeValues(src); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
@@ -0,0 +1,161 @@
+package schema
+
+import (
+	"context"
+	"reflect"
+	"strings"
+)
+
+// EncodeReport describes how the fields of a struct were encoded.
+type EncodeReport struct {
+	// Keys lists the keys produced by the fields, in the order they were
+	// encoded. Values added by hooks are not listed.
+	Keys []EncodedKey
+	// Skipped lists the fields which produced no value, with the reason.
+	Skipped []SkippedField
+}
+
+// EncodedKey is a key produced by a struct field.
+type EncodedKey struct {
+	// Field is the Go path of the field, such as "Filter.Status".
+	Field string
+	Key   string
+}
+
+// SkippedField is a struct field which produced no value.
+type SkippedField struct {
+	// Field is the Go path of the field, such as "Filter.Status".
+	Field  string
+	Reason SkipReason
+}
+
+// SkipReason is the reason why a field produced no value.
+type SkipReason string
+
+const (
+	// SkipIgnored is for fields tagged "-", and fields with only a
+	// "header", "cookie" or "path" tag.
+	SkipIgnored SkipReason = "ignored"
+	// SkipExcluded is for fields not selected by the Include or Exclude
+	// options.
+	SkipExcluded SkipReason = "excluded"
+	// SkipFile is for file fields, which are only encoded by
+	// EncodeMultipart.
+	SkipFile SkipReason = "file"
+	// SkipOmitEmpty is for empty fields with the omitempty option, or
+	// when omitting empty fields by default.
+	SkipOmitEmpty SkipReason = "omitempty"
+	// SkipOmitNil is for nil fields with the omitnil option, or encoded
+	// by a Codec.
+	SkipOmitNil SkipReason = "omitnil"
+	// SkipOmitZero is for zero fields with the omitzero option.
+	SkipOmitZero SkipReason = "omitzero"
+	// SkipUnsupported is for fields without an encoder. Encoding them
+	// also returns an error.
+	SkipUnsupported SkipReason = "unsupported"
+)
+
+// EncodeWithReport is like EncodeValues but also reports which keys were
+// produced by which fields, and which fields were skipped. It is meant for
+// debugging requests and for asserting coverage in tests.
+func (e *Encoder) EncodeWithReport(src any) (UrlValues, *EncodeReport, error) {
+	report := &EncodeReport{}
+	s := &encodeState{ctx: context.Background(), values: UrlValues{}, opts: e.opts, report: report}
+	if err := e.encodeInto(src, s); err != nil {
+		return nil, nil, err
+	}
+	return s.values, report, nil
+}
+
+// field returns the Go path of the field name of the struct being encoded.
+// It is only computed when reporting or checking the keys.
+func (s *encodeState) field(name string) string {
+	if s.report == nil && !s.opts.StrictKeys {
+		return ""
+	}
+	return s.path(name)
+}
+
+// path returns the Go path of the field name of the struct being encoded.
+func (s *encodeState) path(name string) string {
+	if len(s.parents) == 0 {
+		return name
+	}
+	return strings.Join(s.parents, ".") + "." + name
+}
+
+// add appends a value encoded from field.
+func (s *encodeState) add(field, key, value string) {
+	s.values = append(s.values, UrlValue{Key: key, Value: value})
+	if s.opts.StrictKeys {
+		s.claim(field, key)
+	}
+	if s.duplicate != nil {
+		if s.duplicates == nil {
+			s.duplicates = map[string]DuplicateKeyMode{}
+		}
+		// The mode applies to the key as prefixed by encodeInto.
+		s.duplicates[s.opts.KeyPrefix+key] = *s.duplicate
+	}
+	if s.report != nil {
+		s.report.Keys = append(s.report.Keys, EncodedKey{Field: field, Key: key})
+	}
+}
+
+// skip records that field produced no value.
+func (s *encodeState) skip(field string, reason SkipReason) {
+	if s.report != nil {
+		s.report.Skipped = append(s.report.Skipped, SkippedField{Field: field, Reason: reason})
+	}
+}
+
+// encodeNested encodes the nested struct v of the field name, whose keys
+// are built in the given style, tracking its Go path for the report and
+// the errors.
+func (e *Encoder) encodeNested(v reflect.Value, prefix, path, name string, style NestedKeyStyle, s *encodeState) error {
+	if s.parents == nil {
+		s.parents = s.parentsBuf[:0]
+	}
+	s.parents = append(s.parents, name)
+	parentStyle := s.opts.NestedKeyStyle
+	s.opts.NestedKeyStyle = style
+	err := e.encode(v, prefix, path, s)
+	s.opts.NestedKeyStyle = parentStyle
+	s.parents = s.parents[:len(s.parents)-1]
+	return err
+}
+
+// claim records that field is encoded as key, adding a KeyConflictError
+// if another field is encoded as the same key.
+func (s *encodeState) claim(field, key string) {
+	if s.keys == nil {
+		s.keys = map[string]string{}
+	}
+	other, ok := s.keys[key]
+	if !ok {
+		s.keys[key] = field
+		return
+	}
+	if other != field {
+		if s.conflicts == nil {
+			s.conflicts = MultiError{}
+		}
+		s.conflicts[field] = KeyConflictError{Key: s.opts.KeyPrefix + key, Fields: [2]string{other, field}}
+	}
+}
+
+// withConflicts adds the KeyConflictErrors to the errors of the encoding.
+func (s *encodeState) withConflicts(err error) error {
+	if len(s.conflicts) == 0 {
+		return err
+	}
+	errs, ok := err.(MultiError)
+	if !ok {
+		if err != nil {
+			return err
+		}
+		errs = MultiError{}
+	}
+	errs.merge(s.conflicts)
+	return errs
+}
@@ -0,0 +1,66 @@
+package schema
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestEncodeWithReport(t *testing.T) {
+	type Filter struct {
+		Status []string `schema:"status"`
+		Owner  *string  `schema:"owner,omitnil"`
+	}
+	type Query struct {
+		Q      string  `schema:"q"`
+		Limit  int     `schema:"limit,omitempty"`
+		Secret string  `schema:"-"`
+		Token  string  `header:"Authorization"`
+		Avatar File    `schema:"avatar"`
+		Filter Filter  `schema:"filter"`
+		Price  float64 `schema:"price,omitzero"`
+	}
+	src := Query{Q: "shoes", Filter: Filter{Status: []string{"open", "paid"}}}
+
+	values, report, err := NewEncoder().EncodeWithReport(src)
+	noError(t, err)
+
+	wantValues := UrlValues{{"q", "shoes"}, {"status", "open"}, {"status", "paid"}}
+	if !reflect.DeepEqual(values, wantValues) {
+		t.Errorf("got values %v, want %v", values, wantValues)
+	}
+	wantKeys := []EncodedKey{
+		{Field: "Q", Key: "q"},
+		{Field: "Filter.Status", Key: "status"},
+		{Field: "Filter.Status", Key: "status"},
+	}
+	if !reflect.DeepEqual(report.Keys, wantKeys) {
+		t.Errorf("got keys %v, want %v", report.Keys, wantKeys)
+	}
+	wantSkipped := []SkippedField{
+		{Field: "Limit", Reason: SkipOmitEmpty},
+		{Field: "Secret", Reason: SkipIgnored},
+		{Field: "Token", Reason: SkipIgnored},
+		{Field: "Avatar", Reason: SkipFile},
+		{Field: "Filter.Owner", Reason: SkipOmitNil},
+		{Field: "Price", Reason: SkipOmitZero},
+	}
+	if !reflect.DeepEqual(report.Skipped, wantSkipped) {
+		t.Errorf("got skipped %v, want %v", report.Skipped, wantSkipped)
+	}
+}
+
+func TestEncodeWithReportExcluded(t *testing.T) {
+	type Query struct {
+		Q    string `schema:"q"`
+		Page int    `schema:"page"`
+	}
+	e := NewEncoder()
+	e.opts.Exclude = []string{"page"}
+	_, report, err := e.EncodeWithReport(Query{Q: "a", Page: 2})
+	noError(t, err)
+
+	want := []SkippedField{{Field: "Page", Reason: SkipExcluded}}
+	if !reflect.DeepEqual(report.Skipped, want) {
+		t.Errorf("got skipped %v, want %v", report.Skipped, want)
+	}
+}
@@ -0,0 +1,198 @@
+package schema
+
+import (
+	"context"
+	"io"
+	"mime"
+	"net/http"
+	"net/url"
+	"reflect"
+	"strings"
+)
+
+// formContentType is the content type of URL encoded form bodies.
+const formContentType = "application/x-www-form-urlencoded"
+
+// NewRequest returns a new http.Request with params encoded by a default
+// Encoder. See Encoder.NewRequest.
+func NewRequest(ctx context.Context, method, url string, params any) (*http.Request, error) {
+	return defaultEncoder().NewRequest(ctx, method, url, params)
+}
+
+// NewRequest returns a new http.Request with params encoded into the query
+// for methods without a body, such as GET and DELETE, and into a form body
+// for POST, PUT and PATCH, setting the Content-Type header accordingly.
+// If params is nil, nothing is encoded.
+func (e *Encoder) NewRequest(ctx context.Context, method, url string, params any) (*http.Request, error) {
+	if params == nil || !hasBody(method) {
+		req, err := http.NewRequestWithContext(ctx, method, url, nil)
+		if err != nil || params == nil {
+			return req, err
+		}
+		if err := e.EncodeIntoURL(params, req.URL); err != nil {
+			return nil, err
+		}
+		return req, nil
+	}
+	body, err := e.EncodeToString(params)
+	if err != nil {
+		return nil, err
+	}
+	req, err := http.NewRequestWithContext(ctx, method, url, strings.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", formContentType)
+	return req, nil
+}
+
+// EncodeBody encodes src into an application/x-www-form-urlencoded body,
+// keeping the order of the struct fields. It returns the body and its
+// content type, suitable for http.Post.
+//
+// The body is encoded pair by pair as it is read, so the encoded form is
+// never held in memory as a whole.
+func (e *Encoder) EncodeBody(src any) (io.Reader, string, error) {
+	values, err := e.EncodeValues(src)
+	if err != nil {
+		return nil, "", err
+	}
+	escape := e.escape
+	if escape == nil {
+		escape = url.QueryEscape
+	}
+	return &valuesReader{values: values, escape: escape}, formContentType, nil
+}
+
+// valuesReader reads UrlValues encoded as a query string.
+type valuesReader struct {
+	values  UrlValues
+	escape  Escaper
+	next    int
+	pending []byte
+}
+
+func (r *valuesReader) Read(p []byte) (int, error) {
+	n := 0
+	for n < len(p) {
+		if len(r.pending) == 0 {
+			if r.next >= len(r.values) {
+				break
+			}
+			pair := r.values[r.next]
+			if r.next > 0 {
+				r.pending = append(r.pending, '&')
+			}
+			r.pending = append(r.pending, r.escape(pair.Key)...)
+			r.pending = append(r.pending, '=')
+			r.pending = append(r.pending, r.escape(pair.Value)...)
+			r.next++
+		}
+		c := copy(p[n:], r.pending)
+		r.pending = r.pending[c:]
+		n += c
+	}
+	if n == 0 && len(p) > 0 {
+		return 0, io.EOF
+	}
+	return n, nil
+}
+
+// defaultMaxMemory is the memory used to parse multipart forms by
+// DecodeRequest, as by http.Request.FormValue.
+const defaultMaxMemory = 32 << 20
+
+// DecodeRequest decodes the query and form body of r into dst, and the
+// fields tagged with "path" from the path values of r, as set by
+// http.ServeMux patterns such as "/users/{id}".
+//
+// The body is only parsed for application/x-www-form-urlencoded and
+// multipart/form-data requests; other bodies, such as JSON, are left
+// unread. The files of multipart forms are decoded into the fields of type
+// *multipart.FileHeader or []*multipart.FileHeader of the same name, with
+// the limits set by MaxFileSize and MaxSliceLength; reading the body stops
+// at the first file larger than MaxFileSize. The values of a key in the
+// body replace the ones in the query, and path values are decoded last, so
+// they take precedence over both.
+// dst is validated once all of them are decoded without error.
+func (d *Decoder) DecodeRequest(dst any, r *http.Request) error {
+	src, err := ParseQueryWithSeparators(r.URL.RawQuery, "&;")
+	if err != nil {
+		return err
+	}
+	values := src.Values()
+	if r.Body != nil && r.Body != http.NoBody {
+		ct, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+		switch ct {
+		case formContentType:
+			err = r.ParseForm()
+		case "multipart/form-data":
+			err = d.readMultipart(r, defaultMaxMemory)
+		}
+		if e, ok := err.(FileSizeError); ok {
+			return MultiError{e.Key: d.fileError(dst, e)}
+		}
+		if err != nil {
+			return err
+		}
+		for key, vs := range r.PostForm {
+			values[key] = vs
+		}
+	}
+	errs := MultiError{}
+	if err := d.decodeValues(dst, values); err != nil {
+		m, ok := err.(MultiError)
+		if !ok {
+			return err
+		}
+		errs.merge(m)
+	}
+	if r.MultipartForm != nil && len(r.MultipartForm.File) > 0 {
+		for key := range r.MultipartForm.File {
+			// The files satisfy the required option of their field.
+			if _, ok := errs[key].(MissingFieldError); ok {
+				delete(errs, key)
+			}
+		}
+		if err := d.decodeFiles(dst, r.MultipartForm.File); err != nil {
+			errs.merge(err.(MultiError))
+		}
+	}
+	if err := d.decodePath(dst, r); err != nil {
+		m, ok := err.(MultiError)
+		if !ok {
+			return err
+		}
+		errs.merge(m)
+	}
+	if len(errs) > 0 {
+		return errs
+	}
+	return d.validate(dst)
+}
+
+// decodePath decodes the fields of dst tagged with "path" from the path
+// values of r.
+func (d *Decoder) decodePath(dst any, r *http.Request) error {
+	t := reflect.TypeOf(dst)
+	if t == nil || t.Kind() != reflect.Ptr || t.Elem().Kind() != reflect.Struct {
+		return nil
+	}
+	side := d.side("path")
+	src := map[string][]string{}
+	for _, f := range side.cache.get(t.Elem()).fields {
+		if v := r.PathValue(f.alias); v != "" {
+			src[f.alias] = []string{v}
+		}
+	}
+	return side.decodeValues(dst, src)
+}
+
+// hasBody reports whether params are sent in the body for method.
+func hasBody(method string) bool {
+	switch strings.ToUpper(method) {
+	case http.MethodPost, http.MethodPut, http.MethodPatch:
+		return true
+	}
+	return false
+}
@@ -0,0 +1,204 @@
+package schema
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+type requestParams struct {
+	Q    string `schema:"q"`
+	Page int    `schema:"page"`
+}
+
+func TestNewRequest(t *testing.T) {
+	params := requestParams{Q: "a b", Page: 2}
+
+	req, err := NewRequest(context.Background(), http.MethodGet, "https://api.test/search?x=1", params)
+	noError(t, err)
+	if expected := "https://api.test/search?x=1&q=a+b&page=2"; req.URL.String() != expected {
+		t.Errorf("Expected %q, got %q", expected, req.URL.String())
+	}
+	if req.Body != nil || req.Header.Get("Content-Type") != "" {
+		t.Errorf("Expected GET request without body, got %v", req.Header)
+	}
+
+	req, err = NewRequest(context.Background(), http.MethodPost, "https://api.test/search", &params)
+	noError(t, err)
+	if req.URL.RawQuery != "" {
+		t.Errorf("Expected empty query, got %q", req.URL.RawQuery)
+	}
+	if ct := req.Header.Get("Content-Type"); ct != "application/x-www-form-urlencoded" {
+		t.Errorf("Unexpected Content-Type %q", ct)
+	}
+	body, _ := io.ReadAll(req.Body)
+	if expected := "q=a+b&page=2"; string(body) != expected || req.ContentLength != int64(len(expected)) {
+		t.Errorf("Expected body %q, got %q (%d bytes)", expected, body, req.ContentLength)
+	}
+
+	req, err = NewRequest(context.Background(), http.MethodPut, "https://api.test/", nil)
+	noError(t, err)
+	if req.Body != nil {
+		t.Error("Expected request without body for nil params")
+	}
+
+	if _, err := NewRequest(context.Background(), http.MethodGet, "https://api.test/", "invalid"); err == nil {
+		t.Error("Expected error for invalid params, got nil")
+	}
+}
+
+func TestEncodeBody(t *testing.T) {
+	type S struct {
+		IDs []int  `schema:"id"`
+		Q   string `schema:"q"`
+	}
+	src := S{Q: "a&b"}
+	for i := 0; i < 1000; i++ {
+		src.IDs = append(src.IDs, i)
+	}
+
+	encoder := NewEncoder()
+	body, contentType, err := encoder.EncodeBody(src)
+	noError(t, err)
+	if contentType != "application/x-www-form-urlencoded" {
+		t.Errorf("Unexpected content type %q", contentType)
+	}
+	data, err := io.ReadAll(body)
+	noError(t, err)
+
+	expected, err := encoder.EncodeToString(src)
+	noError(t, err)
+	if string(data) != expected {
+		t.Errorf("Expected %q, got %q", expected, data)
+	}
+
+	body, _, err = encoder.EncodeBody(struct{}{})
+	noError(t, err)
+	if data, _ := io.ReadAll(body); len(data) != 0 {
+		t.Errorf("Expected empty body, got %q", data)
+	}
+}
+
+func TestDecodeRequest(t *testing.T) {
+	type params struct {
+		ID   int      `path:"id"`
+		Q    string   `schema:"q"`
+		Page int      `schema:"page"`
+		Tags []string `schema:"tag"`
+	}
+	var got params
+	mux := http.NewServeMux()
+	mux.HandleFunc("/items/{id}", func(w http.ResponseWriter, r *http.Request) {
+		noError(t, NewDecoder().DecodeRequest(&got, r))
+	})
+
+	r, _ := http.NewRequest(http.MethodPost, "/items/7?q=query&page=1&tag=a", strings.NewReader("q=body&tag=b&tag=c"))
+	r.Header.Set("Content-Type", "application/x-www-form-urlencoded; charset=utf-8")
+	mux.ServeHTTP(httptest.NewRecorder(), r)
+	want := params{ID: 7, Q: "body", Page: 1, Tags: []string{"b", "c"}}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %+v, want %+v", got, want)
+	}
+
+	got = params{}
+	r, _ = http.NewRequest(http.MethodPost, "/items/8?q=query", strings.NewReader(`{"q":"json"}`))
+	r.Header.Set("Content-Type", "application/json")
+	mux.ServeHTTP(httptest.NewRecorder(), r)
+	if want := (params{ID: 8, Q: "query"}); !reflect.DeepEqual(got, want) {
+		t.Errorf("got %+v, want %+v", got, want)
+	}
+
+	r, _ = http.NewRequest(http.MethodGet, "/?page=x", nil)
+	err := NewDecoder().DecodeRequest(&got, r)
+	if _, ok := err.(MultiError)["page"].(ConversionError); !ok {
+		t.Errorf("expected a ConversionError, got %v", err)
+	}
+}
+
+func TestDecodeRequestFiles(t *testing.T) {
+	type upload struct {
+		Title       string                  `schema:"title"`
+		Avatar      *multipart.FileHeader   `schema:"avatar,required"`
+		Attachments []*multipart.FileHeader `schema:"attachment,max=2"`
+	}
+	newRequest := func(files map[string][]string) *http.Request {
+		var body bytes.Buffer
+		w := multipart.NewWriter(&body)
+		w.WriteField("title", "hello")
+		for name, contents := range files {
+			for i, content := range contents {
+				fw, _ := w.CreateFormFile(name, fmt.Sprintf("%s%d.txt", name, i))
+				io.WriteString(fw, content)
+			}
+		}
+		w.Close()
+		r, _ := http.NewRequest(http.MethodPost, "/", &body)
+		r.Header.Set("Content-Type", w.FormDataContentType())
+		return r
+	}
+
+	var dst upload
+	d := NewDecoder()
+	d.MaxFileSize(4)
+	r := newRequest(map[string][]string{"avatar": {"a"}, "attachment": {"bb", "cc"}})
+	noError(t, d.DecodeRequest(&dst, r))
+	if dst.Title != "hello" || dst.Avatar == nil || dst.Avatar.Filename != "avatar0.txt" || len(dst.Attachments) != 2 {
+		t.Errorf("unexpected result %+v", dst)
+	}
+
+	tests := []struct {
+		files map[string][]string
+		key   string
+		want  error
+	}{
+		{map[string][]string{"attachment": {"a"}}, "avatar", MissingFieldError{Key: "avatar", Field: "Avatar", Type: reflect.TypeOf(dst.Avatar)}},
+		{map[string][]string{"avatar": {"large"}}, "avatar", FileSizeError{Key: "avatar", Field: "Avatar", Name: "avatar0.txt", Size: 5, Max: 4}},
+		{map[string][]string{"avatar": {"a"}, "attachment": {"a", "b", "c"}}, "attachment", SliceLengthError{Key: "attachment", Field: "Attachments", Len: 3, Max: 2}},
+	}
+	for _, tc := range tests {
+		var dst upload
+		err := d.DecodeRequest(&dst, newRequest(tc.files))
+		errs, _ := err.(MultiError)
+		if got := errs[tc.key]; !reflect.DeepEqual(got, tc.want) {
+			t.Errorf("%v: got %#v, want %#v", tc.files, got, tc.want)
+		}
+	}
+}
+
+// unreadBody fails the test if the body is read past its first reader.
+type unreadBody struct {
+	t *testing.T
+}
+
+func (b unreadBody) Read(p []byte) (int, error) {
+	b.t.Error("body read past the file larger than MaxFileSize")
+	return 0, io.ErrUnexpectedEOF
+}
+
+func TestDecodeRequestFileSizeStopsReading(t *testing.T) {
+	type upload struct {
+		Avatar *multipart.FileHeader `schema:"avatar"`
+	}
+	var head bytes.Buffer
+	w := multipart.NewWriter(&head)
+	fw, _ := w.CreateFormFile("avatar", "avatar.png")
+	fw.Write(bytes.Repeat([]byte("a"), 1024))
+	r, _ := http.NewRequest(http.MethodPost, "/", io.MultiReader(&head, unreadBody{t}))
+	r.Header.Set("Content-Type", w.FormDataContentType())
+
+	var dst upload
+	d := NewDecoder()
+	d.MaxFileSize(16)
+	err := d.DecodeRequest(&dst, r)
+	want := FileSizeError{Key: "avatar", Field: "Avatar", Name: "avatar.png", Size: 17, Max: 16}
+	if got := err.(MultiError)["avatar"]; !reflect.DeepEqual(got, want) {
+		t.Errorf("got %#v, want %#v", got, want)
+	}
+}
@@ -0,0 +1,77 @@
+package schema
+
+import (
+	"maps"
+	"net/http"
+	"os"
+	"slices"
+	"strings"
+)
+
+// Source is a set of keys with values, decoded by Decoder.DecodeSource.
+type Source interface {
+	// Get returns the values of the key.
+	Get(key string) []string
+	// Keys returns the keys of the source.
+	Keys() []string
+}
+
+// ValuesSource is a Source of query values, such as url.Values.
+type ValuesSource map[string][]string
+
+// Get returns the values of the key.
+func (s ValuesSource) Get(key string) []string { return s[key] }
+
+// Keys returns the sorted keys of the source.
+func (s ValuesSource) Keys() []string { return slices.Sorted(maps.Keys(s)) }
+
+// HeaderSource is a Source of HTTP headers, whose keys are the canonical
+// header names.
+type HeaderSource http.Header
+
+// Get returns the values of the header key, case-insensitively.
+func (s HeaderSource) Get(key string) []string { return http.Header(s).Values(key) }
+
+// Keys returns the sorted header names of the source.
+func (s HeaderSource) Keys() []string { return slices.Sorted(maps.Keys(s)) }
+
+// StringSource is a Source with a single value per key, such as parsed
+// command line flags.
+type StringSource map[string]string
+
+// Get returns the value of the key, if any.
+func (s StringSource) Get(key string) []string {
+	if v, ok := s[key]; ok {
+		return []string{v}
+	}
+	return nil
+}
+
+// Keys returns the sorted keys of the source.
+func (s StringSource) Keys() []string { return slices.Sorted(maps.Keys(s)) }
+
+// EnvSource returns a Source of the environment variables starting with
+// prefix, keyed by the rest of their name. A double underscore separates
+// the keys of nested structs, so with the prefix "APP_", APP_DB__HOST has
+// the key "DB.HOST", which decodes into the host field of the db struct as
+// keys are matched case-insensitively.
+func EnvSource(prefix string) Source {
+	s := StringSource{}
+	for _, kv := range os.Environ() {
+		k, v, _ := strings.Cut(kv, "=")
+		if k, ok := strings.CutPrefix(k, prefix); ok && k != "" {
+			s[strings.ReplaceAll(k, "__", ".")] = v
+		}
+	}
+	return s
+}
+
+// DecodeSource decodes the keys and values of src into dst as Decode does.
+func (d *Decoder) DecodeSource(dst any, src Source) error {
+	keys := src.Keys()
+	values := make(map[string][]string, len(keys))
+	for _, key := range keys {
+		values[key] = src.Get(key)
+	}
+	return d.Decode(dst, values)
+}
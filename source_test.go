@@ -0,0 +1,47 @@
+package schema
+
+import (
+	"net/http"
+	"net/url"
+	"reflect"
+	"testing"
+)
+
+type sourceConfig struct {
+	Name  string   `schema:"name"`
+	Port  int      `schema:"port"`
+	Hosts []string `schema:"hosts,comma"`
+	DB    struct {
+		Host string `schema:"host"`
+	} `schema:"db"`
+}
+
+func TestDecodeSource(t *testing.T) {
+	want := sourceConfig{Name: "app", Port: 8080, Hosts: []string{"a", "b"}}
+	want.DB.Host = "localhost"
+
+	t.Setenv("TEST_SOURCE_NAME", "app")
+	t.Setenv("TEST_SOURCE_PORT", "8080")
+	t.Setenv("TEST_SOURCE_HOSTS", "a,b")
+	t.Setenv("TEST_SOURCE_DB__HOST", "localhost")
+
+	h := http.Header{}
+	h.Set("Name", "app")
+	h.Set("Port", "8080")
+	h.Add("Hosts", "a")
+	h.Add("Hosts", "b")
+	h.Set("Db.Host", "localhost")
+
+	for name, src := range map[string]Source{
+		"values": ValuesSource(url.Values{"name": {"app"}, "port": {"8080"}, "hosts": {"a,b"}, "db.host": {"localhost"}}),
+		"header": HeaderSource(h),
+		"string": StringSource{"name": "app", "port": "8080", "hosts": "a,b", "db.host": "localhost"},
+		"env":    EnvSource("TEST_SOURCE_"),
+	} {
+		var dst sourceConfig
+		noError(t, NewDecoder().DecodeSource(&dst, src))
+		if !reflect.DeepEqual(dst, want) {
+			t.Errorf("%s: got %+v, want %+v", name, dst, want)
+		}
+	}
+}
@@ -0,0 +1,97 @@
+package schema
+
+import (
+	"net/url"
+	"strconv"
+	"strings"
+)
+
+// Template is a base URL parsed once, to which the parameters encoded from
+// a struct are added by URL. It is meant for clients building many URLs to
+// the same endpoint: the base URL is not parsed again, the Encoder caches
+// the encoding plan of the struct and the values are encoded into pooled
+// memory, so URL allocates little more than the returned string.
+//
+// A Template is safe for concurrent use.
+type Template struct {
+	e *Encoder
+	// base is the URL without its query and fragment, query its raw query
+	// and fragment its escaped fragment with the leading '#', if any.
+	base, query, fragment string
+}
+
+// NewTemplate parses base and returns a Template encoding with an Encoder
+// configured by opts, or with a default Encoder if there are none.
+func NewTemplate(base string, opts ...EncoderOption) (*Template, error) {
+	u, err := url.Parse(base)
+	if err != nil {
+		return nil, err
+	}
+	e := defaultEncoder()
+	if len(opts) > 0 {
+		e = NewEncoder(opts...)
+	}
+	t := &Template{e: e, query: u.RawQuery}
+	if u.Fragment != "" {
+		t.fragment = "#" + u.EscapedFragment()
+	}
+	u.RawQuery, u.ForceQuery, u.Fragment, u.RawFragment = "", false, "", ""
+	t.base = u.String()
+	return t, nil
+}
+
+// MustTemplate is like NewTemplate but panics if base can't be parsed. It
+// simplifies the initialization of global variables holding templates.
+func MustTemplate(base string, opts ...EncoderOption) *Template {
+	t, err := NewTemplate(base, opts...)
+	if err != nil {
+		panic(`schema: MustTemplate(` + strconv.Quote(base) + `): ` + err.Error())
+	}
+	return t
+}
+
+// URL returns the URL of the template with the parameters encoded from
+// params added to its query, after any parameters of the base URL. If
+// params is nil, nothing is encoded.
+func (t *Template) URL(params any) (string, error) {
+	values := AcquireValues()
+	defer values.Release()
+	if params != nil {
+		if err := t.e.EncodeValuesInto(params, values); err != nil {
+			return "", err
+		}
+	}
+	if len(*values) == 0 && t.query == "" {
+		return t.base + t.fragment, nil
+	}
+
+	n := len(t.base) + len(t.query) + len(t.fragment) + 2*len(*values)
+	for _, p := range *values {
+		n += len(p.Key) + len(p.Value)
+	}
+	var b strings.Builder
+	b.Grow(n)
+	b.WriteString(t.base)
+	b.WriteByte('?')
+	b.WriteString(t.query)
+	for i, p := range *values {
+		if i > 0 || t.query != "" {
+			b.WriteByte('&')
+		}
+		t.writeEscaped(&b, p.Key)
+		b.WriteByte('=')
+		t.writeEscaped(&b, p.Value)
+	}
+	b.WriteString(t.fragment)
+	return b.String(), nil
+}
+
+// writeEscaped writes s escaped with the escaper of the Encoder, or as by
+// url.QueryEscape, to b.
+func (t *Template) writeEscaped(b *strings.Builder, s string) {
+	if t.e.escape != nil {
+		b.WriteString(t.e.escape(s))
+		return
+	}
+	writeQueryEscaped(b, s)
+}
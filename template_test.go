@@ -0,0 +1,65 @@
+package schema
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestTemplate(t *testing.T) {
+	type params struct {
+		Q     string `schema:"q"`
+		Limit int    `schema:"limit,omitempty"`
+	}
+	tests := []struct {
+		base   string
+		params any
+		want   string
+	}{
+		{"https://api.example.com/search", params{Q: "a b", Limit: 10}, "https://api.example.com/search?q=a+b&limit=10"},
+		{"https://api.example.com/search?v=2", params{Q: "x"}, "https://api.example.com/search?v=2&q=x"},
+		{"https://api.example.com/search?v=2#top", params{Q: "x"}, "https://api.example.com/search?v=2&q=x#top"},
+		{"https://api.example.com/search", nil, "https://api.example.com/search"},
+		{"/search?v=2", nil, "/search?v=2"},
+	}
+	for _, tt := range tests {
+		got, err := MustTemplate(tt.base).URL(tt.params)
+		noError(t, err)
+		if got != tt.want {
+			t.Errorf("%s: got %q, want %q", tt.base, got, tt.want)
+		}
+	}
+
+	tmpl := MustTemplate("/search", WithEscaper(EscapeRFC3986))
+	got, err := tmpl.URL(params{Q: "a b"})
+	noError(t, err)
+	if want := "/search?q=a%20b"; got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+	if _, err := tmpl.URL(struct{ C chan int }{}); err == nil {
+		t.Error("expected an error encoding an unsupported field")
+	}
+}
+
+func TestMustTemplatePanics(t *testing.T) {
+	defer func() {
+		if r := recover(); r == nil || !strings.Contains(r.(string), "MustTemplate") {
+			t.Errorf("unexpected panic value %v", r)
+		}
+	}()
+	MustTemplate("http://[::1")
+}
+
+func BenchmarkTemplateURL(b *testing.B) {
+	type params struct {
+		Q     string `schema:"q"`
+		Limit int    `schema:"limit"`
+	}
+	tmpl := MustTemplate("https://api.example.com/search")
+	p := params{Q: "golang", Limit: 20}
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		if _, err := tmpl.URL(p); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
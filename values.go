@@ -1,17 +1,196 @@
 package schema
 
 import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"iter"
 	"net/url"
+	"sort"
 	"strings"
+	"sync"
 )
 
+// UrlValue is a single key/value pair.
 type UrlValue struct {
 	Key   string
 	Value string
 }
 
+// UrlValues is an ordered list of key/value pairs, as produced by
+// Encoder.EncodeValues. Unlike url.Values, it keeps the order in which
+// the pairs were added, and a key may appear several times.
 type UrlValues []UrlValue
 
+// ParseQuery parses the URL-encoded query string and returns the pairs in
+// the order they appear in the query. It is an order-preserving
+// alternative to url.ParseQuery and follows the same rules: pairs are
+// separated by '&', and a query containing a semicolon is rejected.
+//
+// As url.ParseQuery, ParseQuery keeps parsing after an invalid pair and
+// returns the first error encountered.
+func ParseQuery(query string) (UrlValues, error) {
+	return parseQuery(query, "&", true)
+}
+
+// ParseQueryWithSeparators is like ParseQuery but pairs may be separated by
+// any of the bytes in separators. For instance "&;" accepts both '&' and
+// the legacy ';' separator.
+func ParseQueryWithSeparators(query, separators string) (UrlValues, error) {
+	return parseQuery(query, separators, false)
+}
+
+func parseQuery(query, separators string, rejectSemicolon bool) (UrlValues, error) {
+	values := UrlValues{}
+	var err error
+	for query != "" {
+		pair := query
+		if i := strings.IndexAny(query, separators); i >= 0 {
+			pair, query = query[:i], query[i+1:]
+		} else {
+			query = ""
+		}
+		if rejectSemicolon && strings.Contains(pair, ";") {
+			if err == nil {
+				err = errors.New("schema: invalid semicolon separator in query")
+			}
+			continue
+		}
+		if pair == "" {
+			continue
+		}
+		key, value, _ := strings.Cut(pair, "=")
+		key, err1 := url.QueryUnescape(key)
+		if err1 != nil {
+			if err == nil {
+				err = err1
+			}
+			continue
+		}
+		value, err1 = url.QueryUnescape(value)
+		if err1 != nil {
+			if err == nil {
+				err = err1
+			}
+			continue
+		}
+		values = append(values, UrlValue{Key: key, Value: value})
+	}
+	return values, err
+}
+
+// QueryWarning describes a malformed pair of a query parsed by
+// ParseQueryLenient.
+type QueryWarning struct {
+	Pair    string // pair as it appears in the query.
+	Message string // what was wrong with it and how it was recovered.
+}
+
+func (w QueryWarning) String() string {
+	return fmt.Sprintf("schema: query pair %q: %s", w.Pair, w.Message)
+}
+
+// ParseQueryLenient is like ParseQuery but recovers from malformed queries,
+// such as the ones of third-party callbacks with broken encoders, instead of
+// failing. Leading '?' are ignored, as are empty pairs. Invalid escapes,
+// semicolons and '#' are kept as literal characters, and pairs without a
+// key are dropped; a warning is returned for each of these pairs.
+func ParseQueryLenient(query string) (UrlValues, []QueryWarning) {
+	values := UrlValues{}
+	var warnings []QueryWarning
+	for _, pair := range strings.Split(strings.TrimLeft(query, "?"), "&") {
+		if pair == "" {
+			continue
+		}
+		var problems []string
+		if strings.ContainsAny(pair, ";#") {
+			problems = append(problems, "unescaped ';' or '#' kept literally")
+		}
+		rawKey, rawValue, _ := strings.Cut(pair, "=")
+		key, ok1 := lenientUnescape(rawKey)
+		value, ok2 := lenientUnescape(rawValue)
+		if !ok1 || !ok2 {
+			problems = append(problems, "invalid escapes kept literally")
+		}
+		if key == "" {
+			warnings = append(warnings, QueryWarning{Pair: pair, Message: "empty key, pair dropped"})
+			continue
+		}
+		if len(problems) > 0 {
+			warnings = append(warnings, QueryWarning{Pair: pair, Message: strings.Join(problems, ", ")})
+		}
+		values = append(values, UrlValue{Key: key, Value: value})
+	}
+	return values, warnings
+}
+
+// lenientUnescape unescapes s as url.QueryUnescape does, keeping invalid
+// escapes as they are. ok is false if there were any.
+func lenientUnescape(s string) (unescaped string, ok bool) {
+	if !strings.ContainsAny(s, "%+") {
+		return s, true
+	}
+	ok = true
+	buf := make([]byte, 0, len(s))
+	for i := 0; i < len(s); i++ {
+		switch c := s[i]; {
+		case c == '+':
+			buf = append(buf, ' ')
+		case c == '%' && i+2 < len(s) && isHex(s[i+1]) && isHex(s[i+2]):
+			buf = append(buf, unhex(s[i+1])<<4|unhex(s[i+2]))
+			i += 2
+		case c == '%':
+			buf, ok = append(buf, c), false
+		default:
+			buf = append(buf, c)
+		}
+	}
+	return string(buf), ok
+}
+
+func isHex(c byte) bool {
+	return '0' <= c && c <= '9' || 'a' <= c && c <= 'f' || 'A' <= c && c <= 'F'
+}
+
+func unhex(c byte) byte {
+	switch {
+	case c <= '9':
+		return c - '0'
+	case c <= 'F':
+		return c - 'A' + 10
+	}
+	return c - 'a' + 10
+}
+
+// FromURLValues converts url.Values to UrlValues. As url.Values doesn't
+// keep the order of its keys, the keys are sorted in byte order; the values
+// of each key keep their order.
+func FromURLValues(values url.Values) UrlValues {
+	keys := make([]string, 0, len(values))
+	n := 0
+	for k, vs := range values {
+		keys = append(keys, k)
+		n += len(vs)
+	}
+	sort.Strings(keys)
+	v := make(UrlValues, 0, n)
+	for _, k := range keys {
+		for _, value := range values[k] {
+			v = append(v, UrlValue{Key: k, Value: value})
+		}
+	}
+	return v
+}
+
+// ToURLValues converts the values to url.Values. The order of the keys is
+// lost, but the values of each key keep their relative order.
+func (v UrlValues) ToURLValues() url.Values {
+	return url.Values(v.Values())
+}
+
+// Values returns the pairs grouped by key, in a form that can be used as
+// url.Values. The values of each key keep their relative order.
 func (v UrlValues) Values() map[string][]string {
 	m := map[string][]string{}
 	for _, p := range v {
@@ -20,19 +199,467 @@ func (v UrlValues) Values() map[string][]string {
 	return m
 }
 
+// All returns an iterator over the key/value pairs, in order.
+func (v UrlValues) All() iter.Seq2[string, string] {
+	return func(yield func(string, string) bool) {
+		for _, p := range v {
+			if !yield(p.Key, p.Value) {
+				return
+			}
+		}
+	}
+}
+
+// Get gets the first value associated with the given key.
+// If there are no values associated with the key, Get returns
+// the empty string.
+func (v UrlValues) Get(key string) string {
+	for _, p := range v {
+		if p.Key == key {
+			return p.Value
+		}
+	}
+	return ""
+}
+
+// Has checks whether a given key is set.
+func (v UrlValues) Has(key string) bool {
+	for _, p := range v {
+		if p.Key == key {
+			return true
+		}
+	}
+	return false
+}
+
+// Len returns the number of key/value pairs.
+func (v UrlValues) Len() int {
+	return len(v)
+}
+
+// Keys returns the distinct keys, in the order of their first occurrence.
+func (v UrlValues) Keys() []string {
+	var keys []string
+	seen := make(map[string]bool, len(v))
+	for _, p := range v {
+		if !seen[p.Key] {
+			seen[p.Key] = true
+			keys = append(keys, p.Key)
+		}
+	}
+	return keys
+}
+
+// ValuesOf returns the values associated with the given key, in order, or
+// nil if the key is not set.
+func (v UrlValues) ValuesOf(key string) []string {
+	var values []string
+	for _, p := range v {
+		if p.Key == key {
+			values = append(values, p.Value)
+		}
+	}
+	return values
+}
+
+// Filter returns new values with the pairs for which keep returns true, in
+// order, e.g. to drop credentials before logging the values. v is not
+// modified.
+func (v UrlValues) Filter(keep func(key, value string) bool) UrlValues {
+	filtered := make(UrlValues, 0, len(v))
+	for _, p := range v {
+		if keep(p.Key, p.Value) {
+			filtered = append(filtered, p)
+		}
+	}
+	return filtered
+}
+
+// Add adds the value to key, after any existing values.
+func (v *UrlValues) Add(key, value string) {
+	*v = append(*v, UrlValue{Key: key, Value: value})
+}
+
+// Set sets the key to value, replacing any existing values. The key keeps
+// the position of its first occurrence, or is appended if it is not set.
+func (v *UrlValues) Set(key, value string) {
+	set := false
+	n := 0
+	for _, p := range *v {
+		if p.Key == key {
+			if set {
+				continue
+			}
+			p.Value, set = value, true
+		}
+		(*v)[n] = p
+		n++
+	}
+	*v = (*v)[:n]
+	if !set {
+		v.Add(key, value)
+	}
+}
+
+// Del deletes the values associated with key, keeping the order of the
+// remaining pairs.
+func (v *UrlValues) Del(key string) {
+	n := 0
+	for _, p := range *v {
+		if p.Key != key {
+			(*v)[n] = p
+			n++
+		}
+	}
+	*v = (*v)[:n]
+}
+
+// RemoveDuplicates removes the pairs that repeat both the key and the value
+// of a previous pair, keeping the first occurrence.
+func (v *UrlValues) RemoveDuplicates() {
+	seen := make(map[UrlValue]bool, len(*v))
+	n := 0
+	for _, p := range *v {
+		if !seen[p] {
+			seen[p] = true
+			(*v)[n] = p
+			n++
+		}
+	}
+	*v = (*v)[:n]
+}
+
+// MergeStrategy controls how Merge handles keys present in both values.
+type MergeStrategy int
+
+const (
+	// MergeAppend appends the other values after the existing ones.
+	MergeAppend MergeStrategy = iota
+	// MergeReplace replaces the existing values of a key with the other
+	// values, at the position of the first existing value.
+	MergeReplace
+	// MergeKeepFirst keeps the existing values of a key and ignores the
+	// other values.
+	MergeKeepFirst
+)
+
+// Merge merges other into v using the given strategy. Keys only present in
+// other are appended in their order.
+func (v *UrlValues) Merge(other UrlValues, strategy MergeStrategy) {
+	if strategy == MergeAppend {
+		*v = append(*v, other...)
+		return
+	}
+	existing := make(map[string]bool, len(*v))
+	for _, p := range *v {
+		existing[p.Key] = true
+	}
+	merged := make(UrlValues, 0, len(*v)+len(other))
+	if strategy == MergeReplace {
+		replaced := map[string]bool{}
+		for _, p := range *v {
+			if !other.Has(p.Key) {
+				merged = append(merged, p)
+				continue
+			}
+			if !replaced[p.Key] {
+				replaced[p.Key] = true
+				for _, o := range other {
+					if o.Key == p.Key {
+						merged = append(merged, o)
+					}
+				}
+			}
+		}
+	} else {
+		merged = append(merged, *v...)
+	}
+	for _, o := range other {
+		if !existing[o.Key] {
+			merged = append(merged, o)
+		}
+	}
+	*v = merged
+}
+
+// Clone returns a copy of the values that doesn't share memory with v.
+func (v UrlValues) Clone() UrlValues {
+	if v == nil {
+		return nil
+	}
+	return append(make(UrlValues, 0, len(v)), v...)
+}
+
+// maxPooledValues is the capacity above which released values are not
+// pooled, so that a single large encoding doesn't pin memory.
+const maxPooledValues = 1024
+
+var valuesPool = sync.Pool{
+	New: func() any { return new(UrlValues) },
+}
+
+// AcquireValues returns empty values from a pool, to encode into without
+// allocating in high-throughput services. Call Release when done with them:
+//
+//	values := schema.AcquireValues()
+//	defer values.Release()
+//	if err := encoder.EncodeValuesInto(src, values); err != nil {
+//		...
+//	}
+func AcquireValues() *UrlValues {
+	return valuesPool.Get().(*UrlValues)
+}
+
+// Release empties v and returns it to the pool used by AcquireValues,
+// keeping its memory for reuse. v must not be used after Release.
+func (v *UrlValues) Release() {
+	if cap(*v) > maxPooledValues {
+		return
+	}
+	clear(*v)
+	*v = (*v)[:0]
+	valuesPool.Put(v)
+}
+
+// SortKeys sorts the pairs by key using less. The sort is stable, so the
+// values of a key keep their relative order.
+func (v UrlValues) SortKeys(less func(a, b string) bool) {
+	sort.SliceStable(v, func(i, j int) bool {
+		return less(v[i].Key, v[j].Key)
+	})
+}
+
+// SortKeysAlphabetically sorts the pairs by key in byte order, keeping the
+// relative order of the values of a key.
+func (v UrlValues) SortKeysAlphabetically() {
+	v.SortKeys(func(a, b string) bool {
+		return a < b
+	})
+}
+
+// Encode encodes the values into "URL encoded" form ("bar=baz&foo=quux"),
+// keeping the order of the pairs.
 func (v UrlValues) Encode() string {
+	return v.EncodeFormat(QueryFormat{})
+}
+
+// Escaper escapes a key or value for inclusion in an encoded query.
+type Escaper func(string) string
+
+// QueryFormat controls how UrlValues are encoded.
+type QueryFormat struct {
+	// Escape escapes keys and values. If nil, url.QueryEscape is used.
+	Escape Escaper
+	// Separator separates the pairs. If zero, '&' is used.
+	Separator byte
+}
+
+// EncodeWith is like Encode but escapes keys and values with escape, e.g.
+// to leave some characters literal. A nil escape encodes like Encode.
+func (v UrlValues) EncodeWith(escape Escaper) string {
+	return v.EncodeFormat(QueryFormat{Escape: escape})
+}
+
+// EncodeFormat encodes the values using the given format, keeping the
+// order of the pairs. For instance, legacy semicolon-delimited queries can
+// be produced with QueryFormat{Separator: ';'}.
+func (v UrlValues) EncodeFormat(f QueryFormat) string {
+	if f.Separator == 0 {
+		f.Separator = '&'
+	}
+	return v.encode(f)
+}
+
+// EncodeRFC3986 is like Encode but escapes keys and values strictly as
+// defined by RFC 3986, as required by most request signing schemes: spaces
+// are encoded as "%20" instead of "+" and every character other than
+// letters, digits, '-', '.', '_' and '~' is percent-encoded.
+func (v UrlValues) EncodeRFC3986() string {
+	return v.EncodeFormat(QueryFormat{Escape: EscapeRFC3986})
+}
+
+// Canonicalize returns the canonical form used by request signing schemes
+// such as AWS Signature Version 4 and OAuth 1.0: keys and values are
+// escaped as in EncodeRFC3986 and the pairs are sorted in byte order by
+// escaped key, then by escaped value.
+func (v UrlValues) Canonicalize() string {
+	escaped := make(UrlValues, len(v))
+	for i, p := range v {
+		escaped[i] = UrlValue{Key: EscapeRFC3986(p.Key), Value: EscapeRFC3986(p.Value)}
+	}
+	sort.Slice(escaped, func(i, j int) bool {
+		if escaped[i].Key != escaped[j].Key {
+			return escaped[i].Key < escaped[j].Key
+		}
+		return escaped[i].Value < escaped[j].Value
+	})
+	return escaped.EncodeRaw()
+}
+
+// EncodeRaw joins the keys and values without escaping them, e.g. to build
+// a signature base string that is escaped later by another algorithm.
+func (v UrlValues) EncodeRaw() string {
+	return v.EncodeFormat(QueryFormat{Escape: rawEscape})
+}
+
+func rawEscape(s string) string {
+	return s
+}
+
+// encode encodes the values with f. A nil f.Escape escapes as
+// url.QueryEscape, writing directly to the output.
+func (v UrlValues) encode(f QueryFormat) string {
 	if len(v) == 0 {
 		return ""
 	}
-	var buf strings.Builder
+	if f.Escape != nil {
+		var buf strings.Builder
+		_, _ = v.writeTo(&buf, f)
+		return buf.String()
+	}
+	// Most keys and values don't need escaping, so their length is a good
+	// estimate of the output size.
+	n := 2*len(v) - 1
 	for _, p := range v {
-		keyEscaped := url.QueryEscape(p.Key)
-		if buf.Len() > 0 {
-			buf.WriteByte('&')
+		n += len(p.Key) + len(p.Value)
+	}
+	var buf strings.Builder
+	buf.Grow(n)
+	for i, p := range v {
+		if i > 0 {
+			buf.WriteByte(f.Separator)
 		}
-		buf.WriteString(keyEscaped)
+		writeQueryEscaped(&buf, p.Key)
 		buf.WriteByte('=')
-		buf.WriteString(url.QueryEscape(p.Value))
+		writeQueryEscaped(&buf, p.Value)
 	}
 	return buf.String()
 }
+
+// encodedLength returns the length of the values encoded with escape, or
+// escaped as by url.QueryEscape if escape is nil.
+func encodedLength(v UrlValues, escape Escaper) int {
+	if len(v) == 0 {
+		return 0
+	}
+	n := 2*len(v) - 1
+	for _, p := range v {
+		if escape != nil {
+			n += len(escape(p.Key)) + len(escape(p.Value))
+		} else {
+			n += queryEscapedLength(p.Key) + queryEscapedLength(p.Value)
+		}
+	}
+	return n
+}
+
+// queryEscapedLength returns the length of s escaped as by
+// url.QueryEscape.
+func queryEscapedLength(s string) int {
+	n := len(s)
+	for i := 0; i < len(s); i++ {
+		if c := s[i]; !isUnreserved(c) && c != ' ' {
+			n += 2
+		}
+	}
+	return n
+}
+
+// writeQueryEscaped writes s escaped as by url.QueryEscape to buf.
+func writeQueryEscaped(buf *strings.Builder, s string) {
+	const upperhex = "0123456789ABCDEF"
+	for i := 0; i < len(s); i++ {
+		switch c := s[i]; {
+		case isUnreserved(c):
+			buf.WriteByte(c)
+		case c == ' ':
+			buf.WriteByte('+')
+		default:
+			buf.WriteByte('%')
+			buf.WriteByte(upperhex[c>>4])
+			buf.WriteByte(upperhex[c&15])
+		}
+	}
+}
+
+// WriteTo writes the values encoded as by Encode to w, without building
+// the whole encoded string in memory. The pairs are written piece by piece,
+// so w should be buffered.
+func (v UrlValues) WriteTo(w io.Writer) (int64, error) {
+	return v.writeTo(w, QueryFormat{Escape: url.QueryEscape, Separator: '&'})
+}
+
+func (v UrlValues) writeTo(w io.Writer, f QueryFormat) (int64, error) {
+	var written int64
+	sep := string(f.Separator)
+	for i, p := range v {
+		parts := [4]string{sep, f.Escape(p.Key), "=", f.Escape(p.Value)}
+		start := 1
+		if i > 0 {
+			start = 0
+		}
+		for _, part := range parts[start:] {
+			n, err := io.WriteString(w, part)
+			written += int64(n)
+			if err != nil {
+				return written, err
+			}
+		}
+	}
+	return written, nil
+}
+
+// EscapeRFC3986 percent-encodes s so that only the unreserved characters
+// defined by RFC 3986 remain literal.
+func EscapeRFC3986(s string) string {
+	n := 0
+	for i := 0; i < len(s); i++ {
+		if !isUnreserved(s[i]) {
+			n++
+		}
+	}
+	if n == 0 {
+		return s
+	}
+	const upperhex = "0123456789ABCDEF"
+	buf := make([]byte, 0, len(s)+2*n)
+	for i := 0; i < len(s); i++ {
+		if c := s[i]; isUnreserved(c) {
+			buf = append(buf, c)
+		} else {
+			buf = append(buf, '%', upperhex[c>>4], upperhex[c&15])
+		}
+	}
+	return string(buf)
+}
+
+// isUnreserved reports whether c is an unreserved character in RFC 3986.
+func isUnreserved(c byte) bool {
+	return 'a' <= c && c <= 'z' || 'A' <= c && c <= 'Z' || '0' <= c && c <= '9' ||
+		c == '-' || c == '.' || c == '_' || c == '~'
+}
+
+// MarshalJSON encodes the values as an array of [key, value] pairs, keeping
+// their order, e.g. [["b","1"],["a","2"]].
+func (v UrlValues) MarshalJSON() ([]byte, error) {
+	pairs := make([][2]string, len(v))
+	for i, p := range v {
+		pairs[i] = [2]string{p.Key, p.Value}
+	}
+	return json.Marshal(pairs)
+}
+
+// UnmarshalJSON decodes an array of [key, value] pairs as produced by
+// MarshalJSON.
+func (v *UrlValues) UnmarshalJSON(data []byte) error {
+	var pairs [][2]string
+	if err := json.Unmarshal(data, &pairs); err != nil {
+		return err
+	}
+	values := make(UrlValues, len(pairs))
+	for i, p := range pairs {
+		values[i] = UrlValue{Key: p[0], Value: p[1]}
+	}
+	*v = values
+	return nil
+}
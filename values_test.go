@@ -0,0 +1,441 @@
+package schema
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestUrlValuesMutation(t *testing.T) {
+	values := UrlValues{
+		{Key: "a", Value: "1"},
+		{Key: "b", Value: "2"},
+		{Key: "a", Value: "3"},
+		{Key: "c", Value: "4"},
+	}
+
+	if got := values.Get("a"); got != "1" {
+		t.Errorf("Expected Get(a) to be 1, got %q", got)
+	}
+	if got := values.Get("z"); got != "" {
+		t.Errorf("Expected Get(z) to be empty, got %q", got)
+	}
+	if !values.Has("c") || values.Has("z") {
+		t.Errorf("Unexpected Has result for %v", values)
+	}
+
+	values.Set("a", "5")
+	values.Add("d", "6")
+	values.Set("e", "7")
+	values.Del("b")
+
+	expected := UrlValues{
+		{Key: "a", Value: "5"},
+		{Key: "c", Value: "4"},
+		{Key: "d", Value: "6"},
+		{Key: "e", Value: "7"},
+	}
+	if !reflect.DeepEqual(values, expected) {
+		t.Errorf("Expected %v, got %v", expected, values)
+	}
+	if got := values.Encode(); got != "a=5&c=4&d=6&e=7" {
+		t.Errorf("Unexpected encoding %q", got)
+	}
+}
+
+func TestParseQuery(t *testing.T) {
+	values, err := ParseQuery("z=1&a=hello+world&z=2&empty=&bare&q=%2F%26")
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	expected := UrlValues{
+		{Key: "z", Value: "1"},
+		{Key: "a", Value: "hello world"},
+		{Key: "z", Value: "2"},
+		{Key: "empty", Value: ""},
+		{Key: "bare", Value: ""},
+		{Key: "q", Value: "/&"},
+	}
+	if !reflect.DeepEqual(values, expected) {
+		t.Errorf("Expected %v, got %v", expected, values)
+	}
+
+	values, err = ParseQuery("a=1&b=%zz&c=3;d=4&e=5")
+	if err == nil {
+		t.Error("Expected error for invalid query, got nil")
+	}
+	expected = UrlValues{{Key: "a", Value: "1"}, {Key: "e", Value: "5"}}
+	if !reflect.DeepEqual(values, expected) {
+		t.Errorf("Expected %v, got %v", expected, values)
+	}
+}
+
+func TestParseQueryLenient(t *testing.T) {
+	values, warnings := ParseQueryLenient("??a=1&&b=%zz&c=50%&d=%41+b&bare&=x&e=1;2&f=a#b&g=%E2%9C%93&")
+	expected := UrlValues{
+		{Key: "a", Value: "1"},
+		{Key: "b", Value: "%zz"},
+		{Key: "c", Value: "50%"},
+		{Key: "d", Value: "A b"},
+		{Key: "bare", Value: ""},
+		{Key: "e", Value: "1;2"},
+		{Key: "f", Value: "a#b"},
+		{Key: "g", Value: "✓"},
+	}
+	if !reflect.DeepEqual(values, expected) {
+		t.Errorf("Expected %v, got %v", expected, values)
+	}
+	var pairs []string
+	for _, w := range warnings {
+		pairs = append(pairs, w.Pair)
+	}
+	if want := []string{"b=%zz", "c=50%", "=x", "e=1;2", "f=a#b"}; !reflect.DeepEqual(pairs, want) {
+		t.Errorf("Expected warnings for %v, got %v", want, warnings)
+	}
+
+	values, warnings = ParseQueryLenient("z=1&a=hello+world&q=%2F%26")
+	if len(warnings) > 0 {
+		t.Errorf("Unexpected warnings %v", warnings)
+	}
+	strict, _ := ParseQuery("z=1&a=hello+world&q=%2F%26")
+	if !reflect.DeepEqual(values, strict) {
+		t.Errorf("Expected %v, got %v", strict, values)
+	}
+}
+
+func TestUrlValuesJSON(t *testing.T) {
+	values := UrlValues{
+		{Key: "z", Value: "1"},
+		{Key: "a", Value: "2"},
+		{Key: "z", Value: "3"},
+	}
+	data, err := json.Marshal(values)
+	if err != nil {
+		t.Fatalf("Failed to marshal: %v", err)
+	}
+	if string(data) != `[["z","1"],["a","2"],["z","3"]]` {
+		t.Errorf("Unexpected JSON %s", data)
+	}
+
+	var decoded UrlValues
+	if err := json.Unmarshal(data, &decoded); err != nil {
+		t.Fatalf("Failed to unmarshal: %v", err)
+	}
+	if !reflect.DeepEqual(values, decoded) {
+		t.Errorf("Expected %v, got %v", values, decoded)
+	}
+
+	if err := json.Unmarshal([]byte(`{"a":"1"}`), &decoded); err == nil {
+		t.Error("Expected error for JSON object, got nil")
+	}
+}
+
+func TestUrlValuesMerge(t *testing.T) {
+	base := UrlValues{
+		{Key: "a", Value: "1"},
+		{Key: "b", Value: "2"},
+		{Key: "a", Value: "3"},
+	}
+	other := UrlValues{
+		{Key: "c", Value: "4"},
+		{Key: "a", Value: "5"},
+		{Key: "a", Value: "6"},
+	}
+
+	tests := []struct {
+		strategy MergeStrategy
+		expected UrlValues
+	}{
+		{MergeAppend, UrlValues{{"a", "1"}, {"b", "2"}, {"a", "3"}, {"c", "4"}, {"a", "5"}, {"a", "6"}}},
+		{MergeReplace, UrlValues{{"a", "5"}, {"a", "6"}, {"b", "2"}, {"c", "4"}}},
+		{MergeKeepFirst, UrlValues{{"a", "1"}, {"b", "2"}, {"a", "3"}, {"c", "4"}}},
+	}
+	for _, tc := range tests {
+		values := base.Clone()
+		values.Merge(other, tc.strategy)
+		if !reflect.DeepEqual(values, tc.expected) {
+			t.Errorf("Strategy %d: expected %v, got %v", tc.strategy, tc.expected, values)
+		}
+	}
+
+	if base.Get("a") != "1" || len(base) != 3 {
+		t.Errorf("Merge modified the original values: %v", base)
+	}
+}
+
+func TestUrlValuesClone(t *testing.T) {
+	values := UrlValues{{Key: "a", Value: "1"}}
+	clone := values.Clone()
+	clone.Set("a", "2")
+	if values.Get("a") != "1" {
+		t.Errorf("Clone shares memory with the original values")
+	}
+	if UrlValues(nil).Clone() != nil {
+		t.Errorf("Expected clone of nil values to be nil")
+	}
+}
+
+func TestAcquireValues(t *testing.T) {
+	values := AcquireValues()
+	if len(*values) != 0 {
+		t.Fatalf("Expected empty values, got %v", *values)
+	}
+	values.Add("a", "1")
+	values.Add("b", "2")
+	backing := (*values)[:2]
+	values.Release()
+	if len(*values) != 0 {
+		t.Errorf("Expected released values to be empty, got %v", *values)
+	}
+	if backing[0] != (UrlValue{}) {
+		t.Errorf("Expected released values to be cleared, got %v", backing)
+	}
+}
+
+func TestEncodeValuesInto(t *testing.T) {
+	type S struct {
+		A string `schema:"a"`
+		B int    `schema:"b,required"`
+	}
+	values := AcquireValues()
+	defer values.Release()
+	values.Add("x", "0")
+
+	noError(t, NewEncoder().EncodeValuesInto(S{A: "1", B: 2}, values))
+	want := UrlValues{{"x", "0"}, {"a", "1"}, {"b", "2"}}
+	if !reflect.DeepEqual(*values, want) {
+		t.Errorf("Expected %v, got %v", want, *values)
+	}
+
+	if err := NewEncoder().EncodeValuesInto(S{A: "1"}, values); err == nil {
+		t.Error("Expected an error for a missing required field")
+	}
+	if !reflect.DeepEqual(*values, want) {
+		t.Errorf("Expected values to be unchanged on error, got %v", *values)
+	}
+}
+
+func TestUrlValuesAll(t *testing.T) {
+	values := UrlValues{{"b", "1"}, {"a", "2"}, {"b", "3"}}
+	var got UrlValues
+	for k, v := range values.All() {
+		got = append(got, UrlValue{Key: k, Value: v})
+	}
+	if !reflect.DeepEqual(values, got) {
+		t.Errorf("Expected %v, got %v", values, got)
+	}
+
+	n := 0
+	for range values.All() {
+		n++
+		break
+	}
+	if n != 1 {
+		t.Errorf("Expected iteration to stop after break, got %d pairs", n)
+	}
+}
+
+func TestUrlValuesInspection(t *testing.T) {
+	values := UrlValues{{"b", "1"}, {"token", "secret"}, {"a", "2"}, {"b", "3"}}
+	if values.Len() != 4 {
+		t.Errorf("Expected 4 pairs, got %d", values.Len())
+	}
+	if keys := values.Keys(); !reflect.DeepEqual(keys, []string{"b", "token", "a"}) {
+		t.Errorf("Expected keys in order, got %v", keys)
+	}
+	if got := values.ValuesOf("b"); !reflect.DeepEqual(got, []string{"1", "3"}) {
+		t.Errorf("Expected [1 3], got %v", got)
+	}
+	if got := values.ValuesOf("c"); got != nil {
+		t.Errorf("Expected nil, got %v", got)
+	}
+
+	filtered := values.Filter(func(key, value string) bool { return key != "token" })
+	want := UrlValues{{"b", "1"}, {"a", "2"}, {"b", "3"}}
+	if !reflect.DeepEqual(filtered, want) {
+		t.Errorf("Expected %v, got %v", want, filtered)
+	}
+	if values.Get("token") != "secret" || values.Len() != 4 {
+		t.Errorf("Expected the values to be unchanged, got %v", values)
+	}
+	filtered[0].Value = "changed"
+	if values.Get("b") != "1" {
+		t.Errorf("Expected the filtered values to be independent, got %v", values)
+	}
+	if got := values.Filter(func(key, value string) bool { return key == "b" }).Encode(); got != "b=1&b=3" {
+		t.Errorf("Expected b=1&b=3, got %s", got)
+	}
+	if keys := (UrlValues{}).Keys(); keys != nil {
+		t.Errorf("Expected no keys, got %v", keys)
+	}
+}
+
+func TestUrlValuesSortKeys(t *testing.T) {
+	values := UrlValues{{"b", "1"}, {"a", "2"}, {"B", "3"}, {"b", "0"}, {"a", "1"}}
+	values.SortKeysAlphabetically()
+	expected := UrlValues{{"B", "3"}, {"a", "2"}, {"a", "1"}, {"b", "1"}, {"b", "0"}}
+	if !reflect.DeepEqual(values, expected) {
+		t.Errorf("Expected %v, got %v", expected, values)
+	}
+
+	values.SortKeys(func(a, b string) bool {
+		return strings.ToLower(a) > strings.ToLower(b)
+	})
+	expected = UrlValues{{"B", "3"}, {"b", "1"}, {"b", "0"}, {"a", "2"}, {"a", "1"}}
+	if !reflect.DeepEqual(values, expected) {
+		t.Errorf("Expected %v, got %v", expected, values)
+	}
+}
+
+func TestUrlValuesEncodeRFC3986(t *testing.T) {
+	values := UrlValues{
+		{Key: "q", Value: "hello world"},
+		{Key: "a+b", Value: "x=1&y=*~"},
+		{Key: "utf", Value: "é"},
+	}
+	if got, expected := values.Encode(), "q=hello+world&a%2Bb=x%3D1%26y%3D%2A~&utf=%C3%A9"; got != expected {
+		t.Errorf("Expected %q, got %q", expected, got)
+	}
+	if got, expected := values.EncodeRFC3986(), "q=hello%20world&a%2Bb=x%3D1%26y%3D%2A~&utf=%C3%A9"; got != expected {
+		t.Errorf("Expected %q, got %q", expected, got)
+	}
+	if got := EscapeRFC3986("AZaz09-._~"); got != "AZaz09-._~" {
+		t.Errorf("Expected unreserved characters to be kept, got %q", got)
+	}
+}
+
+func TestUrlValuesEncodeWith(t *testing.T) {
+	values := UrlValues{{Key: "fields", Value: "id,name"}, {Key: "time", Value: "10:30 am"}}
+	escape := func(s string) string {
+		return strings.NewReplacer("%2C", ",", "%3A", ":").Replace(url.QueryEscape(s))
+	}
+	if got, expected := values.EncodeWith(escape), "fields=id,name&time=10:30+am"; got != expected {
+		t.Errorf("Expected %q, got %q", expected, got)
+	}
+	if got, expected := values.EncodeWith(nil), values.Encode(); got != expected {
+		t.Errorf("Expected %q, got %q", expected, got)
+	}
+}
+
+func TestUrlValuesSemicolonSeparator(t *testing.T) {
+	values := UrlValues{{Key: "a", Value: "1;2"}, {Key: "b", Value: "3"}}
+	encoded := values.EncodeFormat(QueryFormat{Separator: ';'})
+	if encoded != "a=1%3B2;b=3" {
+		t.Errorf("Unexpected encoding %q", encoded)
+	}
+
+	parsed, err := ParseQueryWithSeparators(encoded+"&c=4", "&;")
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	expected := append(values.Clone(), UrlValue{Key: "c", Value: "4"})
+	if !reflect.DeepEqual(parsed, expected) {
+		t.Errorf("Expected %v, got %v", expected, parsed)
+	}
+}
+
+func TestUrlValuesEncodeRaw(t *testing.T) {
+	values := UrlValues{{Key: "q", Value: "a b&c"}, {Key: "é", Value: "%"}}
+	if got, expected := values.EncodeRaw(), "q=a b&c&é=%"; got != expected {
+		t.Errorf("Expected %q, got %q", expected, got)
+	}
+}
+
+func TestUrlValuesCanonicalize(t *testing.T) {
+	values := UrlValues{
+		{Key: "Version", Value: "2"},
+		{Key: "prefix", Value: "b c"},
+		{Key: "Action", Value: "List"},
+		{Key: "prefix", Value: "a"},
+		{Key: "a~b", Value: "*"},
+	}
+	expected := "Action=List&Version=2&a~b=%2A&prefix=a&prefix=b%20c"
+	if got := values.Canonicalize(); got != expected {
+		t.Errorf("Expected %q, got %q", expected, got)
+	}
+	if values[0].Key != "Version" {
+		t.Errorf("Canonicalize modified the values: %v", values)
+	}
+}
+
+type failingWriter struct {
+	n int
+}
+
+func (w *failingWriter) Write(p []byte) (int, error) {
+	if w.n -= len(p); w.n < 0 {
+		return 0, errors.New("write failed")
+	}
+	return len(p), nil
+}
+
+func TestUrlValuesWriteTo(t *testing.T) {
+	values := UrlValues{{Key: "a", Value: "1 2"}, {Key: "b", Value: "3"}}
+	var buf bytes.Buffer
+	n, err := values.WriteTo(&buf)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if buf.String() != values.Encode() || n != int64(buf.Len()) {
+		t.Errorf("Expected %q, got %q (%d bytes)", values.Encode(), buf.String(), n)
+	}
+
+	n, err = values.WriteTo(&failingWriter{n: 4})
+	if err == nil {
+		t.Error("Expected write error, got nil")
+	}
+	if n != 2 {
+		t.Errorf("Expected 2 bytes written, got %d", n)
+	}
+}
+
+func TestUrlValuesURLValuesConversion(t *testing.T) {
+	std := url.Values{"b": {"1", "2"}, "a": {"3"}, "c": {}}
+	values := FromURLValues(std)
+	expected := UrlValues{{"a", "3"}, {"b", "1"}, {"b", "2"}}
+	if !reflect.DeepEqual(values, expected) {
+		t.Errorf("Expected %v, got %v", expected, values)
+	}
+
+	back := values.ToURLValues()
+	delete(std, "c")
+	if !reflect.DeepEqual(back, std) {
+		t.Errorf("Expected %v, got %v", std, back)
+	}
+}
+
+func TestUrlValuesEncodeMatchesQueryEscape(t *testing.T) {
+	values := UrlValues{}
+	for c := 0; c < 256; c++ {
+		values.Add(string(rune(c)), "a"+string([]byte{byte(c)})+"é z")
+	}
+	if got, expected := values.Encode(), values.EncodeWith(url.QueryEscape); got != expected {
+		t.Errorf("Expected %q, got %q", expected, got)
+	}
+}
+
+func BenchmarkUrlValuesEncode(b *testing.B) {
+	values := UrlValues{}
+	for i := 0; i < 200; i++ {
+		values.Add(fmt.Sprintf("param_%d", i), fmt.Sprintf("value %d", i))
+	}
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		_ = values.Encode()
+	}
+}
+
+func TestUrlValuesRemoveDuplicates(t *testing.T) {
+	values := UrlValues{{"a", "1"}, {"b", "1"}, {"a", "1"}, {"a", "2"}, {"b", "1"}}
+	values.RemoveDuplicates()
+	expected := UrlValues{{"a", "1"}, {"b", "1"}, {"a", "2"}}
+	if !reflect.DeepEqual(values, expected) {
+		t.Errorf("Expected %v, got %v", expected, values)
+	}
+}